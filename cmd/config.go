@@ -0,0 +1,199 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ks-tool/ks/pkg/config"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage ks contexts",
+	Long: `Manage named contexts stored in the ks config file ($HOME/.ks/<config>.yaml).
+
+A context holds defaults for the yc flags (folder, zone, subnet, image family, credentials, bastion).
+The current context is used unless --context is given.`,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	for _, key := range config.ContextKeys() {
+		configSetContext.Flags().String(key, "", "")
+	}
+
+	configCmd.AddCommand(
+		configCurrentContext,
+		configDeleteContext,
+		configGetContexts,
+		configSetContext,
+		configUseContext,
+		configView,
+	)
+}
+
+var configCurrentContext = &cobra.Command{
+	Use:   "current-context",
+	Short: "Show the current context",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+		if len(cfg.CurrentContext) == 0 {
+			log.Fatal("current context is not set")
+		}
+
+		fmt.Println(cfg.CurrentContext)
+	},
+}
+
+var configDeleteContext = &cobra.Command{
+	Use:   "delete-context <name>",
+	Short: "Delete a context",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+		if err := cfg.DeleteContext(args[0]); err != nil {
+			log.Fatal(err)
+		}
+		if err := cfg.Save(); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Context %q deleted", args[0])
+	},
+}
+
+var configGetContexts = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List contexts",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+
+		tbl := table.NewWriter()
+		tbl.SetOutputMirror(os.Stdout)
+		tbl.AppendHeader(table.Row{"Current", "Name", "FolderID", "Zone", "SubnetID"})
+
+		for _, name := range cfg.ContextNames() {
+			var current string
+			if name == cfg.CurrentContext {
+				current = "*"
+			}
+
+			ctx := cfg.Contexts[name]
+			tbl.AppendRow(table.Row{current, name, ctx.FolderID, ctx.Zone, ctx.SubnetID})
+		}
+
+		tbl.Render()
+	},
+}
+
+var configSetContext = &cobra.Command{
+	Use:   "set-context [name]",
+	Short: "Create or update a context",
+	Long: `Create or update a context. Only the given flags are changed,
+an empty value unsets the key. Without a name the current context is updated.`,
+	Example: "  ks config set-context dev --folder-id b1g... --zone ru-central1-a --token-file ~/.yc-token",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+
+		name := cfg.CurrentContext
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if len(name) == 0 {
+			log.Fatal("context name required: current context is not set")
+		}
+
+		ctx := cfg.SetContext(name)
+		for _, key := range config.ContextKeys() {
+			if f := cmd.Flags().Lookup(key); f.Changed {
+				if err := ctx.Set(key, f.Value.String()); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+		if len(cfg.CurrentContext) == 0 {
+			cfg.CurrentContext = name
+		}
+
+		if err := cfg.Save(); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Context %q saved", name)
+	},
+}
+
+var configUseContext = &cobra.Command{
+	Use:   "use-context <name>",
+	Short: "Set the current context",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := loadConfig()
+		if _, err := cfg.Context(args[0]); err != nil {
+			log.Fatal(err)
+		}
+
+		cfg.CurrentContext = args[0]
+		if err := cfg.Save(); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Switched to context %q", args[0])
+	},
+}
+
+var configView = &cobra.Command{
+	Use:   "view",
+	Short: "Show the config file",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := yaml.Marshal(loadConfig())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Print(strings.TrimPrefix(string(b), "{}\n"))
+	},
+}
+
+func loadConfig() *config.Config {
+	path, err := config.Path(viper.GetString("config"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return cfg
+}
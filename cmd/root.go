@@ -20,6 +20,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/ks-tool/ks/pkg/yc"
+
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -43,7 +45,11 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringP("config", "c", "config", "config file (search in $HOME/.ks)")
+	rootCmd.PersistentFlags().String("context", "", "context to use instead of the current one (see `ks config`)")
 	rootCmd.PersistentFlags().Bool("debug", false, "")
+	rootCmd.PersistentFlags().StringP("output", "o", "", "output format: "+yc.OutputFormats)
+	rootCmd.PersistentFlags().String("sort-by", "", "sort list by a JSONPath expression, e.g. {.name}")
+	rootCmd.PersistentFlags().Bool("no-headers", false, "don't print table headers")
 
 	_ = viper.BindPFlags(rootCmd.PersistentFlags())
 }
@@ -17,7 +17,7 @@ limitations under the License.
 package cmd
 
 import (
-	"os"
+	"slices"
 	"time"
 
 	YC "github.com/ks-tool/ks/internal/yc"
@@ -43,50 +43,74 @@ to quickly create a Cobra application.`,
 
 func init() {
 	rootCmd.AddCommand(ycCmd)
-	cobra.OnInitialize(setTokenFromViper, setAllValueFlagsFromViper(ycCmd))
+	// The context is applied to the yc commands only, so that `ks config` works with a broken one.
+	ycCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		setFlagsFromContext(ycCmd)
+	}
 
-	ycCmd.AddCommand(YC.Compute(), YC.K8s())
+	ycCmd.AddCommand(YC.Address(), YC.Compute(), YC.Disk(), YC.DNS(), YC.Image(), YC.InstanceGroup(), YC.K8s(), YC.NATGateway(), YC.Network(), YC.SecurityGroup(), YC.Subnet())
 
 	ycCmd.PersistentFlags().StringP("folder-id", "f", "", "")
 	_ = ycCmd.MarkPersistentFlagRequired("folder-id")
 
 	ycCmd.PersistentFlags().StringP("subnet-id", "s", "", "")
 	ycCmd.PersistentFlags().StringP("zone", "z", yc.DefaultZone, "")
+	ycCmd.PersistentFlags().String("image-family", yc.DefaultImageFamily, "boot disk image family, the latest image of it is used")
+	ycCmd.PersistentFlags().String("image-folder", yc.DefaultImageFolderID, "folder ID of the image family")
+	ycCmd.PersistentFlags().String("bastion", "", "compute instance (name or ID, optionally with :port) to reach instances without an external IP through over SSH")
 	ycCmd.PersistentFlags().DurationP("timeout", "t", 180*time.Second, "")
-	ycCmd.PersistentFlags().StringP("token-file", "k", "", "")
-	ycCmd.PersistentFlags().String("token", "", "Env variable: YC_TOKEN")
-	ycCmd.MarkFlagsMutuallyExclusive("token", "token-file")
-	_ = ycCmd.MarkPersistentFlagRequired("token")
-
-	if err := viper.BindEnv("token", "YC_TOKEN"); err != nil {
-		log.Fatal(err)
+	ycCmd.PersistentFlags().StringP("token-file", "k", "", "file with an IAM or OAuth token")
+	ycCmd.PersistentFlags().String("token", "", "IAM or OAuth token. Env variable: YC_TOKEN")
+	ycCmd.PersistentFlags().String("sa-key-file", "", "service account authorized key JSON. Env variable: YC_SERVICE_ACCOUNT_KEY_FILE")
+	ycCmd.PersistentFlags().String("yc-profile", "", "yc CLI profile to take credentials from. Env variable: YC_PROFILE")
+	ycCmd.MarkFlagsMutuallyExclusive("token", "token-file", "sa-key-file")
+
+	for key, env := range map[string]string{
+		"token":       "YC_TOKEN",
+		"sa-key-file": "YC_SERVICE_ACCOUNT_KEY_FILE",
+		"yc-profile":  "YC_PROFILE",
+	} {
+		if err := viper.BindEnv(key, env); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	_ = viper.BindPFlags(ycCmd.PersistentFlags())
 }
 
-func setTokenFromViper() {
-	token := viper.GetString("token")
-	if len(token) > 0 {
-		return
+// credentialFlags are taken from the context all together, and only if none of them is given explicitly.
+var credentialFlags = []string{"token", "token-file", "sa-key-file", "yc-profile"}
+
+// setFlagsFromContext fills the persistent flags of cmd which were not given on the command line
+// from the selected context, falling back to the top-level keys of the config file.
+func setFlagsFromContext(cmd *cobra.Command) {
+	var values map[string]string
+	name := viper.GetString("context")
+	if ctx, err := loadConfig().Context(name); err == nil {
+		values = ctx.Values()
+	} else if len(name) > 0 {
+		log.Fatal(err)
 	}
 
-	tokenFile := viper.GetString("token-file")
-	if len(tokenFile) > 0 {
-		tok, err := os.ReadFile(tokenFile)
-		if err != nil {
-			log.Fatalf("read token file failed: %s", err)
+	var explicitCredentials bool
+	for _, key := range credentialFlags {
+		if viper.IsSet(key) {
+			explicitCredentials = true
 		}
-		_ = ycCmd.Flags().Set("token", string(tok))
 	}
-}
 
-func setAllValueFlagsFromViper(cmd *cobra.Command) func() {
-	return func() {
-		cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
-			if _, ok := f.Annotations[cobra.BashCompOneRequiredFlag]; ok && viper.IsSet(f.Name) {
-				_ = cmd.PersistentFlags().Set(f.Name, viper.GetString(f.Name))
-			}
-		})
-	}
+	cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+		if explicitCredentials && slices.Contains(credentialFlags, f.Name) {
+			return
+		}
+
+		if v, ok := values[f.Name]; ok {
+			_ = cmd.PersistentFlags().Set(f.Name, v)
+		} else if _, ok = f.Annotations[cobra.BashCompOneRequiredFlag]; ok && viper.IsSet(f.Name) {
+			_ = cmd.PersistentFlags().Set(f.Name, viper.GetString(f.Name))
+		}
+	})
 }
@@ -0,0 +1,238 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Address represents the address command
+func Address() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "address",
+		Short: "Manage static public IP addresses",
+	}
+
+	addressReserve.Flags().String("name", "", "address name")
+	addressList.Flags().Bool("all", false, "show all addresses including ephemeral ones and the ones not reserved by ks")
+	addressRelease.Flags().Bool("unused", false, "release all unused addresses reserved by ks")
+	noWait(addressRelease)
+	parallel(addressRelease)
+
+	cmd.AddCommand(
+		addressReserve,
+		addressList,
+		addressRelease,
+	)
+
+	return cmd
+}
+
+var addressReserve = &cobra.Command{
+	Use:   "reserve",
+	Short: "Reserve a static public IP address in the zone",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		op, err := client.VPCAddressReserve(
+			ctx,
+			viper.GetString("folder-id"),
+			viper.GetString("zone"),
+			viper.GetString("name"),
+			checkLabels(nil),
+		)
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := op.Response()
+		if err != nil {
+			log.Fatal(err)
+		}
+		address := resp.(*vpc.Address)
+
+		log.Infof("The address %s (%s) reserved", yc.AddressIP(address), address.Id)
+	},
+}
+
+var addressList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of static public IP addresses reserved by ks",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst, err := client.VPCAddressList(ctx, viper.GetString("folder-id"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !viper.GetBool("all") {
+			lst = managedAddresses(lst)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.AddressColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var addressRelease = &cobra.Command{
+	Aliases: []string{"rm", "del"},
+	Use:     "release <name|id|ip>... | --unused",
+	Short:   "Release static public IP addresses",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		unused := viper.GetBool("unused")
+		if len(args) == 0 && !unused {
+			log.Fatal("address name, ID, IP or --unused required")
+		}
+
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		var lst []*vpc.Address
+		for _, ref := range args {
+			address, err := client.VPCAddressResolve(ctx, folderId, ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if address.Used {
+				log.Fatalf("The address %s is used by a compute instance, delete the instance or its public IP first", ref)
+			}
+			lst = append(lst, address)
+		}
+
+		if unused {
+			all, err := client.VPCAddressList(ctx, folderId)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, address := range managedAddresses(all) {
+				if !address.Used {
+					lst = append(lst, address)
+				}
+			}
+		}
+		if len(lst) == 0 {
+			log.Info("No addresses to release")
+			return
+		}
+
+		tasks := make([]yc.BulkTask, 0, len(lst))
+		seen := make(map[string]bool)
+		for _, address := range lst {
+			if seen[address.Id] {
+				continue
+			}
+			seen[address.Id] = true
+
+			id := address.Id
+			tasks = append(tasks, yc.BulkTask{
+				ID:   id,
+				Name: yc.AddressIP(address),
+				Run: func(ctx context.Context) (*operation.Operation, error) {
+					return client.VPCAddressRelease(ctx, id)
+				},
+			})
+		}
+
+		exitBulk(runBulk(ctx, "Releasing", tasks))
+	},
+}
+
+// managedAddresses returns the reserved addresses with the managed label of ks.
+func managedAddresses(lst []*vpc.Address) []*vpc.Address {
+	var out []*vpc.Address
+	for _, address := range lst {
+		if address.Reserved && address.Labels[common.ManagedKey] == yc.KsToolKey {
+			out = append(out, address)
+		}
+	}
+
+	return out
+}
+
+// reserveAddresses makes the ephemeral public addresses of the instance static, naming them after it.
+// Addresses given on create are reserved already and left as is.
+func reserveAddresses(ctx context.Context, client *yc.Client, instance *compute.Instance) error {
+	var ips []string
+	for _, nic := range instance.NetworkInterfaces {
+		if ip := nic.GetPrimaryV4Address().GetOneToOneNat().GetAddress(); len(ip) > 0 {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("compute instance %s has no public IP to reserve", instance.Name)
+	}
+
+	for n, ip := range ips {
+		name := instance.Name
+		if len(name) > 0 && n > 0 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+
+		address, op, err := client.VPCAddressPromote(ctx, ip, name, checkLabels(nil))
+		if err == nil && op == nil {
+			continue
+		}
+		if err = waitOperation(ctx, op, err); err != nil {
+			return fmt.Errorf("reserve address %s: %w", ip, err)
+		}
+		log.Infof("The address %s (%s) reserved", ip, address.Id)
+	}
+
+	return nil
+}
@@ -19,19 +19,25 @@ package yc
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"os/user"
 	"strings"
 
 	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/remote"
+	"github.com/ks-tool/ks/pkg/utils"
 	"github.com/ks-tool/ks/pkg/yc"
 
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
 
 	"github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 // Compute represents the compute command
@@ -43,19 +49,50 @@ func Compute() *cobra.Command {
 	}
 
 	computeCreateFlags(vmCreate)
+	securityGroupFlags(vmCreate, nil)
 	noWait(vmDelete)
 	noWait(vmStart)
 	noWait(vmStop)
+	resolveFlags(vmDelete)
+	resolveFlags(vmStart)
+	resolveFlags(vmStop)
+	parallel(vmDelete)
+	parallel(vmStart)
+	parallel(vmStop)
+	vmGetFlags(vmGet)
 	vmListFlags(vmList)
+	vmLogsFlags(vmLogs)
+	sshFlags(vmSsh)
+	sshFlags(vmExec)
+	resolveFlags(vmExec)
+	vmExec.Flags().Int("parallel", remote.DefaultParallel, "number of instances the command runs on at once")
+	sshFlags(vmCp)
+	sshFlags(vmWait)
+	resolveFlags(vmWait)
+	waitFlags(vmWait)
+	resolveFlags(vmCp)
+	vmCp.Flags().BoolP("recursive", "r", false, "copy directories recursively")
+	vmCp.Flags().Int("parallel", remote.DefaultParallel, "number of instances to copy to or from at once")
+	vmUpdateFlags(vmUpdate)
 	vmUserDataShowFlags(vmUserDataShow)
+	vmAttachDiskFlags(vmAttachDisk)
 
 	cmd.AddCommand(
+		vmAttachDisk,
+		vmCp,
 		vmCreate,
 		vmDelete,
+		vmDetachDisk,
+		vmExec,
+		vmGet,
 		vmList,
+		vmLogs,
+		vmSsh,
 		vmStart,
 		vmStop,
+		vmUpdate,
 		vmUserDataShow,
+		vmWait,
 	)
 
 	return cmd
@@ -93,7 +130,9 @@ var vmCreate = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		client, err := yc.NewClient(viper.GetString("token"))
+		cond := waitConditionFromFlags("wait-for")
+
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -101,6 +140,8 @@ var vmCreate = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
 		defer cancel()
 
+		zone := dnsZoneFromFlags(ctx, client, config.Labels)
+
 		op, err := client.ComputeInstanceCreate(ctx, config)
 		if err != nil {
 			log.Fatal(err)
@@ -127,20 +168,35 @@ var vmCreate = &cobra.Command{
 		ip := yc.GetIPv4(instance).External()
 
 		log.Infof("The compute instance %s (%s) created", instance.Name, ip)
+
+		if viper.GetBool("reserve-address") {
+			if err = reserveAddresses(ctx, client, instance); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if zone != nil {
+			updateInstanceDNS(ctx, client, []*compute.Instance{instance})
+		}
+
+		if cond != nil {
+			if err = newWaiter(ctx, client, *cond).wait(ctx, instance.Id); err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("The compute instance %s is ready", instance.Name)
+		}
 	},
 }
 
 var vmDelete = &cobra.Command{
 	Aliases: []string{"rm", "del"},
-	Use:     "delete <instance-id>",
-	Short:   "Delete a compute instance",
+	Use:     "delete <name|id>... | -l <selector>",
+	Short:   "Delete compute instances",
 	PreRun: func(cmd *cobra.Command, args []string) {
 		_ = viper.BindPFlags(cmd.Flags())
 	},
-	PreRunE: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Infof("The compute instance %s will be deleted", args[0])
-		client, err := yc.NewClient(viper.GetString("token"))
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -148,33 +204,86 @@ var vmDelete = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
 		defer cancel()
 
-		op, err := client.ComputeInstanceDelete(ctx, args[0])
+		lst := resolveInstances(ctx, client, args)
+		results, failed := runBulk(ctx, "Deleting", yc.ComputeInstanceTasks(lst, client.ComputeInstanceDelete))
+		removeInstanceDNS(ctx, client, succeededInstances(lst, results))
+		exitBulk(results, failed)
+	},
+}
+
+var vmGet = &cobra.Command{
+	Aliases: []string{"describe"},
+	Use:     "get <name|id>...",
+	Short:   "Show details of compute instances",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		if viper.GetBool("no-wait") {
-			log.Info("The compute instance will be deleted async ...")
-			return
-		}
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
 
-		if err = op.Wait(ctx); err != nil {
+		refs, err := client.ComputeInstanceResolve(ctx, viper.GetString("folder-id"), args, nil)
+		if err != nil {
 			log.Fatal(err)
 		}
 
-		log.Info("The compute instance has been deleted")
+		lst := make([]*compute.Instance, 0, len(refs))
+		for _, ref := range refs {
+			instance, err := client.ComputeInstanceGet(ctx, ref.Id)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if !viper.GetBool("show-user-data") {
+				instance = yc.HideUserData(instance)
+			}
+			lst = append(lst, instance)
+		}
+
+		p := newPrinter()
+		switch {
+		case p.Format == yc.OutputTable || p.Format == yc.OutputWide:
+			for n, instance := range lst {
+				if n > 0 {
+					fmt.Println()
+				}
+				if err = yc.FPrintComputeDescribe(os.Stdout, instance); err != nil {
+					break
+				}
+			}
+		case len(lst) == 1:
+			err = yc.PrintItem(os.Stdout, p, lst[0], yc.ComputeColumns)
+		default:
+			err = yc.FPrintComputeList(os.Stdout, p, lst)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
 	},
 }
 
-var vmList = &cobra.Command{
-	Aliases: []string{"ls"},
-	Use:     "list",
-	Short:   "List of compute instances",
+var vmLogs = &cobra.Command{
+	Use:   "logs <name|id>",
+	Short: "Show the serial port output of a compute instance",
+	Long: `Show the serial port output of a compute instance.
+
+Lines in which cloud-init reports a failure are highlighted when the output is a terminal.`,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		_ = viper.BindPFlags(cmd.Flags())
 	},
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := yc.NewClient(viper.GetString("token"))
+		port := viper.GetInt64("port")
+		if port < 1 || port > yc.SerialPorts {
+			log.Fatalf("invalid serial port %d, allow: 1..%d", port, yc.SerialPorts)
+		}
+
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -182,43 +291,46 @@ var vmList = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
 		defer cancel()
 
-		var lbl map[string]string
-		if !viper.GetBool("all") {
-			lbl = checkLabels(nil)
+		lst, err := client.ComputeInstanceResolve(ctx, viper.GetString("folder-id"), args, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var w io.Writer = os.Stdout
+		if viper.GetBool("highlight") {
+			h := yc.NewHighlighter(os.Stdout)
+			defer h.Flush()
+			w = h
 		}
 
-		var filters []yc.Filter
-		status := viper.GetString("status")
-		if len(status) > 0 {
-			if !yc.AllowStatus(status) {
-				log.Fatalf("invalid status %q", status)
+		if !viper.GetBool("follow") {
+			out, err := client.ComputeInstanceSerialPortOutput(ctx, lst[0].Id, port)
+			if err != nil {
+				log.Fatal(err)
 			}
-			filters = append(filters, yc.Filter{
-				Field:    "status",
-				Operator: yc.OperatorEq,
-				Value:    strings.ToUpper(status),
-			})
+			_, _ = io.WriteString(w, out)
+			return
 		}
 
-		folderId := viper.GetString("folder-id")
-		lst, err := client.ComputeInstanceList(ctx, folderId, lbl, filters...)
-		if err != nil {
+		// Following is not limited by --timeout.
+		fctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		if err = client.ComputeInstanceSerialPortFollow(fctx, lst[0].Id, port, w); err != nil {
 			log.Fatal(err)
 		}
-
-		yc.FPrintComputeList(os.Stdout, lst)
 	},
 }
 
-var vmStart = &cobra.Command{
-	Use:   "start <instance-id>",
-	Short: "Start a compute instance",
+var vmList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of compute instances",
 	PreRun: func(cmd *cobra.Command, args []string) {
 		_ = viper.BindPFlags(cmd.Flags())
 	},
-	PreRunE: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := yc.NewClient(viper.GetString("token"))
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -226,41 +338,91 @@ var vmStart = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
 		defer cancel()
 
-		op, err := client.ComputeInstanceStart(ctx, args[0])
+		sel, err := selectorFromFlags()
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		if viper.GetBool("no-wait") {
-			log.Info("The compute instance will be deleted async ...")
-			return
+		folderId := viper.GetString("folder-id")
+		lst, err := client.ComputeInstanceSelect(ctx, folderId, sel)
+		if err != nil {
+			log.Fatal(err)
 		}
 
-		if err = op.Wait(ctx); err != nil {
+		if err = yc.FPrintComputeList(os.Stdout, newPrinter(), lst); err != nil {
 			log.Fatal(err)
 		}
+	},
+}
 
-		resp, err := op.Response()
+var vmStart = &cobra.Command{
+	Use:   "start <name|id>... | -l <selector>",
+	Short: "Start compute instances",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		instance := resp.(*compute.Instance)
-		ip := yc.GetIPv4(instance).External()
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
 
-		log.Infof("The compute instance %s (%s) started", instance.Name, ip)
+		lst := resolveInstances(ctx, client, args)
+		results, failed := runBulk(ctx, "Starting", yc.ComputeInstanceTasks(lst, client.ComputeInstanceStart))
+		var started []*compute.Instance
+		for _, res := range results {
+			if instance, ok := res.Response.(*compute.Instance); ok {
+				log.Infof("The compute instance %s (%s) started", instance.Name, yc.GetIPv4(instance).External())
+				started = append(started, instance)
+			}
+		}
+		if viper.GetBool("no-wait") {
+			warnInstanceDNS(succeededInstances(lst, results))
+		} else {
+			updateInstanceDNS(ctx, client, started)
+		}
+		exitBulk(results, failed)
 	},
 }
 
 var vmStop = &cobra.Command{
-	Use:   "stop <instance-id>",
-	Short: "Stop a compute instance",
+	Use:   "stop <name|id>... | -l <selector>",
+	Short: "Stop compute instances",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst := resolveInstances(ctx, client, args)
+		results, failed := runBulk(ctx, "Stopping", yc.ComputeInstanceTasks(lst, client.ComputeInstanceStop))
+		removeInstanceDNS(ctx, client, succeededInstances(lst, results))
+		exitBulk(results, failed)
+	},
+}
+
+var vmUpdate = &cobra.Command{
+	Use:   "update <name|id>",
+	Short: "Change the platform, resources, preemptibility or service account of a compute instance",
+	Long: `Change the platform, resources, preemptibility or service account of a compute instance.
+
+Only the given flags are changed. Changing anything but the service account requires the instance
+to be stopped: with --allow-restart a running instance is stopped, updated and started again.`,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		_ = viper.BindPFlags(cmd.Flags())
 	},
-	PreRunE: cobra.ExactArgs(1),
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		client, err := yc.NewClient(viper.GetString("token"))
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -268,27 +430,81 @@ var vmStop = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
 		defer cancel()
 
-		op, err := client.ComputeInstanceStop(ctx, args[0])
+		folderId := viper.GetString("folder-id")
+		lst, err := client.ComputeInstanceResolve(ctx, folderId, args, nil)
 		if err != nil {
 			log.Fatal(err)
 		}
+		instance := lst[0]
 
-		if viper.GetBool("no-wait") {
-			log.Info("The compute instance will be deleted async ...")
+		var upd yc.ComputeInstanceUpdate
+		flags := cmd.Flags()
+		if flags.Changed("platform-id") {
+			upd.PlatformID = utils.Ptr(viper.GetString("platform-id"))
+		}
+		if flags.Changed("cores") {
+			upd.Cores = utils.Ptr(viper.GetInt64("cores"))
+		}
+		if flags.Changed("core-fraction") {
+			upd.CoreFraction = utils.Ptr(viper.GetInt64("core-fraction"))
+		}
+		if flags.Changed("memory") {
+			upd.MemoryGib = utils.Ptr(viper.GetInt64("memory"))
+		}
+		if flags.Changed("preemptible") {
+			upd.Preemptible = utils.Ptr(viper.GetBool("preemptible"))
+		}
+		if flags.Changed("sa") {
+			var saId string
+			if name := viper.GetString("sa"); len(name) > 0 {
+				if saId, err = client.IAMServiceAccountGetIdByName(ctx, folderId, name); err != nil {
+					log.Fatal(err)
+				}
+			}
+			upd.ServiceAccountID = &saId
+		}
+
+		req, changes := upd.Request(instance)
+		if len(changes) == 0 {
+			log.Infof("Nothing to update in compute instance %s", instance.Name)
 			return
 		}
+		yc.FPrintInstanceChanges(os.Stdout, changes)
 
-		if err = op.Wait(ctx); err != nil {
-			log.Fatal(err)
+		restart := yc.NeedsRestart(changes) && instance.Status != compute.Instance_STOPPED
+		if restart {
+			if !viper.GetBool("allow-restart") {
+				log.Fatalf("The changes require stopping compute instance %s, rerun with --allow-restart", instance.Name)
+			}
+			if instance.Status != compute.Instance_RUNNING {
+				log.Fatalf("The compute instance %s is %s", instance.Name, instance.Status)
+			}
+
+			log.Infof("Stopping compute instance %s ...", instance.Name)
+			op, err := client.ComputeInstanceStop(ctx, instance.Id)
+			if err = waitOperation(ctx, op, err); err != nil {
+				log.Fatal(err)
+			}
 		}
 
-		resp, err := op.Response()
-		if err != nil {
+		log.Infof("Updating compute instance %s ...", instance.Name)
+		op, err := client.ComputeInstanceUpdate(ctx, req)
+		if err = waitOperation(ctx, op, err); err != nil {
+			if restart {
+				log.Fatalf("Updating failed, the compute instance %s is left stopped: %s", instance.Name, err)
+			}
 			log.Fatal(err)
 		}
 
-		instance := resp.(*compute.Instance)
-		log.Infof("The compute instance %s stopped", instance.Name)
+		if restart {
+			log.Infof("Starting compute instance %s ...", instance.Name)
+			op, err = client.ComputeInstanceStart(ctx, instance.Id)
+			if err = waitOperation(ctx, op, err); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		log.Infof("The compute instance %s updated", instance.Name)
 	},
 }
 
@@ -338,10 +554,26 @@ func computeCreateFlags(cmd *cobra.Command) {
 	cmd.Flags().Int64("core-fraction", yc.DefaultCoreFraction, "")
 	cmd.Flags().Int64("memory", yc.DefaultMemoryGib, "")
 	cmd.Flags().String("disk-type", yc.DefaultDiskType, "")
-	cmd.Flags().String("disk-id", yc.DefaultDiskID, "set boot disk id")
+	cmd.Flags().String("disk-id", "", "boot disk image ID, overrides --image-family")
 	cmd.Flags().Int64("disk-size", yc.DefaultDiskSizeGib, "")
+	cmd.Flags().StringArray("secondary-disk", nil,
+		"attach a data disk, e.g. 'size=50,type=network-ssd,name=data,auto-delete=false,mount=/data'. "+
+			"Keys: name, size (GiB), type, id, snapshot, auto-delete, mount, fs")
 	cmd.Flags().Bool("preemptible", true, "")
-	cmd.Flags().Bool("no-public-ip", false, "")
+	cmd.Flags().Bool("no-public-ip", false, "no external IP, use nat-gateway ensure for internet access and --bastion for SSH")
+	cmd.Flags().StringArray("network-interface", nil,
+		"add a network interface instead of the default one, e.g. "+
+			"'subnet=<id>,ipv4=10.0.0.5,nat=true,security-group-ids=<id>,<id>,ipv6=true'. "+
+			"Keys: subnet (default --subnet-id), ipv4, nat, nat-address, ipv6, ipv6-address, security-group-ids")
+	cmd.MarkFlagsMutuallyExclusive("network-interface", "address")
+	cmd.MarkFlagsMutuallyExclusive("network-interface", "no-public-ip")
+	cmd.Flags().StringArray("subnet-label", nil,
+		"prefer the subnet of the zone with the label key=value when --subnet-id is not given")
+	cmd.Flags().String("subnet-cidr", "", "prefer the subnet of the zone within the CIDR when --subnet-id is not given")
+	cmd.Flags().Bool("auto-network", false,
+		"create the network '"+yc.AutoNetworkName+"' with a subnet per zone if the zone has no subnet")
+	cmd.Flags().Bool("reserve-address", false, "make the public IP static, so it is kept across restarts, see address")
+	cmd.MarkFlagsMutuallyExclusive("reserve-address", "no-public-ip")
 	cmd.Flags().String("sa", "", "service account name")
 	cmd.Flags().String("user-data-file", "", "")
 
@@ -356,15 +588,175 @@ func computeCreateFlags(cmd *cobra.Command) {
 	_ = cmd.MarkFlagRequired("user")
 
 	cmd.Flags().String("shell", "/bin/bash", "set login shell for user")
+	cmd.Flags().String("dns-zone", "", "DNS zone (domain, name or ID) to register <name>.<zone> records of the instance in")
+	cmd.Flags().String("wait-for", "", "wait until the instance is ready: "+yc.WaitConditions)
+	cmd.Flags().Bool("serial", false, "check cloud-init in the serial port output instead of over SSH")
+}
+
+func vmUpdateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("platform-id", "", "")
+	cmd.Flags().Int64("cores", 0, "")
+	cmd.Flags().Int64("core-fraction", 0, "")
+	cmd.Flags().Int64("memory", 0, "memory in GiB")
+	cmd.Flags().Bool("preemptible", false, "")
+	cmd.Flags().String("sa", "", "service account name, empty to remove the service account")
+	cmd.Flags().Bool("allow-restart", false, "stop and start a running instance if the changes require it")
+}
+
+// waitOperation waits for the operation returned with err.
+func waitOperation(ctx context.Context, op *operation.Operation, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return op.Wait(ctx)
 }
 
 func noWait(cmd *cobra.Command) {
 	cmd.Flags().Bool("no-wait", false, "don't wait for completion")
 }
 
+func parallel(cmd *cobra.Command) {
+	cmd.Flags().Int("parallel", yc.DefaultParallel, "number of operations to run concurrently")
+}
+
+// runBulk runs the tasks with the --parallel and --no-wait flags, showing the progress on stderr
+// and a summary on stdout. It returns the results and the number of failed tasks,
+// the caller acts on the succeeded ones and then calls exitBulk.
+func runBulk(ctx context.Context, action string, tasks []yc.BulkTask) ([]yc.BulkResult, int) {
+	view := yc.NewProgressView(os.Stderr, action)
+	results := yc.RunBulk(ctx, tasks, yc.BulkOptions{
+		Parallel: viper.GetInt("parallel"),
+		NoWait:   viper.GetBool("no-wait"),
+		Progress: view,
+	})
+	view.Stop()
+
+	yc.FPrintBulkResults(os.Stdout, results)
+
+	return results, yc.BulkFailed(results)
+}
+
+// exitBulk exits with a non-zero code if any task failed.
+func exitBulk(results []yc.BulkResult, failed int) {
+	if failed > 0 {
+		log.Fatalf("%d of %d operations failed", failed, len(results))
+	}
+}
+
+// succeededInstances returns the instances whose tasks succeeded.
+func succeededInstances(lst []*compute.Instance, results []yc.BulkResult) []*compute.Instance {
+	ok := make(map[string]bool, len(results))
+	for _, res := range results {
+		if res.Err == nil {
+			ok[res.ID] = true
+		}
+	}
+
+	var out []*compute.Instance
+	for _, instance := range lst {
+		if ok[instance.Id] {
+			out = append(out, instance)
+		}
+	}
+
+	return out
+}
+
+func vmGetFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("show-user-data", false, "show the user-data instead of its size")
+}
+
+func vmLogsFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64("port", 1, fmt.Sprintf("serial port, 1..%d", yc.SerialPorts))
+	cmd.Flags().BoolP("follow", "f", false, "poll for new output")
+	cmd.Flags().Bool("highlight", term.IsTerminal(int(os.Stdout.Fd())), "highlight cloud-init failures")
+}
+
 func vmListFlags(cmd *cobra.Command) {
 	cmd.Flags().Bool("all", false, "show all compute instances")
 	cmd.Flags().String("status", "", "show compute instances with specific status. Allow: "+yc.Statuses())
+	selectorFlags(cmd)
+}
+
+func resolveFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("all", false, "let the selector match compute instances not managed by ks")
+	selectorFlags(cmd)
+}
+
+// resolveInstances finds the compute instances given by name or ID in args and by the selector flags.
+// The selector is used only if --selector or --filter is given.
+func resolveInstances(ctx context.Context, client *yc.Client, args []string) []*compute.Instance {
+	var sel *yc.Selector
+	if len(viper.GetStringSlice("selector")) > 0 || len(viper.GetStringSlice("filter")) > 0 {
+		s, err := selectorFromFlags()
+		if err != nil {
+			log.Fatal(err)
+		}
+		sel = &s
+	}
+	if len(args) == 0 && sel == nil {
+		log.Fatal("compute instance name, ID or --selector required")
+	}
+
+	lst, err := client.ComputeInstanceResolve(ctx, viper.GetString("folder-id"), args, sel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(lst) == 0 {
+		log.Fatal("no compute instances match the selector")
+	}
+
+	return lst
+}
+
+func selectorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayP("selector", "l", nil,
+		"label selector, e.g. 'env=prod,role!=db', 'tier in (web,api)', 'name=~^web-', 'gpu' or '!gpu'")
+	cmd.Flags().StringArray("filter", nil,
+		"field filter, e.g. 'name=~^web-' or 'zone=ru-central1-a'. Fields: "+strings.Join(yc.SelectorFields, ", "))
+}
+
+// selectorFromFlags builds a selector from the --selector, --filter, --status and --all flags.
+// Unless --all is given only instances managed by ks are selected.
+func selectorFromFlags() (yc.Selector, error) {
+	var sel yc.Selector
+	for _, s := range viper.GetStringSlice("selector") {
+		reqs, err := yc.ParseLabelSelector(s)
+		if err != nil {
+			return sel, err
+		}
+		sel.Labels = append(sel.Labels, reqs...)
+	}
+
+	for _, s := range viper.GetStringSlice("filter") {
+		req, err := yc.ParseFieldFilter(s)
+		if err != nil {
+			return sel, err
+		}
+		sel.Fields = append(sel.Fields, req)
+	}
+
+	if status := viper.GetString("status"); len(status) > 0 {
+		if !yc.AllowStatus(status) {
+			return sel, fmt.Errorf("invalid status %q", status)
+		}
+		sel.Fields = append(sel.Fields, yc.Requirement{
+			Key:      "status",
+			Operator: yc.SelectorEq,
+			Values:   []string{strings.ToUpper(status)},
+		})
+	}
+
+	if !viper.GetBool("all") {
+		sel.Labels = append(sel.Labels, yc.Requirement{
+			Key:      common.ManagedKey,
+			Operator: yc.SelectorEq,
+			Values:   []string{yc.KsToolKey},
+		})
+	}
+
+	return sel, nil
 }
 
 func vmUserDataShowFlags(cmd *cobra.Command) {
@@ -377,6 +769,7 @@ func vmUserDataShowFlags(cmd *cobra.Command) {
 	cmd.Flags().StringSlice("ssh-pub", nil, "")
 	cmd.Flags().String("shell", "/bin/bash", "set login shell for user")
 	cmd.Flags().String("user-data-file", "", "")
+	cmd.Flags().StringArray("secondary-disk", nil, "secondary disk to mount, see create")
 	cmd.Flags().Bool("template", false, "show template")
 }
 
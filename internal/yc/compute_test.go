@@ -0,0 +1,68 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+)
+
+func TestSucceededInstances(t *testing.T) {
+	lst := []*compute.Instance{{Id: "a", Name: "web-1"}, {Id: "b", Name: "web-2"}, {Id: "c", Name: "web-3"}}
+
+	tests := []struct {
+		name    string
+		results []yc.BulkResult
+		want    []string
+	}{
+		{
+			name:    "all succeeded",
+			results: []yc.BulkResult{{ID: "a"}, {ID: "b"}, {ID: "c"}},
+			want:    []string{"web-1", "web-2", "web-3"},
+		},
+		{
+			name:    "some failed",
+			results: []yc.BulkResult{{ID: "a"}, {ID: "b", Err: errors.New("boom")}, {ID: "c"}},
+			want:    []string{"web-1", "web-3"},
+		},
+		{
+			name:    "all failed",
+			results: []yc.BulkResult{{ID: "a", Err: errors.New("boom")}, {ID: "b", Err: errors.New("boom")}},
+		},
+		{
+			name:    "in the order of the instances",
+			results: []yc.BulkResult{{ID: "c"}, {ID: "a"}},
+			want:    []string{"web-1", "web-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, instance := range succeededInstances(lst, tt.results) {
+				got = append(got, instance.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("succeededInstances = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,472 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ks-tool/ks/pkg/remote"
+	"github.com/ks-tool/ks/pkg/utils"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Disk represents the disk command
+func Disk() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disk",
+		Short: "Manage compute disks and snapshots",
+	}
+
+	diskCreateFlags(diskCreate)
+	noWait(diskDelete)
+	parallel(diskDelete)
+	diskResize.Flags().Int64("size", 0, "new size in GiB, disks can only grow")
+	_ = diskResize.MarkFlagRequired("size")
+
+	snapshot := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage disk snapshots",
+	}
+	snapshotCreate.Flags().String("name", "", "snapshot name")
+	noWait(snapshotDelete)
+	parallel(snapshotDelete)
+	snapshot.AddCommand(
+		snapshotCreate,
+		snapshotDelete,
+		snapshotList,
+	)
+
+	cmd.AddCommand(
+		diskCreate,
+		diskDelete,
+		diskList,
+		diskResize,
+		snapshot,
+	)
+
+	return cmd
+}
+
+var diskCreate = &cobra.Command{
+	Use:   "create",
+	Short: "Create an empty disk or restore a disk from a snapshot",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		cfg := &yc.DiskConfig{
+			FolderID: folderId,
+			Name:     viper.GetString("name"),
+			Zone:     viper.GetString("zone"),
+			TypeID:   viper.GetString("type"),
+			SizeGib:  viper.GetInt64("size"),
+			Labels:   checkLabels(nil),
+		}
+
+		if ref := viper.GetString("snapshot"); len(ref) > 0 {
+			snapshot, err := client.ComputeSnapshotResolve(ctx, folderId, ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+			cfg.SnapshotID = snapshot.Id
+			if cfg.SizeGib == 0 {
+				cfg.SizeGib = (snapshot.DiskSize + utils.Gib - 1) / utils.Gib
+			}
+		}
+		if cfg.SizeGib <= 0 {
+			log.Fatal("--size or --snapshot required")
+		}
+
+		op, err := client.ComputeDiskCreate(ctx, cfg)
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := op.Response()
+		if err != nil {
+			log.Fatal(err)
+		}
+		disk := resp.(*compute.Disk)
+
+		log.Infof("The disk %s (%s) created", disk.Name, disk.Id)
+	},
+}
+
+var diskDelete = &cobra.Command{
+	Aliases: []string{"rm", "del"},
+	Use:     "delete <name|id>...",
+	Short:   "Delete disks",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		tasks := make([]yc.BulkTask, 0, len(args))
+		for _, ref := range args {
+			disk, err := client.ComputeDiskResolve(ctx, viper.GetString("folder-id"), ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if len(disk.InstanceIds) > 0 {
+				log.Fatalf("The disk %s is attached to compute instance %s, detach it first", ref, disk.InstanceIds[0])
+			}
+
+			id := disk.Id
+			tasks = append(tasks, yc.BulkTask{
+				ID:   id,
+				Name: disk.Name,
+				Run: func(ctx context.Context) (*operation.Operation, error) {
+					return client.ComputeDiskDelete(ctx, id)
+				},
+			})
+		}
+
+		exitBulk(runBulk(ctx, "Deleting", tasks))
+	},
+}
+
+var diskList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of disks",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst, err := client.ComputeDiskList(ctx, viper.GetString("folder-id"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.DiskColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var diskResize = &cobra.Command{
+	Use:   "resize <name|id>",
+	Short: "Grow a disk",
+	Long: `Grow a disk.
+
+The filesystem on the disk is not resized, run e.g. resize2fs on the instance afterwards.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		disk, err := client.ComputeDiskResolve(ctx, viper.GetString("folder-id"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		size := viper.GetInt64("size")
+		if current := disk.Size / utils.Gib; size <= current {
+			log.Fatalf("The disk %s is %dG already, disks can only grow", args[0], current)
+		}
+
+		op, err := client.ComputeDiskResize(ctx, disk.Id, size)
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("The disk %s resized to %dG", args[0], size)
+	},
+}
+
+var snapshotCreate = &cobra.Command{
+	Use:   "create <disk-name|id>",
+	Short: "Create a snapshot of a disk",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		disk, err := client.ComputeDiskResolve(ctx, folderId, args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Creating snapshot of disk %s ...", args[0])
+		op, err := client.ComputeSnapshotCreate(ctx, folderId, disk.Id, viper.GetString("name"), checkLabels(nil))
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := op.Response()
+		if err != nil {
+			log.Fatal(err)
+		}
+		snapshot := resp.(*compute.Snapshot)
+
+		log.Infof("The snapshot %s (%s) created", snapshot.Name, snapshot.Id)
+	},
+}
+
+var snapshotDelete = &cobra.Command{
+	Aliases: []string{"rm", "del"},
+	Use:     "delete <name|id>...",
+	Short:   "Delete snapshots",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		tasks := make([]yc.BulkTask, 0, len(args))
+		for _, ref := range args {
+			snapshot, err := client.ComputeSnapshotResolve(ctx, viper.GetString("folder-id"), ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			id := snapshot.Id
+			tasks = append(tasks, yc.BulkTask{
+				ID:   id,
+				Name: snapshot.Name,
+				Run: func(ctx context.Context) (*operation.Operation, error) {
+					return client.ComputeSnapshotDelete(ctx, id)
+				},
+			})
+		}
+
+		exitBulk(runBulk(ctx, "Deleting", tasks))
+	},
+}
+
+var snapshotList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of snapshots",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst, err := client.ComputeSnapshotList(ctx, viper.GetString("folder-id"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.SnapshotColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var vmAttachDisk = &cobra.Command{
+	Use:   "attach-disk <name|id> <disk-name|id>",
+	Short: "Attach a disk to a compute instance",
+	Long: `Attach a disk to a compute instance.
+
+With --mount the disk is formatted unless it has a filesystem, mounted and added to /etc/fstab
+over SSH. The disk appears in the instance as /dev/disk/by-id/virtio-<device-name>.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		lst, err := client.ComputeInstanceResolve(ctx, folderId, args[:1], nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		instance := lst[0]
+
+		disk, err := client.ComputeDiskResolve(ctx, folderId, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		spec := "id=" + disk.Id
+		if name := viper.GetString("device-name"); len(name) > 0 {
+			spec += ",name=" + name
+		}
+		if mount := viper.GetString("mount"); len(mount) > 0 {
+			spec += ",mount=" + mount + ",fs=" + viper.GetString("fs")
+		}
+		if cmd.Flags().Changed("auto-delete") {
+			spec += ",auto-delete=" + viper.GetString("auto-delete")
+		}
+		d, err := yc.ParseSecondaryDisk(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Attaching disk %s to compute instance %s ...", args[1], instance.Name)
+		op, err := client.ComputeInstanceAttachDisk(ctx, instance.Id, d.Spec(instance.Name))
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		if len(d.Mount) > 0 {
+			if err = mountDisk(ctx, client, instance, d); err != nil {
+				log.Fatalf("The disk %s is attached, but mounting failed: %s", args[1], err)
+			}
+			log.Infof("The disk %s mounted to %s", args[1], d.Mount)
+		}
+
+		log.Infof("The disk %s attached to compute instance %s", args[1], instance.Name)
+	},
+}
+
+var vmDetachDisk = &cobra.Command{
+	Use:   "detach-disk <name|id> <disk-name|id>",
+	Short: "Detach a disk from a compute instance",
+	Long: `Detach a disk from a compute instance.
+
+Unmount the disk in the instance first. The disk is kept even if it was attached with auto-delete.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		lst, err := client.ComputeInstanceResolve(ctx, folderId, args[:1], nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		instance := lst[0]
+
+		disk, err := client.ComputeDiskResolve(ctx, folderId, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Detaching disk %s from compute instance %s ...", args[1], instance.Name)
+		op, err := client.ComputeInstanceDetachDisk(ctx, instance.Id, disk.Id)
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("The disk %s detached from compute instance %s", args[1], instance.Name)
+	},
+}
+
+// mountDisk formats and mounts an attached disk over SSH.
+func mountDisk(ctx context.Context, client *yc.Client, instance *compute.Instance, d yc.SecondaryDisk) error {
+	targets, err := sshTargets(ctx, client, []*compute.Instance{instance})
+	if err != nil {
+		return err
+	}
+
+	conn, err := remote.Dial(ctx, targets[0].Config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	code, err := conn.Run(d.MountScript(), nil, os.Stderr, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return fmt.Errorf("mount script exited with code %d", code)
+	}
+
+	return nil
+}
+
+func diskCreateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("name", "", "disk name")
+	cmd.Flags().Int64("size", 0, "size in GiB, defaults to the disk size of the snapshot")
+	cmd.Flags().String("type", yc.DefaultDiskType, "")
+	cmd.Flags().String("snapshot", "", "snapshot name or ID to restore the disk from")
+}
+
+func vmAttachDiskFlags(cmd *cobra.Command) {
+	cmd.Flags().String("device-name", "", "device name of the disk in the instance, required with --mount")
+	cmd.Flags().Bool("auto-delete", false, "delete the disk with the instance")
+	cmd.Flags().String("mount", "", "format unless the disk has a filesystem and mount it to the directory over SSH")
+	cmd.Flags().String("fs", yc.DefaultFilesystem, "filesystem to format the disk with")
+	sshFlags(cmd)
+}
@@ -0,0 +1,267 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"os"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// DNS represents the dns command
+func DNS() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dns",
+		Short: "Manage Cloud DNS zones and the records of compute instances",
+	}
+
+	zone := &cobra.Command{
+		Use:   "zone",
+		Short: "Manage Cloud DNS zones",
+	}
+	dnsZoneEnsure.Flags().String("name", "", "zone name (default the domain with dots replaced by dashes)")
+	dnsZoneEnsure.Flags().StringSlice("network", nil, "networks (name or ID) a private zone is visible in")
+	dnsZoneEnsure.Flags().Bool("public", false, "create a public zone")
+	dnsZoneEnsure.MarkFlagsMutuallyExclusive("network", "public")
+	zone.AddCommand(
+		dnsZoneEnsure,
+		dnsZoneList,
+		dnsZoneRecords,
+	)
+
+	cmd.AddCommand(
+		zone,
+		dnsSync,
+	)
+
+	return cmd
+}
+
+var dnsZoneEnsure = &cobra.Command{
+	Use:   "ensure <domain>",
+	Short: "Create a DNS zone unless the folder has one for the domain",
+	Long: `Create a DNS zone unless the folder has one for the domain.
+
+The zone is private to the --network networks, or public with --public.
+Records of compute instances are registered in it with vm create --dns-zone.`,
+	Example: "  ks yc dns zone ensure example.internal --network ks",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		cfg := &yc.DNSZoneConfig{
+			FolderID: folderId,
+			Zone:     args[0],
+			Name:     viper.GetString("name"),
+			Public:   viper.GetBool("public"),
+		}
+		for _, ref := range viper.GetStringSlice("network") {
+			network, err := client.VPCNetworkResolve(ctx, folderId, ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+			cfg.NetworkIDs = append(cfg.NetworkIDs, network.Id)
+		}
+
+		zone, err := client.DNSZoneEnsure(ctx, cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("The DNS zone %s (%s) is ready", zone.Zone, zone.Id)
+	},
+}
+
+var dnsZoneList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List DNS zones",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst, err := client.DNSZoneList(ctx, viper.GetString("folder-id"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.DNSZoneColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var dnsZoneRecords = &cobra.Command{
+	Use:   "records <domain|name|id>",
+	Short: "List the records of a DNS zone",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		zone, err := client.DNSZoneResolve(ctx, viper.GetString("folder-id"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		lst, err := client.DNSRecordList(ctx, zone.Id)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.DNSRecordColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var dnsSync = &cobra.Command{
+	Use:   "sync",
+	Short: "Reconcile the DNS records of the compute instances managed by ks",
+	Long: `Reconcile the DNS records of the compute instances managed by ks.
+
+Running instances created with --dns-zone get A and AAAA records of their current IPs,
+the records of stopped instances are removed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		changes, err := client.DNSSync(ctx, viper.GetString("folder-id"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(changes) == 0 {
+			log.Info("The DNS records are up to date")
+			return
+		}
+
+		yc.FPrintDNSRecordChanges(os.Stdout, changes)
+	},
+}
+
+// dnsZoneFromFlags resolves --dns-zone and labels the instance to be created with it.
+// It returns nil without --dns-zone.
+func dnsZoneFromFlags(ctx context.Context, client *yc.Client, labels map[string]string) *dns.DnsZone {
+	ref := viper.GetString("dns-zone")
+	if len(ref) == 0 {
+		return nil
+	}
+
+	zone, err := client.DNSZoneResolve(ctx, viper.GetString("folder-id"), ref)
+	if err != nil {
+		log.Fatal(err)
+	}
+	labels[common.LabelDNSZoneKey] = zone.Id
+
+	return zone
+}
+
+// updateInstanceDNS sets the records of the instances created with --dns-zone to their current IPs.
+func updateInstanceDNS(ctx context.Context, client *yc.Client, lst []*compute.Instance) {
+	instanceDNS(ctx, client, lst, client.DNSInstanceRecordsUpdate)
+}
+
+// removeInstanceDNS removes the records of the instances created with --dns-zone.
+func removeInstanceDNS(ctx context.Context, client *yc.Client, lst []*compute.Instance) {
+	instanceDNS(ctx, client, lst, client.DNSInstanceRecordsRemove)
+}
+
+// warnInstanceDNS warns that the records of the instances created with --dns-zone are not updated,
+// as the IPs are not known without waiting for the operations.
+func warnInstanceDNS(lst []*compute.Instance) {
+	for _, instance := range lst {
+		if _, ok := instance.Labels[common.LabelDNSZoneKey]; ok {
+			log.Warn("The DNS records are not updated with --no-wait, run `ks yc dns sync` once the instances are running")
+			return
+		}
+	}
+}
+
+// instanceDNS changes the records of the instances in their zones. A failure doesn't fail the command,
+// as the instances are changed already, the records are left for dns sync.
+func instanceDNS(
+	ctx context.Context,
+	client *yc.Client,
+	lst []*compute.Instance,
+	fn func(context.Context, *dns.DnsZone, *compute.Instance) (*operation.Operation, error),
+) {
+	zones := make(map[string]*dns.DnsZone)
+	for _, instance := range lst {
+		id, ok := instance.Labels[common.LabelDNSZoneKey]
+		if !ok {
+			continue
+		}
+
+		err := func() error {
+			zone, ok := zones[id]
+			if !ok {
+				var err error
+				if zone, err = client.DNSZoneGet(ctx, id); err != nil {
+					return err
+				}
+				zones[id] = zone
+			}
+
+			op, err := fn(ctx, zone, instance)
+			if err == nil && op == nil {
+				return nil
+			}
+			if err = waitOperation(ctx, op, err); err != nil {
+				return err
+			}
+			log.Infof("The DNS records of %s updated", yc.InstanceRecordName(instance, zone))
+			return nil
+		}()
+		if err != nil {
+			log.Warnf("%s: DNS records not updated, run ks yc dns sync: %s", instance.Name, err)
+		}
+	}
+}
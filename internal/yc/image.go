@@ -0,0 +1,322 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/remote"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+
+	"github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// bakeCleanupTimeout limits deleting the temporary instance of a bake, which may run after --timeout.
+const bakeCleanupTimeout = 5 * time.Minute
+
+// Image represents the image command
+func Image() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "image",
+		Short: "Manage compute images",
+	}
+
+	imageList.Flags().String("family", "", "show images of the family only")
+	imageBakeFlags(imageBake)
+
+	cmd.AddCommand(
+		imageBake,
+		imageList,
+	)
+
+	return cmd
+}
+
+var imageList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of images in the folder given by --image-folder",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst, err := client.ComputeImageList(ctx, viper.GetString("image-folder"), viper.GetString("family"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.ImageColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var imageBake = &cobra.Command{
+	Use:   "bake --name <image> --script <file>...",
+	Short: "Bake an image by provisioning a temporary compute instance",
+	Long: `Bake an image by provisioning a temporary compute instance.
+
+The instance is created from the latest image of --from-family, or from --from-image.
+Once cloud-init has finished, the scripts are run in order over SSH with sudo, the instance
+is stopped and the image is created from its boot disk. The temporary instance is deleted
+in any case, also if baking fails or is interrupted.
+
+The image is labeled with the hash of its recipe: the source image, the disk size and the
+content of the scripts. Baking is skipped if an image with the same recipe exists in the folder.
+Creating an image takes a while, raise --timeout, e.g. -t 30m.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Interrupting cancels the context, so the temporary instance is still deleted.
+		sctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		ctx, cancel := context.WithTimeout(sctx, viper.GetDuration("timeout"))
+		defer cancel()
+
+		recipe := yc.BakeRecipe{
+			SourceImageID: viper.GetString("from-image"),
+			DiskSizeGib:   viper.GetInt64("disk-size"),
+		}
+		if len(recipe.SourceImageID) == 0 {
+			family := viper.GetString("from-family")
+			if len(family) == 0 {
+				family = viper.GetString("image-family")
+			}
+			source, err := client.ComputeImageLatestByFamily(ctx, viper.GetString("image-folder"), family)
+			if err != nil {
+				log.Fatal(err)
+			}
+			recipe.SourceImageID = source.Id
+			log.Infof("Baking from image %s (%s)", source.Name, source.Id)
+		}
+
+		for _, file := range viper.GetStringSlice("script") {
+			path, err := homedir.Expand(file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			b, err := os.ReadFile(path)
+			if err != nil {
+				log.Fatal(err)
+			}
+			recipe.Scripts = append(recipe.Scripts, yc.BakeScript{Name: filepath.Base(path), Content: b})
+		}
+
+		folderId := viper.GetString("folder-id")
+		name := viper.GetString("name")
+		hash := recipe.Hash()
+
+		images, err := client.ComputeImageList(ctx, folderId, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, image := range images {
+			sameRecipe := image.Labels[common.LabelImageRecipeKey] == hash
+			if sameRecipe && image.Status == compute.Image_READY && !viper.GetBool("force") {
+				log.Infof("The image %s (%s) has the same recipe, skipping", image.Name, image.Id)
+				return
+			}
+			if image.Name == name {
+				log.Fatalf("The image %s exists already, delete it or choose another --name", name)
+			}
+		}
+
+		image, err := bakeImage(ctx, client, recipe)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("The image %s (%s) baked", image.Name, image.Id)
+	},
+}
+
+// bakeImage provisions a temporary instance with the recipe and creates the image from its boot disk.
+// The instance is deleted before returning.
+func bakeImage(ctx context.Context, client *yc.Client, recipe yc.BakeRecipe) (*compute.Image, error) {
+	hash := recipe.Hash()
+	labels := checkLabels(map[string]string{common.LabelImageRecipeKey: hash})
+
+	usr := viper.GetString("user")
+	if len(usr) == 0 {
+		current, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		usr = current.Username
+	}
+
+	cfg := &yc.ComputeInstanceConfig{
+		Name:              "ks-bake-" + hash[:8],
+		PlatformID:        viper.GetString("platform-id"),
+		FolderID:          viper.GetString("folder-id"),
+		SubnetID:          viper.GetString("subnet-id"),
+		Zone:              viper.GetString("zone"),
+		DiskType:          viper.GetString("disk-type"),
+		DiskID:            recipe.SourceImageID,
+		DiskSize:          uint(recipe.DiskSizeGib),
+		User:              usr,
+		SshPublicKeyFiles: viper.GetStringSlice("ssh-pub"),
+		Labels:            labels,
+	}
+	if err := cfg.SetUserData(""); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Creating temporary compute instance %s ...", cfg.Name)
+	op, err := client.ComputeInstanceCreate(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := op.Metadata()
+	if err != nil {
+		return nil, err
+	}
+	id := meta.(*compute.CreateInstanceMetadata).InstanceId
+
+	defer func() {
+		cctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), bakeCleanupTimeout)
+		defer cancel()
+
+		log.Infof("Deleting temporary compute instance %s ...", cfg.Name)
+		op, err := client.ComputeInstanceDelete(cctx, id)
+		if err = waitOperation(cctx, op, err); err != nil {
+			log.Errorf("Deleting temporary compute instance %s (%s) failed: %s", cfg.Name, id, err)
+		}
+	}()
+
+	if err = op.Wait(ctx); err != nil {
+		return nil, err
+	}
+	instance, err := client.ComputeInstanceWaitStatus(ctx, id, compute.Instance_RUNNING)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = provision(ctx, client, instance, recipe.Scripts); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Stopping temporary compute instance %s ...", cfg.Name)
+	op, err = client.ComputeInstanceStop(ctx, id)
+	if err = waitOperation(ctx, op, err); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Creating image %s ...", viper.GetString("name"))
+	op, err = client.ComputeImageCreate(ctx, &yc.ImageConfig{
+		FolderID:    cfg.FolderID,
+		Name:        viper.GetString("name"),
+		Family:      viper.GetString("family"),
+		Description: "Baked by ks from image " + recipe.SourceImageID,
+		DiskID:      instance.GetBootDisk().GetDiskId(),
+		Labels:      labels,
+	})
+	if err = waitOperation(ctx, op, err); err != nil {
+		return nil, err
+	}
+
+	resp, err := op.Response()
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.(*compute.Image), nil
+}
+
+// provision runs the scripts on the instance once cloud-init has finished,
+// then cleans the cloud-init state, so that it runs again on instances created from the image.
+func provision(ctx context.Context, client *yc.Client, instance *compute.Instance, scripts []yc.BakeScript) error {
+	dialer, err := newSshDialer(ctx, client)
+	if err != nil {
+		return err
+	}
+	cfg, err := dialer.config(instance)
+	if err != nil {
+		return err
+	}
+
+	conn, err := remote.DialWait(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	log.Infof("Waiting for cloud-init on %s ...", instance.Name)
+	if err = conn.WaitCloudInit(ctx); err != nil {
+		return err
+	}
+
+	for _, s := range scripts {
+		log.Infof("Running %s ...", s.Name)
+		code, err := conn.Run("sudo -H bash -s", bytes.NewReader(s.Content), os.Stdout, os.Stderr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+		if code != 0 {
+			return fmt.Errorf("%s exited with code %d", s.Name, code)
+		}
+	}
+
+	code, err := conn.Run("sudo cloud-init clean --logs", nil, os.Stdout, os.Stderr)
+	if err == nil && code != 0 {
+		err = fmt.Errorf("cloud-init clean exited with code %d", code)
+	}
+
+	return err
+}
+
+func imageBakeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("name", "", "name of the image")
+	_ = cmd.MarkFlagRequired("name")
+	cmd.Flags().String("family", "", "family of the image")
+	cmd.Flags().String("from-family", "", "family of the source image in --image-folder (default --image-family)")
+	cmd.Flags().String("from-image", "", "source image ID, overrides --from-family")
+	cmd.Flags().StringArray("script", nil, "provisioning script, run as root; repeat to run several in order")
+	_ = cmd.MarkFlagRequired("script")
+	cmd.Flags().Bool("force", false, "bake even if an image with the same recipe exists")
+	cmd.Flags().String("platform-id", yc.DefaultPlatformID, "")
+	cmd.Flags().String("disk-type", yc.DefaultDiskType, "")
+	cmd.Flags().Int64("disk-size", yc.DefaultDiskSizeGib, "")
+	sshFlags(cmd)
+}
@@ -0,0 +1,362 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"os"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	"github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// igUnsupportedFlags are the create flags of a single instance which don't apply to the instances of a group.
+var igUnsupportedFlags = []string{"address", "reserve-address", "dns-zone", "wait-for", "serial"}
+
+// InstanceGroup represents the instance-group command
+func InstanceGroup() *cobra.Command {
+	cmd := &cobra.Command{
+		Aliases: []string{"ig"},
+		Use:     "instance-group",
+		Short:   "Manage instance groups",
+	}
+
+	computeCreateFlags(igCreate)
+	securityGroupFlags(igCreate, nil)
+	for _, name := range igUnsupportedFlags {
+		_ = igCreate.Flags().MarkHidden(name)
+	}
+	igScaleFlags(igCreate)
+	igCreate.Flags().StringSlice("zones", nil, "zones to spread the instances over (default --zone)")
+	igCreate.Flags().Int64("max-unavailable", 1, "instances that may be stopped at once while the group is updated")
+	igCreate.Flags().Int64("max-expansion", 0, "instances that may be created above the target size while the group is updated")
+	igCreate.Flags().String("group-sa", "", "service account name the group manages its instances with (default --sa)")
+	igScaleFlags(igScale)
+	igList.Flags().Bool("all", false, "show all instance groups")
+	noWait(igDelete)
+	parallel(igDelete)
+
+	cmd.AddCommand(
+		igCreate,
+		igDelete,
+		igGet,
+		igInstances,
+		igList,
+		igScale,
+	)
+
+	return cmd
+}
+
+var igCreate = &cobra.Command{
+	Use:   "create",
+	Short: "Create an instance group",
+	Long: `Create an instance group.
+
+The instances are created from the same flags as by vm create and named <name>-<index>.
+A fixed number of instances is kept with --size, with --max-size the group is scaled
+by the CPU utilization starting with --size instances.
+Interfaces without a subnet get the subnet of every zone of --zones.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range igUnsupportedFlags {
+			if cmd.Flags().Changed(name) {
+				log.Fatalf("--%s is not supported by instance groups", name)
+			}
+		}
+
+		var config *yc.ComputeInstanceConfig
+		if err := viper.Unmarshal(&config); err != nil {
+			log.Fatal(err)
+		}
+		if len(config.Name) == 0 {
+			log.Fatal("--name required")
+		}
+
+		config.Labels = checkLabels(config.Labels)
+
+		tpl, err := readUserDataFile(config.UserDataFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = config.SetUserData(tpl); err != nil {
+			log.Fatal(err)
+		}
+
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		size, auto := igScaleFromFlags()
+		op, err := client.ComputeInstanceGroupCreate(ctx, &yc.InstanceGroupConfig{
+			Instance:       config,
+			Zones:          viper.GetStringSlice("zones"),
+			Size:           size,
+			AutoScale:      auto,
+			MaxUnavailable: viper.GetInt64("max-unavailable"),
+			MaxExpansion:   viper.GetInt64("max-expansion"),
+			ServiceAccount: viper.GetString("group-sa"),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		meta, err := op.Metadata()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Creating instance group %s ...", meta.(*instancegroup.CreateInstanceGroupMetadata).InstanceGroupId)
+
+		if err = op.Wait(ctx); err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := op.Response()
+		if err != nil {
+			log.Fatal(err)
+		}
+		group := resp.(*instancegroup.InstanceGroup)
+
+		log.Infof("The instance group %s (%s) created", group.Name, group.Id)
+	},
+}
+
+var igDelete = &cobra.Command{
+	Aliases: []string{"rm", "del"},
+	Use:     "delete <name|id>...",
+	Short:   "Delete instance groups with their instances",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		tasks := make([]yc.BulkTask, 0, len(args))
+		for _, ref := range args {
+			group, err := client.ComputeInstanceGroupResolve(ctx, viper.GetString("folder-id"), ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			id := group.Id
+			tasks = append(tasks, yc.BulkTask{
+				ID:   id,
+				Name: group.Name,
+				Run: func(ctx context.Context) (*operation.Operation, error) {
+					return client.ComputeInstanceGroupDelete(ctx, id)
+				},
+			})
+		}
+
+		exitBulk(runBulk(ctx, "Deleting", tasks))
+	},
+}
+
+var igGet = &cobra.Command{
+	Aliases: []string{"describe"},
+	Use:     "get <name|id>",
+	Short:   "Show details of an instance group",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		ref, err := client.ComputeInstanceGroupResolve(ctx, viper.GetString("folder-id"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		group, err := client.ComputeInstanceGroupGet(ctx, ref.Id)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintItem(os.Stdout, newPrinter(), group, yc.InstanceGroupColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var igInstances = &cobra.Command{
+	Use:   "instances <name|id>",
+	Short: "List the instances of an instance group",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		group, err := client.ComputeInstanceGroupResolve(ctx, viper.GetString("folder-id"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		lst, err := client.ComputeInstanceGroupInstances(ctx, group.Id)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.ManagedInstanceColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var igList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of instance groups",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		var lbl map[string]string
+		if !viper.GetBool("all") {
+			lbl = map[string]string{common.ManagedKey: yc.KsToolKey}
+		}
+
+		lst, err := client.ComputeInstanceGroupList(ctx, viper.GetString("folder-id"), lbl)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.InstanceGroupColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var igScale = &cobra.Command{
+	Use:   "scale <name|id>",
+	Short: "Change the scale policy of an instance group",
+	Long: `Change the scale policy of an instance group.
+
+The group keeps --size instances, or is scaled by the CPU utilization with --max-size.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed("size") && !cmd.Flags().Changed("max-size") {
+			log.Fatal("--size or --max-size required")
+		}
+
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		group, err := client.ComputeInstanceGroupResolve(ctx, viper.GetString("folder-id"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		size, auto := igScaleFromFlags()
+		op, err := client.ComputeInstanceGroupScale(ctx, group.Id, yc.InstanceGroupScalePolicy(size, auto))
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("The instance group %s scaled", group.Name)
+	},
+}
+
+func igScaleFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64("size", 1, "number of instances, the initial one with --max-size")
+	cmd.Flags().Int64("max-size", 0, "scale the group by the CPU utilization up to the number of instances")
+	cmd.Flags().Int64("min-zone-size", 0, "minimum number of instances in every zone with --max-size")
+	cmd.Flags().Float64("cpu-target", 75, "average CPU utilization in percent to scale to with --max-size")
+}
+
+// igScaleFromFlags returns the size of a fixed scale group, or the auto scale of --max-size.
+func igScaleFromFlags() (int64, *yc.AutoScale) {
+	size := viper.GetInt64("size")
+	maxSize := viper.GetInt64("max-size")
+	if maxSize == 0 {
+		return size, nil
+	}
+
+	return size, &yc.AutoScale{
+		MinZoneSize: viper.GetInt64("min-zone-size"),
+		MaxSize:     maxSize,
+		InitialSize: size,
+		CPUTarget:   viper.GetFloat64("cpu-target"),
+	}
+}
+
+// readUserDataFile returns the user-data template from the file, empty if no file is given.
+func readUserDataFile(file string) (string, error) {
+	if len(file) == 0 {
+		return "", nil
+	}
+
+	file, err := homedir.Expand(file)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -38,10 +38,14 @@ func K8s() *cobra.Command {
 	}
 
 	computeCreateFlags(clusterCreate)
+	securityGroupFlags(clusterCreate, yc.ClusterSecurityGroupPresets)
 	noWait(clusterCreate)
 	noWait(clusterDelete)
 	noWait(clusterStart)
 	noWait(clusterStop)
+	parallel(clusterDelete)
+	parallel(clusterStart)
+	parallel(clusterStop)
 	clusterScaleFlags(clusterScale)
 	noWait(clusterScale)
 
@@ -61,6 +65,9 @@ func K8s() *cobra.Command {
 var clusterCreate = &cobra.Command{
 	Use:   "create",
 	Short: "Create a Kubernetes cluster",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		var config *yc.ComputeInstanceConfig
 		if err := viper.Unmarshal(&config); err != nil {
@@ -82,11 +89,14 @@ var clusterCreate = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		config.Labels = map[string]string{
+		config.Labels = checkLabels(map[string]string{
 			common.LabelNodeRoleControlPlane: "",
-		}
+			common.LabelClusterNameKey:       config.Name,
+		})
+
+		cond := waitConditionFromFlags("wait-for")
 
-		client, err := yc.NewClient(viper.GetString("token"))
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -94,6 +104,8 @@ var clusterCreate = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
 		defer cancel()
 
+		zone := dnsZoneFromFlags(ctx, client, config.Labels)
+
 		op, err := client.ComputeInstanceCreate(ctx, config)
 		if err != nil {
 			log.Fatal(err)
@@ -119,14 +131,35 @@ var clusterCreate = &cobra.Command{
 		ip := yc.GetIPv4(instance).External()
 
 		log.Infof("The Kubernetes cluster %s (%s) created", instance.Name, ip)
+
+		if viper.GetBool("reserve-address") {
+			if err = reserveAddresses(ctx, client, instance); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if zone != nil {
+			updateInstanceDNS(ctx, client, []*compute.Instance{instance})
+		}
+
+		if cond != nil {
+			if err = newWaiter(ctx, client, *cond).wait(ctx, instance.Id); err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("The Kubernetes cluster %s is bootstrapped", instance.Name)
+		}
 	},
 }
 
 var clusterDelete = &cobra.Command{
-	Use:   "delete <cluster-id>",
+	Use:   "delete <cluster-name>",
 	Short: "Delete a Kubernetes cluster",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		/*client, err := yc.NewClient(viper.GetString("token"))
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -134,8 +167,13 @@ var clusterDelete = &cobra.Command{
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
 		defer cancel()
 
-		folderId := viper.GetString("folder-id")
-		client.ComputeInstanceList(ctx, folderId, map[string]string{""})*/
+		lst := clusterInstances(ctx, client, args[0])
+		results, failed := runBulk(ctx, "Deleting", yc.ComputeInstanceTasks(lst, client.ComputeInstanceDelete))
+		removeInstanceDNS(ctx, client, succeededInstances(lst, results))
+		if failed == 0 && !viper.GetBool("no-wait") {
+			deleteClusterSecurityGroups(ctx, client, args[0])
+		}
+		exitBulk(results, failed)
 	},
 }
 
@@ -143,7 +181,7 @@ var clusterGet = &cobra.Command{
 	Use:   "get [cluster-id, ...]",
 	Short: "Get a Kubernetes cluster info",
 	Run: func(cmd *cobra.Command, args []string) {
-		/*client, err := yc.NewClient(viper.GetString("token"))
+		/*client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -157,7 +195,7 @@ var clusterList = &cobra.Command{
 	Use:   "list",
 	Short: "List a Kubernetes clusters",
 	Run: func(cmd *cobra.Command, args []string) {
-		/*client, err := yc.NewClient(viper.GetString("token"))
+		/*client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -170,35 +208,63 @@ var clusterList = &cobra.Command{
 			log.Fatal(err)
 		}
 
-		yc.FPrintComputeList(os.Stdout, lst)*/
+		yc.FPrintComputeList(os.Stdout, newPrinter(), lst)*/
 	},
 }
 
 var clusterStart = &cobra.Command{
-	Use:   "start <cluster-id>",
+	Use:   "start <cluster-name>",
 	Short: "Start a Kubernetes cluster",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		/*client, err := yc.NewClient(viper.GetString("token"))
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
-		defer cancel()*/
+		defer cancel()
+
+		lst := clusterInstances(ctx, client, args[0])
+		results, failed := runBulk(ctx, "Starting", yc.ComputeInstanceTasks(lst, client.ComputeInstanceStart))
+		var started []*compute.Instance
+		for _, res := range results {
+			if instance, ok := res.Response.(*compute.Instance); ok {
+				started = append(started, instance)
+			}
+		}
+		if viper.GetBool("no-wait") {
+			warnInstanceDNS(succeededInstances(lst, results))
+		} else {
+			updateInstanceDNS(ctx, client, started)
+		}
+		exitBulk(results, failed)
 	},
 }
 
 var clusterStop = &cobra.Command{
-	Use:   "stop <cluster-id>",
+	Use:   "stop <cluster-name>",
 	Short: "Stop a Kubernetes cluster",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		/*client, err := yc.NewClient(viper.GetString("token"))
+		client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
-		defer cancel()*/
+		defer cancel()
+
+		lst := clusterInstances(ctx, client, args[0])
+		results, failed := runBulk(ctx, "Stopping", yc.ComputeInstanceTasks(lst, client.ComputeInstanceStop))
+		removeInstanceDNS(ctx, client, succeededInstances(lst, results))
+		exitBulk(results, failed)
 	},
 }
 
@@ -206,7 +272,7 @@ var clusterScale = &cobra.Command{
 	Use:   "scale <cluster-id>",
 	Short: "Scale Kubernetes workers",
 	Run: func(cmd *cobra.Command, args []string) {
-		/*client, err := yc.NewClient(viper.GetString("token"))
+		/*client, err := newClient(cmd.Context())
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -220,3 +286,44 @@ func clusterScaleFlags(cmd *cobra.Command) {
 	cmd.Flags().Uint("replicas", 0, "number of worker node replicas")
 	cmd.Flags().StringSlice("delete-node", nil, "scale down and delete specific node")
 }
+
+// clusterInstances returns the compute instances of the named cluster.
+func clusterInstances(ctx context.Context, client *yc.Client, name string) []*compute.Instance {
+	lst, err := client.ComputeInstanceSelect(ctx, viper.GetString("folder-id"), yc.Selector{
+		Labels: []yc.Requirement{
+			{Key: common.LabelClusterNameKey, Operator: yc.SelectorEq, Values: []string{name}},
+			{Key: common.ManagedKey, Operator: yc.SelectorEq, Values: []string{yc.KsToolKey}},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(lst) == 0 {
+		log.Fatalf("Kubernetes cluster %q not found", name)
+	}
+
+	return lst
+}
+
+// deleteClusterSecurityGroups deletes the preset groups of the cluster. A failure doesn't fail the command,
+// as the instances are deleted already.
+func deleteClusterSecurityGroups(ctx context.Context, client *yc.Client, name string) {
+	lst, err := client.VPCSecurityGroupList(ctx, viper.GetString("folder-id"))
+	if err != nil {
+		log.Warnf("The security groups of cluster %s are not deleted: %v", name, err)
+		return
+	}
+
+	for _, group := range lst {
+		if group.Labels[common.LabelClusterNameKey] != name || group.Labels[common.ManagedKey] != yc.KsToolKey {
+			continue
+		}
+
+		op, err := client.VPCSecurityGroupDelete(ctx, group.Id)
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Warnf("The security group %s is not deleted: %v", group.Name, err)
+			continue
+		}
+		log.Infof("The security group %s deleted", group.Name)
+	}
+}
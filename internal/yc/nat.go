@@ -0,0 +1,87 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+
+	"github.com/ks-tool/ks/pkg/yc"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NATGateway represents the nat-gateway command
+func NATGateway() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nat-gateway",
+		Short: "Manage the NAT gateway giving instances without an external IP access to the internet",
+	}
+
+	cmd.AddCommand(
+		natGatewayEnsure,
+	)
+
+	return cmd
+}
+
+var natGatewayEnsure = &cobra.Command{
+	Use:   "ensure [<subnet name|id>...]",
+	Short: "Route the egress traffic of subnets through the NAT gateway",
+	Long: `Route the egress traffic of subnets through the NAT gateway.
+
+The NAT gateway ` + yc.NATGatewayName + ` of the folder and a route table of the network with
+the default route through it are created if needed, and the route table is set for the subnets.
+A subnet with its own route table gets the default route added to it, unless it has one already.
+Without arguments the subnet of --subnet-id is used.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			subnetID := viper.GetString("subnet-id")
+			if len(subnetID) == 0 {
+				log.Fatal("subnet required, as an argument or with --subnet-id")
+			}
+			args = []string{subnetID}
+		}
+
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		for _, ref := range args {
+			subnet, err := client.VPCSubnetResolve(ctx, folderId, ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			nat, err := client.VPCNATGatewayEnsure(ctx, folderId, subnet.Id)
+			if err != nil {
+				log.Fatalf("%s: %s", subnet.Name, err)
+			}
+			log.Infof("The subnet %s routes egress traffic through NAT gateway %s (route table %s)",
+				subnet.Name, nat.Gateway.Name, nat.RouteTable.Name)
+		}
+	},
+}
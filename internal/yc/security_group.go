@@ -0,0 +1,349 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// SecurityGroup represents the security-group command
+func SecurityGroup() *cobra.Command {
+	cmd := &cobra.Command{
+		Aliases: []string{"sg"},
+		Use:     "security-group",
+		Short:   "Manage VPC security groups",
+	}
+
+	securityGroupCreate.Flags().String("name", "", "security group name (default the name of the preset group)")
+	securityGroupCreate.Flags().String("network", "", "network name or ID")
+	securityGroupCreate.Flags().String("description", "", "")
+	securityGroupCreate.Flags().String("preset", "", "create the group with the rules of the preset: "+yc.SecurityGroupPresetNames())
+	securityGroupCreate.Flags().String("cluster", "", "cluster of the preset group, required for the presets with self rules")
+	securityGroupCreate.Flags().Bool("prune", false, "delete the rules of the existing preset group that are not in the preset")
+	_ = securityGroupCreate.MarkFlagRequired("network")
+	securityGroupList.Flags().String("network", "", "show security groups of the network only")
+	noWait(securityGroupDelete)
+	parallel(securityGroupDelete)
+
+	securityGroupAddRule.Flags().String("direction", "ingress", "ingress or egress")
+	securityGroupAddRule.Flags().String("protocol", "tcp", "tcp, udp, icmp or any")
+	securityGroupAddRule.Flags().String("port", "", "port or port range, e.g. 443 or 8000-8080 (default any)")
+	securityGroupAddRule.Flags().StringSlice("cidr", nil, "IPv4 CIDRs the traffic comes from or goes to")
+	securityGroupAddRule.Flags().Bool("self", false, "allow traffic between the instances of the group")
+	securityGroupAddRule.Flags().String("description", "", "")
+	securityGroupAddRule.MarkFlagsMutuallyExclusive("cidr", "self")
+	securityGroupAddRule.MarkFlagsOneRequired("cidr", "self")
+
+	cmd.AddCommand(
+		securityGroupAddRule,
+		securityGroupCreate,
+		securityGroupDelete,
+		securityGroupGet,
+		securityGroupList,
+	)
+
+	return cmd
+}
+
+var securityGroupCreate = &cobra.Command{
+	Use:   "create",
+	Short: "Create a security group",
+	Long: `Create a security group.
+
+With --preset and without --name the group of the preset in the network is created,
+or the missing rules of the preset are added to it if it exists. This is the group
+vm create --security-group-preset attaches. With --prune the other rules of the group,
+e.g. added with add-rule, are deleted.
+
+The presets with self rules have a group per cluster, given with --cluster, so the
+nodes of clusters sharing a network are isolated. vm create uses the ks-tool.dev/cluster
+label of the instance as the cluster, or the name of the instance.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		network, err := client.VPCNetworkResolve(ctx, folderId, viper.GetString("network"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		name, preset := viper.GetString("name"), viper.GetString("preset")
+		var rules []yc.SecurityGroupRule
+		if len(preset) > 0 {
+			var ok bool
+			if rules, ok = yc.SecurityGroupPresets[preset]; !ok {
+				log.Fatalf("unknown preset %q, allow: %s", preset, yc.SecurityGroupPresetNames())
+			}
+		}
+
+		if (viper.GetBool("prune") || len(viper.GetString("cluster")) > 0) && (len(name) > 0 || len(preset) == 0) {
+			log.Fatal("--prune and --cluster apply to --preset without --name only")
+		}
+
+		var group *vpc.SecurityGroup
+		if len(name) == 0 {
+			if len(preset) == 0 {
+				log.Fatal("--name or --preset required")
+			}
+			if group, err = client.VPCSecurityGroupEnsurePreset(ctx, &yc.PresetSecurityGroupConfig{
+				FolderID:  folderId,
+				NetworkID: network.Id,
+				Preset:    preset,
+				Cluster:   viper.GetString("cluster"),
+				Prune:     viper.GetBool("prune"),
+			}); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			op, err := client.VPCSecurityGroupCreate(ctx, &yc.SecurityGroupConfig{
+				FolderID:    folderId,
+				NetworkID:   network.Id,
+				Name:        name,
+				Description: viper.GetString("description"),
+				Labels:      checkLabels(nil),
+				Rules:       rules,
+			})
+			if err = waitOperation(ctx, op, err); err != nil {
+				log.Fatal(err)
+			}
+
+			resp, err := op.Response()
+			if err != nil {
+				log.Fatal(err)
+			}
+			group = resp.(*vpc.SecurityGroup)
+		}
+
+		log.Infof("The security group %s (%s) is ready with %d rules", group.Name, group.Id, len(group.Rules))
+	},
+}
+
+var securityGroupDelete = &cobra.Command{
+	Aliases: []string{"rm", "del"},
+	Use:     "delete <name|id>...",
+	Short:   "Delete security groups",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		tasks := make([]yc.BulkTask, 0, len(args))
+		for _, ref := range args {
+			group, err := client.VPCSecurityGroupResolve(ctx, viper.GetString("folder-id"), ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if group.DefaultForNetwork {
+				log.Fatalf("The security group %s is the default one of network %s and is deleted with it", ref, group.NetworkId)
+			}
+
+			id := group.Id
+			tasks = append(tasks, yc.BulkTask{
+				ID:   id,
+				Name: group.Name,
+				Run: func(ctx context.Context) (*operation.Operation, error) {
+					return client.VPCSecurityGroupDelete(ctx, id)
+				},
+			})
+		}
+
+		exitBulk(runBulk(ctx, "Deleting", tasks))
+	},
+}
+
+var securityGroupGet = &cobra.Command{
+	Aliases: []string{"describe"},
+	Use:     "get <name|id>",
+	Short:   "Show the rules of a security group",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		group, err := client.VPCSecurityGroupResolve(ctx, viper.GetString("folder-id"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		p := newPrinter()
+		if p.Format == yc.OutputJSON || p.Format == yc.OutputYAML {
+			err = yc.PrintItem(os.Stdout, p, group, yc.SecurityGroupColumns)
+		} else {
+			err = yc.PrintList(os.Stdout, p, group.Rules, yc.SecurityGroupRuleColumns)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var securityGroupList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of security groups",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		lst, err := client.VPCSecurityGroupList(ctx, folderId)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if ref := viper.GetString("network"); len(ref) > 0 {
+			network, err := client.VPCNetworkResolve(ctx, folderId, ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var filtered []*vpc.SecurityGroup
+			for _, group := range lst {
+				if group.NetworkId == network.Id {
+					filtered = append(filtered, group)
+				}
+			}
+			lst = filtered
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.SecurityGroupColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var securityGroupAddRule = &cobra.Command{
+	Use:   "add-rule <name|id>",
+	Short: "Add a rule to a security group",
+	Long: `Add a rule to a security group.
+
+Nothing is done if the group has a rule allowing the same traffic.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rule, err := ruleFromFlags()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		group, err := client.VPCSecurityGroupResolve(ctx, viper.GetString("folder-id"), args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		op, err := client.VPCSecurityGroupAddRules(ctx, group, []yc.SecurityGroupRule{rule})
+		if err == nil && op == nil {
+			log.Infof("The security group %s already allows %s", group.Name, rule)
+			return
+		}
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("The rule %s added to security group %s", rule, group.Name)
+	},
+}
+
+func ruleFromFlags() (yc.SecurityGroupRule, error) {
+	rule := yc.SecurityGroupRule{
+		Description: viper.GetString("description"),
+		Protocol:    strings.ToLower(viper.GetString("protocol")),
+		CIDRs:       viper.GetStringSlice("cidr"),
+		Self:        viper.GetBool("self"),
+	}
+
+	switch dir := viper.GetString("direction"); dir {
+	case "ingress":
+		rule.Direction = vpc.SecurityGroupRule_INGRESS
+	case "egress":
+		rule.Direction = vpc.SecurityGroupRule_EGRESS
+	default:
+		return rule, fmt.Errorf("invalid direction %q, allow: ingress, egress", dir)
+	}
+
+	switch rule.Protocol {
+	case "tcp", "udp", "icmp", "any":
+	default:
+		return rule, fmt.Errorf("invalid protocol %q, allow: tcp, udp, icmp, any", rule.Protocol)
+	}
+
+	var err error
+	rule.FromPort, rule.ToPort, err = yc.ParsePortRange(viper.GetString("port"))
+	if err == nil && rule.FromPort > 0 && (rule.Protocol == "icmp" || rule.Protocol == "any") {
+		err = fmt.Errorf("ports are allowed with tcp and udp only")
+	}
+
+	return rule, err
+}
+
+func securityGroupFlags(cmd *cobra.Command, presets []string) {
+	cmd.Flags().StringSlice("security-group", nil, "attach the security groups, names or IDs")
+	cmd.Flags().StringSlice("security-group-preset", presets,
+		"attach the groups of the presets, creating or updating them in the network: "+yc.SecurityGroupPresetNames())
+}
@@ -0,0 +1,411 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ks-tool/ks/pkg/config"
+	"github.com/ks-tool/ks/pkg/remote"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+)
+
+var vmSsh = &cobra.Command{
+	Use:   "ssh <name|id> [-- command...]",
+	Short: "Connect to a compute instance over SSH",
+	Long: `Connect to a compute instance over SSH.
+
+The external IP of the instance is used, or the internal one with --internal or if it has none.
+The internal IP is reached through the --bastion instance if it is set, e.g. in the context.
+The bastion is given as <name|id>[:port], its port defaults to 22.
+The user defaults to the one created by the user-data of the instance.
+Host keys are trusted on first use and stored in ~/.ks/known_hosts.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst, err := client.ComputeInstanceResolve(ctx, viper.GetString("folder-id"), args[:1], nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		targets, err := sshTargets(ctx, client, lst)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		conn, err := remote.Dial(ctx, targets[0].Config)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		code, err := conn.Shell(strings.Join(args[1:], " "), os.Stdin, os.Stdout, os.Stderr)
+		_ = conn.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		os.Exit(code)
+	},
+}
+
+var vmExec = &cobra.Command{
+	Use:   "exec <name|id>... | -l <selector> -- <command...>",
+	Short: "Run a command on compute instances over SSH",
+	Long: `Run a command on compute instances over SSH.
+
+The output is streamed with every line prefixed by the instance name, followed by a summary.
+With -o json or -o yaml the output is collected into a report instead.
+The exit code is non-zero if the command failed on any instance.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if dash := cmd.ArgsLenAtDash(); dash < 0 || dash == len(args) {
+			return errors.New("command required after --")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		dash := cmd.ArgsLenAtDash()
+		lst := resolveInstances(ctx, client, args[:dash])
+		targets, err := sshTargets(ctx, client, lst)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		p := newPrinter()
+		opts := remote.ExecOptions{Parallel: viper.GetInt("parallel")}
+		report := p.Format == yc.OutputJSON || p.Format == yc.OutputYAML
+		if !report {
+			opts.Stdout, opts.Stderr = os.Stdout, os.Stderr
+		}
+
+		results := remote.Exec(ctx, targets, strings.Join(args[dash:], " "), opts)
+		if report {
+			err = yc.FPrintExecReport(os.Stdout, p, results)
+		} else {
+			yc.FPrintExecResults(os.Stderr, results)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, res := range results {
+			if res.Err != nil || res.ExitCode != 0 {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+var vmCp = &cobra.Command{
+	Use:   "cp <src> <dst>",
+	Short: "Copy files between the local host and compute instances over SFTP",
+	Long: `Copy files between the local host and compute instances over SFTP.
+
+A remote path is written as <name|id>:<path>. With --selector the name is left out:
+
+  ks yc vm cp app.conf web-1:/tmp/
+  ks yc vm cp -r -l role=web conf/ :/etc/app/
+  ks yc vm cp -l role=web :/var/log/syslog logs/
+
+Files downloaded from several instances are put into a directory per instance.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		src, dst := parseCopyPath(args[0]), parseCopyPath(args[1])
+		if src.remote == dst.remote {
+			log.Fatal("exactly one of the paths must be remote, e.g. web-1:/tmp/")
+		}
+
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		var refs []string
+		if name := src.instance + dst.instance; len(name) > 0 {
+			refs = append(refs, name)
+		}
+		lst := resolveInstances(ctx, client, refs)
+		targets, err := sshTargets(ctx, client, lst)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		view := yc.NewCopyView(os.Stderr)
+		errs := remote.Each(ctx, targets, viper.GetInt("parallel"), func(t remote.Target, c *remote.Client) error {
+			opts := remote.CopyOptions{
+				Recursive: viper.GetBool("recursive"),
+				Progress:  view.For(t.Name),
+			}
+			if dst.remote {
+				return c.Upload(src.path, dst.path, opts)
+			}
+
+			local := dst.path
+			if len(targets) > 1 {
+				local = filepath.Join(local, t.Name) + string(filepath.Separator)
+				if err := os.MkdirAll(local, 0o755); err != nil {
+					return err
+				}
+			}
+
+			return c.Download(src.path, local, opts)
+		})
+		view.Stop()
+
+		var failed bool
+		for n, err := range errs {
+			if err != nil {
+				log.Errorf("%s: %s", targets[n].Name, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+type copyPath struct {
+	instance string
+	path     string
+	remote   bool
+}
+
+// parseCopyPath splits [<name|id>:]<path>. A colon after a slash or of a Windows drive is a part of a local path.
+func parseCopyPath(s string) copyPath {
+	i := strings.Index(s, ":")
+	if i < 0 || strings.ContainsAny(s[:i], `/\`) || len(filepath.VolumeName(s)) > 0 {
+		return copyPath{path: s}
+	}
+
+	p := copyPath{instance: s[:i], path: s[i+1:], remote: true}
+	if len(p.path) == 0 {
+		p.path = "."
+	}
+
+	return p
+}
+
+func sshFlags(cmd *cobra.Command) {
+	cmd.Flags().String("user", "", "login user (default the user from the user-data of the instance)")
+	cmd.Flags().StringSlice("ssh-pub", nil, "public keys whose private keys are used (default ssh-agent and ~/.ssh/id_*)")
+	cmd.Flags().String("jump", "", "connect through the jump host [user@]host[:port]")
+	cmd.Flags().Bool("internal", false, "connect to the internal IP of the instance")
+	cmd.Flags().Int("port", remote.DefaultPort, "SSH port")
+}
+
+// sshDialer builds the SSH configs of compute instances from the ssh flags.
+// The auth methods and the known hosts are shared by all configs.
+type sshDialer struct {
+	agent           *remote.Agent
+	auth            []ssh.AuthMethod
+	hostKeyCallback ssh.HostKeyCallback
+	// bastion is the instance of --bastion, got with its metadata. It is nil with --jump.
+	bastion     *compute.Instance
+	bastionPort int
+}
+
+func newSshDialer(ctx context.Context, client *yc.Client) (*sshDialer, error) {
+	agent, auth, err := remote.AuthMethods(viper.GetStringSlice("ssh-pub"))
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := remote.KnownHosts(filepath.Join(dir, "known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &sshDialer{agent: agent, auth: auth, hostKeyCallback: hostKeyCallback}
+	if bastion := viper.GetString("bastion"); len(bastion) > 0 && len(viper.GetString("jump")) == 0 {
+		ref, port, err := parseBastion(bastion)
+		if err != nil {
+			return nil, err
+		}
+		d.bastionPort = port
+
+		lst, err := client.ComputeInstanceResolve(ctx, viper.GetString("folder-id"), []string{ref}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("bastion: %w", err)
+		}
+		if d.bastion, err = client.ComputeInstanceGet(ctx, lst[0].Id); err != nil {
+			return nil, fmt.Errorf("bastion: %w", err)
+		}
+	}
+
+	return d, nil
+}
+
+// config returns the SSH config of the instance.
+// Without --user the instance must be got with its metadata to find the user.
+// An instance without an external IP, or any with --internal, is reached through the bastion if there is one.
+func (d *sshDialer) config(instance *compute.Instance) (*remote.Config, error) {
+	usr := viper.GetString("user")
+	if len(usr) == 0 {
+		if usr = yc.GetUser(instance); len(usr) == 0 {
+			return nil, fmt.Errorf("no user in the user-data of compute instance %s, set --user", instance.Name)
+		}
+	}
+
+	ip := yc.GetIPv4(instance)
+	internal := viper.GetBool("internal") || !ip.HasExternal()
+	addr := ip.External()
+	if internal {
+		addr = ip.Internal()
+	}
+	if len(addr) == 0 {
+		return nil, fmt.Errorf("compute instance %s has no IP address", instance.Name)
+	}
+
+	port := viper.GetInt("port")
+	if port == 0 {
+		port = remote.DefaultPort
+	}
+
+	cfg := &remote.Config{
+		User:            usr,
+		Addr:            net.JoinHostPort(addr, strconv.Itoa(port)),
+		Agent:           d.agent,
+		Auth:            d.auth,
+		HostKeyCallback: d.hostKeyCallback,
+	}
+
+	if jump := viper.GetString("jump"); len(jump) > 0 {
+		jumpUser, jumpAddr := remote.ParseTarget(jump)
+		if len(jumpUser) == 0 {
+			jumpUser = usr
+		}
+		cfg.Jump = &remote.Config{
+			User:            jumpUser,
+			Addr:            jumpAddr,
+			Agent:           d.agent,
+			Auth:            d.auth,
+			HostKeyCallback: d.hostKeyCallback,
+		}
+	} else if d.bastion != nil && d.bastion.Id != instance.Id && internal {
+		jump, err := d.bastionConfig(usr)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Jump = jump
+	}
+
+	return cfg, nil
+}
+
+// bastionConfig returns the SSH config of the bastion. Its user is the one from its user-data,
+// or the user of the target if it has none.
+func (d *sshDialer) bastionConfig(usr string) (*remote.Config, error) {
+	ip := yc.GetIPv4(d.bastion)
+	if !ip.HasExternal() {
+		return nil, fmt.Errorf("bastion %s has no external IP address", d.bastion.Name)
+	}
+	if u := yc.GetUser(d.bastion); len(u) > 0 {
+		usr = u
+	}
+
+	return &remote.Config{
+		User:            usr,
+		Addr:            net.JoinHostPort(ip.External(), strconv.Itoa(d.bastionPort)),
+		Agent:           d.agent,
+		Auth:            d.auth,
+		HostKeyCallback: d.hostKeyCallback,
+	}, nil
+}
+
+// parseBastion splits the --bastion value "<name|id>[:port]". Names and IDs of instances have no colons.
+func parseBastion(s string) (ref string, port int, err error) {
+	ref, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return s, remote.DefaultPort, nil
+	}
+
+	port, err = strconv.Atoi(p)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("bastion %s: invalid port %q", ref, p)
+	}
+
+	return ref, port, nil
+}
+
+// sshTargets returns the SSH targets of the instances, getting their metadata if needed to find the user.
+func sshTargets(ctx context.Context, client *yc.Client, lst []*compute.Instance) ([]remote.Target, error) {
+	dialer, err := newSshDialer(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]remote.Target, 0, len(lst))
+	for _, instance := range lst {
+		if len(viper.GetString("user")) == 0 {
+			if instance, err = client.ComputeInstanceGet(ctx, instance.Id); err != nil {
+				return nil, err
+			}
+		}
+
+		cfg, err := dialer.config(instance)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, remote.Target{Name: instance.Name, Config: cfg})
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,280 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/remote"
+	"github.com/ks-tool/ks/pkg/yc"
+	"github.com/ks-tool/ks/pkg/yc/fake"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh"
+)
+
+const testFolderID = "b1gtest"
+
+// setSSHHome points the home directory to one with a key in ~/.ssh and no ssh-agent.
+func setSSHHome(t *testing.T) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(home, ".ssh")
+	if err = os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "id_ed25519"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "id_ed25519.pub"), ssh.MarshalAuthorizedKey(signer.PublicKey()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addInstance adds an instance with the IPs and a cloud-config creating the user.
+func addInstance(f *fake.Cloud, name, internal, external, user string) *compute.Instance {
+	addr := &compute.PrimaryAddress{Address: internal}
+	if len(external) > 0 {
+		addr.OneToOneNat = &compute.OneToOneNat{Address: external}
+	}
+
+	i := &compute.Instance{
+		FolderId:          testFolderID,
+		Name:              name,
+		NetworkInterfaces: []*compute.NetworkInterface{{PrimaryV4Address: addr}},
+	}
+	if len(user) > 0 {
+		i.Metadata = map[string]string{common.UserDataKey: "#cloud-config\nusers:\n  - name: " + user + "\n"}
+	}
+
+	return f.AddInstance(i)
+}
+
+func TestSSHTargets(t *testing.T) {
+	setSSHHome(t)
+
+	f := fake.New()
+	client := yc.NewFromBackend(f)
+	addInstance(f, "web", "10.128.0.10", "51.250.0.10", "ubuntu")
+	addInstance(f, "db", "10.128.0.20", "", "ubuntu")
+	addInstance(f, "bastion", "10.128.0.2", "51.250.0.2", "jump")
+	addInstance(f, "nouser", "10.128.0.30", "", "")
+
+	type hop struct {
+		user, addr string
+	}
+	tests := []struct {
+		name   string
+		target string
+		flags  map[string]any
+		want   hop
+		// jump is the hop the target is reached through, empty if it is reached directly.
+		jump    hop
+		wantErr string
+	}{
+		{
+			name:   "external IP",
+			target: "web",
+			want:   hop{"ubuntu", "51.250.0.10:22"},
+		},
+		{
+			name:   "internal IP without a bastion",
+			target: "db",
+			want:   hop{"ubuntu", "10.128.0.20:22"},
+		},
+		{
+			name:   "external IP with a bastion",
+			target: "web",
+			flags:  map[string]any{"bastion": "bastion"},
+			want:   hop{"ubuntu", "51.250.0.10:22"},
+		},
+		{
+			name:   "internal IP through the bastion",
+			target: "db",
+			flags:  map[string]any{"bastion": "bastion"},
+			want:   hop{"ubuntu", "10.128.0.20:22"},
+			jump:   hop{"jump", "51.250.0.2:22"},
+		},
+		{
+			name:   "internal flag through the bastion",
+			target: "web",
+			flags:  map[string]any{"bastion": "bastion", "internal": true},
+			want:   hop{"ubuntu", "10.128.0.10:22"},
+			jump:   hop{"jump", "51.250.0.2:22"},
+		},
+		{
+			name:   "bastion port",
+			target: "db",
+			flags:  map[string]any{"bastion": "bastion:2222"},
+			want:   hop{"ubuntu", "10.128.0.20:22"},
+			jump:   hop{"jump", "51.250.0.2:2222"},
+		},
+		{
+			name:   "port of the target",
+			target: "db",
+			flags:  map[string]any{"bastion": "bastion", "port": 2200},
+			want:   hop{"ubuntu", "10.128.0.20:2200"},
+			jump:   hop{"jump", "51.250.0.2:22"},
+		},
+		{
+			name:   "user of the target",
+			target: "db",
+			flags:  map[string]any{"bastion": "bastion", "user": "admin"},
+			want:   hop{"admin", "10.128.0.20:22"},
+			jump:   hop{"jump", "51.250.0.2:22"},
+		},
+		{
+			name:   "bastion itself",
+			target: "bastion",
+			flags:  map[string]any{"bastion": "bastion", "internal": true},
+			want:   hop{"jump", "10.128.0.2:22"},
+		},
+		{
+			name:   "jump host over the bastion",
+			target: "db",
+			flags:  map[string]any{"bastion": "bastion", "jump": "root@203.0.113.1:2022"},
+			want:   hop{"ubuntu", "10.128.0.20:22"},
+			jump:   hop{"root", "203.0.113.1:2022"},
+		},
+		{
+			name:   "jump host with the user of the target",
+			target: "web",
+			flags:  map[string]any{"jump": "203.0.113.1"},
+			want:   hop{"ubuntu", "51.250.0.10:22"},
+			jump:   hop{"ubuntu", "203.0.113.1"},
+		},
+		{
+			name:    "invalid bastion port",
+			target:  "db",
+			flags:   map[string]any{"bastion": "bastion:ssh"},
+			wantErr: `bastion bastion: invalid port "ssh"`,
+		},
+		{
+			name:    "unknown bastion",
+			target:  "db",
+			flags:   map[string]any{"bastion": "missing"},
+			wantErr: "bastion: ",
+		},
+		{
+			name:    "bastion without an external IP",
+			target:  "web",
+			flags:   map[string]any{"bastion": "db", "internal": true},
+			wantErr: "bastion db has no external IP address",
+		},
+		{
+			name:    "no user",
+			target:  "nouser",
+			wantErr: "no user in the user-data of compute instance nouser, set --user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set("folder-id", testFolderID)
+			for k, v := range tt.flags {
+				viper.Set(k, v)
+			}
+
+			ctx := context.Background()
+			lst, err := client.ComputeInstanceResolve(ctx, testFolderID, []string{tt.target}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			targets, err := sshTargets(ctx, client, lst)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := targets[0].Config
+			if targets[0].Name != tt.target || cfg.User != tt.want.user || cfg.Addr != tt.want.addr {
+				t.Errorf("target %s %s@%s, want %s %s@%s",
+					targets[0].Name, cfg.User, cfg.Addr, tt.target, tt.want.user, tt.want.addr)
+			}
+			if len(cfg.Auth) == 0 || cfg.HostKeyCallback == nil {
+				t.Error("no auth methods or host key callback")
+			}
+
+			switch {
+			case cfg.Jump == nil && len(tt.jump.addr) > 0:
+				t.Errorf("no jump, want %s@%s", tt.jump.user, tt.jump.addr)
+			case cfg.Jump != nil && len(tt.jump.addr) == 0:
+				t.Errorf("jump %s@%s, want none", cfg.Jump.User, cfg.Jump.Addr)
+			case cfg.Jump != nil && (cfg.Jump.User != tt.jump.user || cfg.Jump.Addr != tt.jump.addr):
+				t.Errorf("jump %s@%s, want %s@%s", cfg.Jump.User, cfg.Jump.Addr, tt.jump.user, tt.jump.addr)
+			}
+		})
+	}
+}
+
+func TestParseBastion(t *testing.T) {
+	tests := []struct {
+		in      string
+		ref     string
+		port    int
+		wantErr bool
+	}{
+		{in: "bastion", ref: "bastion", port: remote.DefaultPort},
+		{in: "fhm0123456789abcdef:2222", ref: "fhm0123456789abcdef", port: 2222},
+		{in: "bastion:", wantErr: true},
+		{in: "bastion:0", wantErr: true},
+		{in: "bastion:65536", wantErr: true},
+	}
+	for _, tt := range tests {
+		ref, port, err := parseBastion(tt.in)
+		if (err != nil) != tt.wantErr || ref != tt.ref || port != tt.port {
+			t.Errorf("parseBastion(%q) = %q, %d, %v, want %q, %d, error %t", tt.in, ref, port, err, tt.ref, tt.port, tt.wantErr)
+		}
+	}
+}
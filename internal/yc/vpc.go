@@ -0,0 +1,310 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"os"
+
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Network represents the network command
+func Network() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "network",
+		Short: "Manage VPC networks",
+	}
+
+	networkCreate.Flags().String("name", "", "network name")
+	networkCreate.Flags().String("description", "", "")
+	_ = networkCreate.MarkFlagRequired("name")
+	noWait(networkDelete)
+	parallel(networkDelete)
+
+	cmd.AddCommand(
+		networkCreate,
+		networkDelete,
+		networkList,
+	)
+
+	return cmd
+}
+
+// Subnet represents the subnet command
+func Subnet() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "subnet",
+		Short: "Manage VPC subnets",
+	}
+
+	subnetCreate.Flags().String("name", "", "subnet name")
+	subnetCreate.Flags().String("network", "", "network name or ID")
+	subnetCreate.Flags().String("range", "", "IPv4 CIDR of the subnet (default a free /24 of "+yc.AutoNetworkCIDR+")")
+	_ = subnetCreate.MarkFlagRequired("network")
+	subnetList.Flags().String("network", "", "show subnets of the network only")
+	noWait(subnetDelete)
+	parallel(subnetDelete)
+
+	cmd.AddCommand(
+		subnetCreate,
+		subnetDelete,
+		subnetList,
+	)
+
+	return cmd
+}
+
+var networkCreate = &cobra.Command{
+	Use:   "create",
+	Short: "Create a network",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		op, err := client.VPCNetworkCreate(
+			ctx,
+			viper.GetString("folder-id"),
+			viper.GetString("name"),
+			viper.GetString("description"),
+			checkLabels(nil),
+		)
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := op.Response()
+		if err != nil {
+			log.Fatal(err)
+		}
+		network := resp.(*vpc.Network)
+
+		log.Infof("The network %s (%s) created", network.Name, network.Id)
+	},
+}
+
+var networkDelete = &cobra.Command{
+	Aliases: []string{"rm", "del"},
+	Use:     "delete <name|id>...",
+	Short:   "Delete networks",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		subnets, err := client.VPCSubnetList(ctx, folderId)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		tasks := make([]yc.BulkTask, 0, len(args))
+		for _, ref := range args {
+			network, err := client.VPCNetworkResolve(ctx, folderId, ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, subnet := range subnets {
+				if subnet.NetworkId == network.Id {
+					log.Fatalf("The network %s has subnet %s, delete its subnets first", ref, subnet.Name)
+				}
+			}
+
+			id := network.Id
+			tasks = append(tasks, yc.BulkTask{
+				ID:   id,
+				Name: network.Name,
+				Run: func(ctx context.Context) (*operation.Operation, error) {
+					return client.VPCNetworkDelete(ctx, id)
+				},
+			})
+		}
+
+		exitBulk(runBulk(ctx, "Deleting", tasks))
+	},
+}
+
+var networkList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of networks",
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst, err := client.VPCNetworkList(ctx, viper.GetString("folder-id"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.NetworkColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var subnetCreate = &cobra.Command{
+	Use:   "create",
+	Short: "Create a subnet in the zone",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		network, err := client.VPCNetworkResolve(ctx, folderId, viper.GetString("network"))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		op, err := client.VPCSubnetCreate(ctx, &yc.SubnetConfig{
+			FolderID:  folderId,
+			NetworkID: network.Id,
+			Name:      viper.GetString("name"),
+			Zone:      viper.GetString("zone"),
+			CIDR:      viper.GetString("range"),
+			Labels:    checkLabels(nil),
+		})
+		if err = waitOperation(ctx, op, err); err != nil {
+			log.Fatal(err)
+		}
+
+		resp, err := op.Response()
+		if err != nil {
+			log.Fatal(err)
+		}
+		subnet := resp.(*vpc.Subnet)
+
+		log.Infof("The subnet %s (%s) created in %s", subnet.Name, subnet.Id, subnet.V4CidrBlocks[0])
+	},
+}
+
+var subnetDelete = &cobra.Command{
+	Aliases: []string{"rm", "del"},
+	Use:     "delete <name|id>...",
+	Short:   "Delete subnets",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		tasks := make([]yc.BulkTask, 0, len(args))
+		for _, ref := range args {
+			subnet, err := client.VPCSubnetResolve(ctx, viper.GetString("folder-id"), ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			id := subnet.Id
+			tasks = append(tasks, yc.BulkTask{
+				ID:   id,
+				Name: subnet.Name,
+				Run: func(ctx context.Context) (*operation.Operation, error) {
+					return client.VPCSubnetDelete(ctx, id)
+				},
+			})
+		}
+
+		exitBulk(runBulk(ctx, "Deleting", tasks))
+	},
+}
+
+var subnetList = &cobra.Command{
+	Aliases: []string{"ls"},
+	Use:     "list",
+	Short:   "List of subnets",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		folderId := viper.GetString("folder-id")
+		lst, err := client.VPCSubnetList(ctx, folderId)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if ref := viper.GetString("network"); len(ref) > 0 {
+			network, err := client.VPCNetworkResolve(ctx, folderId, ref)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			var filtered []*vpc.Subnet
+			for _, subnet := range lst {
+				if subnet.NetworkId == network.Id {
+					filtered = append(filtered, subnet)
+				}
+			}
+			lst = filtered
+		}
+
+		if err = yc.PrintList(os.Stdout, newPrinter(), lst, yc.SubnetColumns); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -0,0 +1,169 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ks-tool/ks/pkg/remote"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var vmWait = &cobra.Command{
+	Use:   "wait <name|id>... | -l <selector>",
+	Short: "Wait for compute instances to be ready",
+	Long: `Wait for compute instances to be ready.
+
+  --for status=<status>  polls the API until the instance has the status
+  --for ssh              also waits until SSH accepts connections
+  --for cloud-init       also waits until cloud-init has finished, checked over SSH,
+                         or in the serial port output with --serial or if SSH can't be used;
+                         it fails if cloud-init reported an error
+
+The wait is limited by --timeout.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		_ = viper.BindPFlags(cmd.Flags())
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		cond := waitConditionFromFlags("for")
+		if cond == nil {
+			log.Fatal("--for is required")
+		}
+
+		client, err := newClient(cmd.Context())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), viper.GetDuration("timeout"))
+		defer cancel()
+
+		lst := resolveInstances(ctx, client, args)
+		w := newWaiter(ctx, client, *cond)
+
+		var (
+			wg     sync.WaitGroup
+			failed bool
+			mu     sync.Mutex
+		)
+		for _, instance := range lst {
+			wg.Add(1)
+			go func(id, name string) {
+				defer wg.Done()
+
+				if err := w.wait(ctx, id); err != nil {
+					log.Errorf("%s: %s", name, err)
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+					return
+				}
+				log.Infof("The compute instance %s is ready", name)
+			}(instance.Id, instance.Name)
+		}
+		wg.Wait()
+
+		if failed {
+			log.Fatal("not all compute instances are ready")
+		}
+	},
+}
+
+func waitFlags(cmd *cobra.Command) {
+	cmd.Flags().String("for", "status=RUNNING", "condition to wait for: "+yc.WaitConditions)
+	cmd.Flags().Bool("serial", false, "check cloud-init in the serial port output instead of over SSH")
+}
+
+// waiter waits for compute instances to meet the condition.
+// waitConditionFromFlags parses the wait condition of the flag, nil if it is not set.
+// --serial is accepted with cloud-init only.
+func waitConditionFromFlags(name string) *yc.WaitCondition {
+	s := viper.GetString(name)
+	if len(s) == 0 {
+		if viper.GetBool("serial") {
+			log.Fatalf("--serial requires --%s cloud-init", name)
+		}
+		return nil
+	}
+
+	cond, err := yc.ParseWaitCondition(s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if viper.GetBool("serial") && cond.Kind != yc.WaitCloudInit {
+		log.Fatalf("--serial applies to --%s cloud-init only", name)
+	}
+
+	return &cond
+}
+
+type waiter struct {
+	client *yc.Client
+	cond   yc.WaitCondition
+
+	dialer    *sshDialer
+	dialerErr error
+}
+
+func newWaiter(ctx context.Context, client *yc.Client, cond yc.WaitCondition) *waiter {
+	w := &waiter{client: client, cond: cond}
+	if cond.Kind == yc.WaitSSH || (cond.Kind == yc.WaitCloudInit && !viper.GetBool("serial")) {
+		w.dialer, w.dialerErr = newSshDialer(ctx, client)
+	}
+
+	return w
+}
+
+func (w *waiter) wait(ctx context.Context, id string) error {
+	instance, err := w.client.ComputeInstanceWaitStatus(ctx, id, w.cond.Status)
+	if err != nil || w.cond.Kind == yc.WaitStatus {
+		return err
+	}
+
+	if w.cond.Kind == yc.WaitCloudInit && viper.GetBool("serial") {
+		return w.client.ComputeInstanceWaitCloudInit(ctx, id)
+	}
+
+	var cfg *remote.Config
+	if err = w.dialerErr; err == nil {
+		cfg, err = w.dialer.config(instance)
+	}
+	if err != nil {
+		if w.cond.Kind == yc.WaitSSH {
+			return err
+		}
+		log.Debugf("%s, checking cloud-init in the serial port output", err)
+		return w.client.ComputeInstanceWaitCloudInit(ctx, id)
+	}
+
+	conn, err := remote.DialWait(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if w.cond.Kind == yc.WaitSSH {
+		return nil
+	}
+
+	return conn.WaitCloudInit(ctx)
+}
@@ -0,0 +1,46 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+
+	"github.com/ks-tool/ks/pkg/yc"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// newClient creates a Yandex Cloud client with credentials resolved from the yc persistent flags.
+func newClient(ctx context.Context) (*yc.Client, error) {
+	return yc.NewClient(ctx, yc.CredentialsConfig{
+		ServiceAccountKeyFile: viper.GetString("sa-key-file"),
+		Token:                 viper.GetString("token"),
+		TokenFile:             viper.GetString("token-file"),
+		CLIProfile:            viper.GetString("yc-profile"),
+	})
+}
+
+// newPrinter creates a printer for the global output flags.
+func newPrinter() *yc.Printer {
+	p, err := yc.NewPrinter(viper.GetString("output"), viper.GetString("sort-by"), viper.GetBool("no-headers"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return p
+}
@@ -19,7 +19,10 @@ package common
 const (
 	ManagedKey = "managed"
 
-	LabelClusterNameKey       = ""
+	LabelClusterNameKey       = "ks-tool.dev/cluster"
+	LabelDNSZoneKey           = "ks-tool.dev/dns-zone"
+	LabelImageRecipeKey       = "ks-tool.dev/recipe"
+	LabelSecurityGroupPreset  = "ks-tool.dev/security-group-preset"
 	LabelNodeRoleControlPlane = "node-role.kubernetes.io/control-plane"
 
 	UserDataKey      = "user-data"
@@ -34,6 +37,18 @@ users:
       - {{ $key }}
       {{- end }}
     {{- end }}
+{{- with .mounts }}
+fs_setup:
+  {{- range . }}
+  - device: {{ .Device }}
+    filesystem: {{ .FS }}
+    overwrite: false
+  {{- end }}
+mounts:
+  {{- range . }}
+  - [ {{ .Device }}, {{ .Mount }}, {{ .FS }}, "defaults,nofail", "0", "2" ]
+  {{- end }}
+{{- end }}
 `
 	UserDataK8sTemplate = UserDataTemplate + ``
 )
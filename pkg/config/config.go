@@ -0,0 +1,208 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Context is a named set of defaults for the yc persistent flags.
+// The yaml keys are the names of the flags they fill.
+type Context struct {
+	FolderID string `yaml:"folder-id,omitempty"`
+	Zone     string `yaml:"zone,omitempty"`
+	SubnetID string `yaml:"subnet-id,omitempty"`
+	// ImageFamily pins the boot image family, so new instances get the latest patched image of it.
+	ImageFamily string `yaml:"image-family,omitempty"`
+	ImageFolder string `yaml:"image-folder,omitempty"`
+	TokenFile   string `yaml:"token-file,omitempty"`
+	SAKeyFile   string `yaml:"sa-key-file,omitempty"`
+	YCProfile   string `yaml:"yc-profile,omitempty"`
+	// Bastion is the compute instance instances without an external IP are reached through over SSH,
+	// as <name|id>[:port].
+	Bastion string `yaml:"bastion,omitempty"`
+}
+
+// ContextKeys returns the keys which can be set in a context.
+func ContextKeys() []string {
+	var out []string
+	t := reflect.TypeOf(Context{})
+	for i := 0; i < t.NumField(); i++ {
+		out = append(out, contextKey(t.Field(i)))
+	}
+
+	return out
+}
+
+// Values returns the non-empty values of the context by key.
+func (c *Context) Values() map[string]string {
+	out := make(map[string]string)
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if s := v.Field(i).String(); len(s) > 0 {
+			out[contextKey(v.Type().Field(i))] = s
+		}
+	}
+
+	return out
+}
+
+// Set sets the value of key. An empty value unsets it.
+func (c *Context) Set(key, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if contextKey(v.Type().Field(i)) == key {
+			v.Field(i).SetString(value)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown context key %q", key)
+}
+
+func contextKey(f reflect.StructField) string {
+	key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return key
+}
+
+// Config is the ks config file. Keys other than contexts are kept as is.
+type Config struct {
+	CurrentContext string              `yaml:"current-context,omitempty"`
+	Contexts       map[string]*Context `yaml:"contexts,omitempty"`
+	Extra          map[string]any      `yaml:",inline"`
+
+	path string
+}
+
+// Dir returns the ks directory $HOME/.ks.
+func Dir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".ks"), nil
+}
+
+// Path returns the path of the named config file in $HOME/.ks.
+func Path(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name+".yaml"), nil
+}
+
+// Load reads the config file. A missing file gives an empty config.
+func Load(path string) (*Config, error) {
+	cfg := &Config{path: path}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+
+		return nil, err
+	}
+
+	if err = yaml.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Save writes the config back to the file it was loaded from.
+func (c *Config) Save() error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.path, b, 0o600)
+}
+
+// Context returns the named context, or the current one if name is empty.
+func (c *Config) Context(name string) (*Context, error) {
+	if len(name) == 0 {
+		name = c.CurrentContext
+	}
+	if len(name) == 0 {
+		return nil, errors.New("current context is not set")
+	}
+
+	ctx, ok := c.Contexts[name]
+	if !ok || ctx == nil {
+		return nil, fmt.Errorf("context %q not found", name)
+	}
+
+	return ctx, nil
+}
+
+// SetContext creates the named context if needed and returns it.
+func (c *Config) SetContext(name string) *Context {
+	if c.Contexts == nil {
+		c.Contexts = make(map[string]*Context)
+	}
+
+	ctx, ok := c.Contexts[name]
+	if !ok || ctx == nil {
+		ctx = &Context{}
+		c.Contexts[name] = ctx
+	}
+
+	return ctx
+}
+
+// DeleteContext removes the named context and unsets it if it is current.
+func (c *Config) DeleteContext(name string) error {
+	if _, ok := c.Contexts[name]; !ok {
+		return fmt.Errorf("context %q not found", name)
+	}
+
+	delete(c.Contexts, name)
+	if c.CurrentContext == name {
+		c.CurrentContext = ""
+	}
+
+	return nil
+}
+
+// ContextNames returns the sorted names of all contexts.
+func (c *Config) ContextNames() []string {
+	out := make([]string, 0, len(c.Contexts))
+	for name := range c.Contexts {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+
+	return out
+}
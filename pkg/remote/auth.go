@@ -0,0 +1,214 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// DefaultKeyDir is the directory searched for keys when no public keys are given.
+const DefaultKeyDir = "~/.ssh"
+
+// PublicKeyFiles returns the files of the directory holding an SSH public key.
+func PublicKeyFiles(dir string) ([]string, error) {
+	dir, err := homedir.Expand(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		filename := entry.Name()
+		if entry.IsDir() || filename == "config" || strings.HasPrefix(filename, "known_hosts") {
+			continue
+		}
+
+		file := filepath.Join(dir, filename)
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(b, []byte("PRIVATE KEY")) {
+			continue
+		}
+
+		if _, _, _, _, err = ssh.ParseAuthorizedKey(b); err == nil {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+// Agent is the ssh-agent offering its keys.
+// Every connection dials the agent and the agent connection is closed with the client.
+type Agent struct {
+	Socket string
+	// Allowed limits the offered keys to these public keys in the wire format. Empty allows all.
+	Allowed [][]byte
+}
+
+func (a *Agent) dial() (net.Conn, ssh.AuthMethod, error) {
+	conn, err := net.Dial("unix", a.Socket)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn, ssh.PublicKeysCallback(agentSigners(agent.NewClient(conn), a.Allowed)), nil
+}
+
+// AuthMethods returns the ssh-agent and the private keys matching the public key files.
+// A private key is looked up next to its public key without the .pub extension.
+// If public keys are given, only the agent keys among them are offered,
+// otherwise all agent keys and the keys in DefaultKeyDir are.
+// Private keys protected by a passphrase are skipped, they are expected to be in the agent.
+// The agent is nil if SSH_AUTH_SOCK is not set or doesn't accept connections.
+func AuthMethods(pubKeyFiles []string) (*Agent, []ssh.AuthMethod, error) {
+	var (
+		methods  []ssh.AuthMethod
+		allowed  [][]byte
+		keyFiles []string
+	)
+
+	if len(pubKeyFiles) == 0 {
+		// A missing directory leaves the agent only.
+		files, _ := PublicKeyFiles(DefaultKeyDir)
+		for _, file := range files {
+			if key, ok := strings.CutSuffix(file, ".pub"); ok {
+				keyFiles = append(keyFiles, key)
+			}
+		}
+	} else {
+		for _, file := range pubKeyFiles {
+			file, err := homedir.Expand(file)
+			if err != nil {
+				return nil, nil, err
+			}
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return nil, nil, err
+			}
+			pub, _, _, _, err := ssh.ParseAuthorizedKey(b)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", file, err)
+			}
+
+			allowed = append(allowed, pub.Marshal())
+			if key, ok := strings.CutSuffix(file, ".pub"); ok {
+				keyFiles = append(keyFiles, key)
+			}
+		}
+	}
+
+	var a *Agent
+	if sock := os.Getenv("SSH_AUTH_SOCK"); len(sock) > 0 {
+		a = &Agent{Socket: sock, Allowed: allowed}
+		// The agent is probed once, so that a dead one is reported as no keys.
+		conn, _, err := a.dial()
+		if err != nil {
+			log.Debugf("ssh-agent: %v", err)
+			a = nil
+		} else {
+			_ = conn.Close()
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, file := range keyFiles {
+		signer, err := readPrivateKey(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if signer != nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if a == nil && len(methods) == 0 {
+		return nil, nil, errors.New("no SSH keys found, start ssh-agent or set --ssh-pub")
+	}
+
+	return a, methods, nil
+}
+
+func agentSigners(a agent.ExtendedAgent, allowed [][]byte) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		signers, err := a.Signers()
+		if err != nil || len(allowed) == 0 {
+			return signers, err
+		}
+
+		var out []ssh.Signer
+		for _, s := range signers {
+			for _, pub := range allowed {
+				if bytes.Equal(s.PublicKey().Marshal(), pub) {
+					out = append(out, s)
+					break
+				}
+			}
+		}
+
+		return out, nil
+	}
+}
+
+// readPrivateKey returns nil if the key is missing or protected by a passphrase.
+func readPrivateKey(file string) (ssh.Signer, error) {
+	file, err := homedir.Expand(file)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(filepath.Clean(file))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(b)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			log.Debugf("%s is protected by a passphrase, skipped", file)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,331 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// writeKey writes the key pair to dir/name and dir/name.pub, the private key encrypted
+// if the passphrase is not empty. It returns the key, its signer and the public key file.
+func writeKey(t *testing.T, dir, name, passphrase string) (ed25519.PrivateKey, ssh.Signer, string) {
+	t.Helper()
+
+	key, signer := newKey(t)
+
+	var (
+		block *pem.Block
+		err   error
+	)
+	if len(passphrase) > 0 {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, name)
+	if err = os.WriteFile(file, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(file+".pub", ssh.MarshalAuthorizedKey(signer.PublicKey()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return key, signer, file + ".pub"
+}
+
+// setHome points the home directory to an empty one without an ssh-agent.
+func setHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+
+	if err := os.Mkdir(filepath.Join(home, ".ssh"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	return home
+}
+
+// testAgent is an in-process ssh-agent counting its open connections.
+type testAgent struct {
+	sock    string
+	keyring agent.Agent
+
+	mu    sync.Mutex
+	conns int
+}
+
+func newTestAgent(t *testing.T, keys ...ed25519.PrivateKey) *testAgent {
+	t.Helper()
+
+	// A short path, the socket path is limited to about 100 bytes.
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	a := &testAgent{sock: filepath.Join(dir, "sock"), keyring: agent.NewKeyring()}
+	for _, k := range keys {
+		if err = a.keyring.Add(agent.AddedKey{PrivateKey: k}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := net.Listen("unix", a.sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			a.mu.Lock()
+			a.conns++
+			a.mu.Unlock()
+
+			go func() {
+				_ = agent.ServeAgent(a.keyring, conn)
+				_ = conn.Close()
+				a.mu.Lock()
+				a.conns--
+				a.mu.Unlock()
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", a.sock)
+
+	return a
+}
+
+func (a *testAgent) waitConns(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		a.mu.Lock()
+		conns := a.conns
+		a.mu.Unlock()
+		if conns == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("agent has %d connections, want %d", conns, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPublicKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, _, pub := writeKey(t, dir, "id_ed25519", "")
+	for name, content := range map[string]string{
+		"config":          "Host *\n",
+		"known_hosts":     "",
+		"authorized_keys": "not a key\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := PublicKeyFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != pub {
+		t.Errorf("PublicKeyFiles = %v, want [%s]", files, pub)
+	}
+}
+
+func TestAuthMethods(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup returns the public key files and the key the server accepts.
+		setup   func(t *testing.T, home string) ([]string, ssh.Signer)
+		wantErr string
+	}{
+		{
+			name: "default key dir",
+			setup: func(t *testing.T, home string) ([]string, ssh.Signer) {
+				_, s, _ := writeKey(t, filepath.Join(home, ".ssh"), "id_ed25519", "")
+				return nil, s
+			},
+		},
+		{
+			name: "public key file",
+			setup: func(t *testing.T, home string) ([]string, ssh.Signer) {
+				_, s, pub := writeKey(t, home, "deploy", "")
+				return []string{pub}, s
+			},
+		},
+		{
+			name: "public key file in the home dir",
+			setup: func(t *testing.T, home string) ([]string, ssh.Signer) {
+				_, s, _ := writeKey(t, home, "deploy", "")
+				return []string{"~/deploy.pub"}, s
+			},
+		},
+		{
+			name: "passphrase is skipped",
+			setup: func(t *testing.T, home string) ([]string, ssh.Signer) {
+				_, _, pub := writeKey(t, home, "deploy", "secret")
+				return []string{pub}, nil
+			},
+			wantErr: "no SSH keys found",
+		},
+		{
+			name: "no keys",
+			setup: func(t *testing.T, home string) ([]string, ssh.Signer) {
+				return nil, nil
+			},
+			wantErr: "no SSH keys found",
+		},
+		{
+			name: "missing public key file",
+			setup: func(t *testing.T, home string) ([]string, ssh.Signer) {
+				return []string{filepath.Join(home, "missing.pub")}, nil
+			},
+			wantErr: "no such file",
+		},
+		{
+			name: "dead agent",
+			setup: func(t *testing.T, home string) ([]string, ssh.Signer) {
+				t.Setenv("SSH_AUTH_SOCK", filepath.Join(home, "agent.sock"))
+				return nil, nil
+			},
+			wantErr: "no SSH keys found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := setHome(t)
+			files, key := tt.setup(t, home)
+
+			a, methods, err := AuthMethods(files)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a != nil {
+				t.Errorf("agent %v without SSH_AUTH_SOCK", a)
+			}
+
+			srv := newTestServer(t, key)
+			cfg := srv.config("ubuntu", key)
+			cfg.Auth = methods
+
+			c, err := Dial(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = c.Close()
+		})
+	}
+}
+
+func TestAuthMethodsAgent(t *testing.T) {
+	home := setHome(t)
+	allowedKey, allowed, pub := writeKey(t, home, "allowed", "")
+	otherKey, _, _ := writeKey(t, home, "other", "")
+	// The private key file is not needed with the agent.
+	if err := os.Remove(filepath.Join(home, "allowed")); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAgent(t, otherKey, allowedKey)
+
+	agentCfg, methods, err := AuthMethods([]string{pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agentCfg == nil || len(methods) != 0 {
+		t.Fatalf("AuthMethods = %v, %d methods, want the agent only", agentCfg, len(methods))
+	}
+	// The probe connection is closed.
+	a.waitConns(t, 0)
+
+	srv := newTestServer(t, allowed)
+	cfg := srv.config("ubuntu", allowed)
+	cfg.Agent, cfg.Auth = agentCfg, methods
+
+	c, err := Dial(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.waitConns(t, 1)
+
+	srv.mu.Lock()
+	for _, k := range srv.offered {
+		if !bytes.Equal(k, allowed.PublicKey().Marshal()) {
+			t.Error("the agent offered a key not in the public key files")
+		}
+	}
+	srv.mu.Unlock()
+
+	_ = c.Close()
+	a.waitConns(t, 0)
+}
+
+func TestDialAgentGone(t *testing.T) {
+	setHome(t)
+	key := newSigner(t)
+	srv := newTestServer(t, key)
+
+	// The agent stopped after AuthMethods, the other methods are still offered.
+	cfg := srv.config("ubuntu", key)
+	cfg.Agent = &Agent{Socket: filepath.Join(t.TempDir(), "sock")}
+
+	c, err := Dial(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package remote runs commands on compute instances over SSH.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultPort    = 22
+	DefaultTimeout = 10 * time.Second
+)
+
+// Config describes an SSH connection.
+type Config struct {
+	User string
+	// Addr is host or host:port. The port defaults to 22.
+	Addr string
+
+	// Agent, if set, is offered before Auth.
+	Agent           *Agent
+	Auth            []ssh.AuthMethod
+	HostKeyCallback ssh.HostKeyCallback
+	// Timeout limits connecting and the handshake. Zero means DefaultTimeout.
+	Timeout time.Duration
+
+	// Jump is the host the connection is tunnelled through, like `ssh -J`.
+	Jump *Config
+}
+
+// ParseTarget splits "[user@]host[:port]" into the user and the address.
+func ParseTarget(s string) (user, addr string) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+
+	return "", s
+}
+
+func (cfg *Config) address() string {
+	if _, _, err := net.SplitHostPort(cfg.Addr); err == nil {
+		return cfg.Addr
+	}
+
+	return net.JoinHostPort(strings.Trim(cfg.Addr, "[]"), fmt.Sprint(DefaultPort))
+}
+
+func (cfg *Config) timeout() time.Duration {
+	if cfg.Timeout > 0 {
+		return cfg.Timeout
+	}
+
+	return DefaultTimeout
+}
+
+// Client is an SSH connection, possibly through a jump host.
+type Client struct {
+	*ssh.Client
+
+	jump  *Client
+	agent net.Conn
+}
+
+// Dial connects to the host of the config.
+func Dial(ctx context.Context, cfg *Config) (*Client, error) {
+	var (
+		jump *Client
+		conn net.Conn
+		err  error
+	)
+
+	addr := cfg.address()
+	if cfg.Jump == nil {
+		d := net.Dialer{Timeout: cfg.timeout()}
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		if jump, err = Dial(ctx, cfg.Jump); err != nil {
+			return nil, err
+		}
+		conn, err = jump.Dial("tcp", addr)
+	}
+	if err != nil {
+		if jump != nil {
+			_ = jump.Close()
+		}
+		return nil, fmt.Errorf("ssh %s: %w", addr, err)
+	}
+
+	auth := cfg.Auth
+	var agentConn net.Conn
+	if cfg.Agent != nil {
+		var method ssh.AuthMethod
+		if agentConn, method, err = cfg.Agent.dial(); err != nil {
+			log.Debugf("ssh-agent: %v", err)
+		} else {
+			auth = append([]ssh.AuthMethod{method}, cfg.Auth...)
+		}
+	}
+
+	// The handshake doesn't take a context.
+	_ = conn.SetDeadline(time.Now().Add(cfg.timeout()))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		HostKeyCallback: cfg.HostKeyCallback,
+	})
+	if err != nil {
+		_ = conn.Close()
+		if agentConn != nil {
+			_ = agentConn.Close()
+		}
+		if jump != nil {
+			_ = jump.Close()
+		}
+		return nil, fmt.Errorf("ssh %s@%s: %w", cfg.User, addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return &Client{Client: ssh.NewClient(c, chans, reqs), jump: jump, agent: agentConn}, nil
+}
+
+// Close closes the connection, the ssh-agent connection and the jump host connection.
+func (c *Client) Close() error {
+	err := c.Client.Close()
+	if c.agent != nil {
+		_ = c.agent.Close()
+	}
+	if c.jump != nil {
+		_ = c.jump.Close()
+	}
+
+	return err
+}
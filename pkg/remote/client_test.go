@@ -0,0 +1,390 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an in-process SSH server. It runs `exit N` and echoes any other command,
+// serves SFTP on the local file system and forwards direct-tcpip channels, so it works as a jump host.
+type testServer struct {
+	addr    string
+	hostKey ssh.Signer
+
+	mu         sync.Mutex
+	authorized [][]byte
+	offered    [][]byte
+	forwarded  []string
+	conns      int
+}
+
+func newKey(t *testing.T) (ed25519.PrivateKey, ssh.Signer) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, signer
+}
+
+func newSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+
+	_, signer := newKey(t)
+	return signer
+}
+
+// newTestServer starts a server accepting the public keys of the signers.
+func newTestServer(t *testing.T, authorized ...ssh.Signer) *testServer {
+	t.Helper()
+
+	srv := &testServer{hostKey: newSigner(t)}
+	for _, s := range authorized {
+		srv.authorized = append(srv.authorized, s.PublicKey().Marshal())
+	}
+
+	cfg := &ssh.ServerConfig{PublicKeyCallback: srv.publicKey}
+	cfg.AddHostKey(srv.hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	srv.addr = l.Addr().String()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn, cfg)
+		}
+	}()
+
+	return srv
+}
+
+func (srv *testServer) publicKey(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.offered = append(srv.offered, key.Marshal())
+	for _, k := range srv.authorized {
+		if bytes.Equal(k, key.Marshal()) {
+			return nil, nil
+		}
+	}
+
+	return nil, errors.New("unknown key")
+}
+
+func (srv *testServer) serve(conn net.Conn, cfg *ssh.ServerConfig) {
+	sc, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	srv.mu.Lock()
+	srv.conns++
+	srv.mu.Unlock()
+	defer func() {
+		srv.mu.Lock()
+		srv.conns--
+		srv.mu.Unlock()
+	}()
+
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		switch nc.ChannelType() {
+		case "session":
+			go srv.session(nc)
+		case "direct-tcpip":
+			go srv.forward(nc)
+		default:
+			_ = nc.Reject(ssh.UnknownChannelType, nc.ChannelType())
+		}
+	}
+	_ = sc.Wait()
+}
+
+func (srv *testServer) session(nc ssh.NewChannel) {
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		return
+	}
+	defer ch.Close()
+
+	for req := range reqs {
+		if req.Type == "subsystem" {
+			var payload struct{ Name string }
+			if ssh.Unmarshal(req.Payload, &payload) != nil || payload.Name != "sftp" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+
+			sftpServer, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+			_ = sftpServer.Serve()
+			return
+		}
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+
+		var payload struct{ Command string }
+		if err = ssh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+
+		var status uint32
+		if s, ok := strings.CutPrefix(payload.Command, "exit "); ok {
+			n, _ := strconv.Atoi(s)
+			status = uint32(n)
+		} else {
+			_, _ = fmt.Fprintln(ch, payload.Command)
+		}
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+func (srv *testServer) forward(nc ssh.NewChannel) {
+	var payload struct {
+		Addr     string
+		Port     uint32
+		OrigAddr string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(nc.ExtraData(), &payload); err != nil {
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	addr := net.JoinHostPort(payload.Addr, fmt.Sprint(payload.Port))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	srv.mu.Lock()
+	srv.forwarded = append(srv.forwarded, addr)
+	srv.mu.Unlock()
+
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		_ = ch.CloseWrite()
+	}()
+	_, _ = io.Copy(conn, ch)
+	_ = conn.Close()
+}
+
+// waitConns waits until the server has n connections.
+func (srv *testServer) waitConns(t *testing.T, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		srv.mu.Lock()
+		conns := srv.conns
+		srv.mu.Unlock()
+		if conns == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server has %d connections, want %d", conns, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (srv *testServer) config(user string, auth ssh.Signer) *Config {
+	return &Config{
+		User:            user,
+		Addr:            srv.addr,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(auth)},
+		HostKeyCallback: ssh.FixedHostKey(srv.hostKey.PublicKey()),
+		Timeout:         2 * time.Second,
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in, user, addr string
+	}{
+		{"host", "", "host"},
+		{"ubuntu@host", "ubuntu", "host"},
+		{"ubuntu@10.0.0.1:2222", "ubuntu", "10.0.0.1:2222"},
+		{"a@b@host", "a@b", "host"},
+	}
+	for _, tt := range tests {
+		user, addr := ParseTarget(tt.in)
+		if user != tt.user || addr != tt.addr {
+			t.Errorf("ParseTarget(%q) = %q, %q, want %q, %q", tt.in, user, addr, tt.user, tt.addr)
+		}
+	}
+}
+
+func TestConfigAddress(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"10.0.0.1", "10.0.0.1:22"},
+		{"10.0.0.1:2222", "10.0.0.1:2222"},
+		{"::1", "[::1]:22"},
+		{"[::1]", "[::1]:22"},
+		{"[::1]:2222", "[::1]:2222"},
+	}
+	for _, tt := range tests {
+		if got := (&Config{Addr: tt.addr}).address(); got != tt.want {
+			t.Errorf("address of %q = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestDial(t *testing.T) {
+	key := newSigner(t)
+	srv := newTestServer(t, key)
+
+	c, err := Dial(context.Background(), srv.config("ubuntu", key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	code, err := c.Run("echo hello", nil, &out, io.Discard)
+	if err != nil || code != 0 || out.String() != "echo hello\n" {
+		t.Errorf("Run = %d, %v, %q", code, err, out.String())
+	}
+	if code, err = c.Run("exit 3", nil, io.Discard, io.Discard); err != nil || code != 3 {
+		t.Errorf("Run(exit 3) = %d, %v, want 3", code, err)
+	}
+
+	_ = c.Close()
+	srv.waitConns(t, 0)
+}
+
+func TestDialErrors(t *testing.T) {
+	key := newSigner(t)
+	srv := newTestServer(t, key)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	_ = l.Close()
+
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   string
+	}{
+		{"connection refused", func(cfg *Config) { cfg.Addr = closed }, "ssh " + closed},
+		{"unknown key", func(cfg *Config) {
+			cfg.Auth = []ssh.AuthMethod{ssh.PublicKeys(newSigner(t))}
+		}, "unable to authenticate"},
+		{"host key mismatch", func(cfg *Config) {
+			cfg.HostKeyCallback = ssh.FixedHostKey(newSigner(t).PublicKey())
+		}, "host key mismatch"},
+		{"jump host refused", func(cfg *Config) {
+			cfg.Jump = &Config{Addr: closed, Timeout: time.Second}
+		}, "ssh " + closed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := srv.config("ubuntu", key)
+			tt.modify(cfg)
+
+			c, err := Dial(context.Background(), cfg)
+			if err == nil {
+				_ = c.Close()
+				t.Fatal("no error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q has no %q", err, tt.want)
+			}
+		})
+	}
+	srv.waitConns(t, 0)
+}
+
+func TestDialJump(t *testing.T) {
+	key := newSigner(t)
+	jump := newTestServer(t, key)
+	target := newTestServer(t, key)
+
+	cfg := target.config("ubuntu", key)
+	cfg.Jump = jump.config("bastion", key)
+
+	c, err := Dial(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code, err := c.Run("hostname", nil, &out, io.Discard); err != nil || code != 0 || out.String() != "hostname\n" {
+		t.Errorf("Run = %d, %v, %q", code, err, out.String())
+	}
+
+	jump.mu.Lock()
+	forwarded := jump.forwarded
+	jump.mu.Unlock()
+	if len(forwarded) != 1 || forwarded[0] != target.addr {
+		t.Errorf("jump host forwarded to %v, want %s", forwarded, target.addr)
+	}
+
+	_ = c.Close()
+	target.waitConns(t, 0)
+	jump.waitConns(t, 0)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// CopyProgress is notified while files are copied. Names are the source paths.
+type CopyProgress interface {
+	Start(name string, size int64)
+	Add(name string, n int64)
+	Done(name string, err error)
+}
+
+// CopyOptions controls Upload and Download.
+type CopyOptions struct {
+	// Recursive allows copying directories.
+	Recursive bool
+	Progress  CopyProgress
+}
+
+// Upload copies the local file or directory to the remote path over SFTP.
+// Like cp, the source is copied into the destination if it is a directory or ends with a slash.
+func (c *Client) Upload(local, remote string, opts CopyOptions) error {
+	sc, err := sftp.NewClient(c.Client)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	if st, err := sc.Stat(remote); (err == nil && st.IsDir()) || strings.HasSuffix(remote, "/") {
+		remote = path.Join(remote, filepath.Base(local))
+	}
+
+	if !info.IsDir() {
+		return uploadFile(sc, local, remote, info, opts.Progress)
+	}
+	if !opts.Recursive {
+		return fmt.Errorf("%s is a directory, copy it with --recursive", local)
+	}
+
+	return filepath.WalkDir(local, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(local, name)
+		if err != nil {
+			return err
+		}
+		dst := path.Join(remote, filepath.ToSlash(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if err = sc.MkdirAll(dst); err != nil {
+				return fmt.Errorf("%s: %w", dst, err)
+			}
+			return sc.Chmod(dst, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return uploadFile(sc, name, dst, info, opts.Progress)
+	})
+}
+
+// Download copies the remote file or directory to the local path over SFTP.
+// Like cp, the source is copied into the destination if it is a directory or ends with a separator.
+func (c *Client) Download(remote, local string, opts CopyOptions) error {
+	sc, err := sftp.NewClient(c.Client)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+
+	info, err := sc.Stat(remote)
+	if err != nil {
+		return fmt.Errorf("%s: %w", remote, err)
+	}
+	if st, err := os.Stat(local); (err == nil && st.IsDir()) || strings.HasSuffix(local, string(filepath.Separator)) {
+		local = filepath.Join(local, path.Base(remote))
+	}
+
+	if !info.IsDir() {
+		return downloadFile(sc, remote, local, info, opts.Progress)
+	}
+	if !opts.Recursive {
+		return fmt.Errorf("%s is a directory, copy it with --recursive", remote)
+	}
+
+	walker := sc.Walk(remote)
+	for walker.Step() {
+		if err = walker.Err(); err != nil {
+			return err
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), remote), "/")
+		dst := filepath.Join(local, filepath.FromSlash(rel))
+
+		info := walker.Stat()
+		if info.IsDir() {
+			if err = os.MkdirAll(dst, info.Mode().Perm()|0o700); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		if err = downloadFile(sc, walker.Path(), dst, info, opts.Progress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func uploadFile(sc *sftp.Client, src, dst string, info fs.FileInfo, progress CopyProgress) (err error) {
+	start(progress, src, info.Size())
+	defer func() { done(progress, src, err) }()
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := sc.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, &progressReader{r: in, name: src, progress: progress}); err != nil {
+		return err
+	}
+
+	return out.Chmod(info.Mode().Perm())
+}
+
+func downloadFile(sc *sftp.Client, src, dst string, info fs.FileInfo, progress CopyProgress) (err error) {
+	start(progress, src, info.Size())
+	defer func() { done(progress, src, err) }()
+
+	in, err := sc.Open(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(&progressWriter{w: out, name: src, progress: progress}, in); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+func start(progress CopyProgress, name string, size int64) {
+	if progress != nil {
+		progress.Start(name, size)
+	}
+}
+
+func done(progress CopyProgress, name string, err error) {
+	if progress != nil {
+		progress.Done(name, err)
+	}
+}
+
+// progressReader reports the bytes read.
+type progressReader struct {
+	r        io.Reader
+	name     string
+	progress CopyProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if r.progress != nil && n > 0 {
+		r.progress.Add(r.name, int64(n))
+	}
+
+	return n, err
+}
+
+type progressWriter struct {
+	w        io.Writer
+	name     string
+	progress CopyProgress
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if w.progress != nil && n > 0 {
+		w.progress.Add(w.name, int64(n))
+	}
+
+	return n, err
+}
@@ -0,0 +1,373 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type file struct {
+	content string
+	mode    fs.FileMode
+}
+
+// writeTree creates the files under dir. A name ending with a slash is a directory.
+func writeTree(t *testing.T, dir string, files map[string]file) {
+	t.Helper()
+
+	for name, f := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if strings.HasSuffix(name, "/") {
+			if err := os.MkdirAll(p, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(f.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, f := range files {
+		if err := os.Chmod(filepath.Join(dir, filepath.FromSlash(name)), f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree returns the files under dir like writeTree takes them.
+func readTree(t *testing.T, dir string) map[string]file {
+	t.Helper()
+
+	files := make(map[string]file)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			files[filepath.ToSlash(rel)+"/"] = file{mode: info.Mode().Perm()}
+			return nil
+		}
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = file{content: string(b), mode: info.Mode().Perm()}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func compareTrees(t *testing.T, got, want map[string]file) {
+	t.Helper()
+
+	for name, w := range want {
+		g, ok := got[name]
+		switch {
+		case !ok:
+			t.Errorf("%s is missing", name)
+		case g != w:
+			t.Errorf("%s = %q %v, want %q %v", name, g.content, g.mode, w.content, w.mode)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected %s", name)
+		}
+	}
+}
+
+// recorder is a CopyProgress counting the bytes of every file.
+type recorder struct {
+	mu    sync.Mutex
+	sizes map[string]int64
+	added map[string]int64
+	done  map[string]error
+}
+
+func newRecorder() *recorder {
+	return &recorder{sizes: make(map[string]int64), added: make(map[string]int64), done: make(map[string]error)}
+}
+
+func (r *recorder) Start(name string, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sizes[name] = size
+}
+
+func (r *recorder) Add(name string, n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.added[name] += n
+}
+
+func (r *recorder) Done(name string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done[name] = err
+}
+
+var testTree = map[string]file{
+	"app/":           {mode: 0o750},
+	"app/app.conf":   {content: "port = 80\n", mode: 0o640},
+	"app/bin/":       {mode: 0o755},
+	"app/bin/run.sh": {content: "#!/bin/sh\nexec app\n", mode: 0o755},
+	"app/empty/":     {mode: 0o700},
+}
+
+// subTree returns the files of the tree under prefix, renamed to be under name.
+func subTree(tree map[string]file, prefix, name string) map[string]file {
+	out := make(map[string]file)
+	for n, f := range tree {
+		if rest, ok := strings.CutPrefix(n, prefix); ok {
+			out[name+rest] = f
+		}
+	}
+
+	return out
+}
+
+// withTree returns the files of both trees.
+func withTree(a, b map[string]file) map[string]file {
+	out := maps.Clone(a)
+	maps.Copy(out, b)
+
+	return out
+}
+
+func dialTestServer(t *testing.T) *Client {
+	t.Helper()
+
+	key := newSigner(t)
+	srv := newTestServer(t, key)
+	c, err := Dial(context.Background(), srv.config("ubuntu", key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+func TestUpload(t *testing.T) {
+	c := dialTestServer(t)
+	src := t.TempDir()
+	writeTree(t, src, testTree)
+
+	tests := []struct {
+		name string
+		// src and dst are relative to the local and the remote directory.
+		src, dst  string
+		recursive bool
+		existing  map[string]file
+		want      map[string]file
+		wantErr   string
+	}{
+		{
+			name: "file into a directory",
+			src:  "app/app.conf", dst: "etc",
+			existing: map[string]file{"etc/": {mode: 0o755}},
+			want:     map[string]file{"etc/": {mode: 0o755}, "etc/app.conf": testTree["app/app.conf"]},
+		},
+		{
+			name: "file as a new name",
+			src:  "app/bin/run.sh", dst: "start.sh",
+			want: map[string]file{"start.sh": testTree["app/bin/run.sh"]},
+		},
+		{
+			name: "file over an existing one",
+			src:  "app/app.conf", dst: "app.conf",
+			existing: map[string]file{"app.conf": {content: "old content, longer than the new one\n", mode: 0o600}},
+			want:     map[string]file{"app.conf": testTree["app/app.conf"]},
+		},
+		{
+			name: "directory without recursive",
+			src:  "app", dst: "srv",
+			wantErr: "is a directory, copy it with --recursive",
+		},
+		{
+			name: "directory into a directory",
+			src:  "app", dst: "srv", recursive: true,
+			existing: map[string]file{"srv/": {mode: 0o755}},
+			want:     withTree(map[string]file{"srv/": {mode: 0o755}}, subTree(testTree, "app", "srv/app")),
+		},
+		{
+			name: "directory as a new name",
+			src:  "app", dst: "app-1.0", recursive: true,
+			want: subTree(testTree, "app", "app-1.0"),
+		},
+		{
+			name: "missing remote directory",
+			src:  "app/app.conf", dst: "missing/app.conf",
+			wantErr: "missing/app.conf",
+		},
+		{
+			name: "remote path under a file",
+			src:  "app", dst: "app.conf/app", recursive: true,
+			existing: map[string]file{"app.conf": {content: "port = 80\n", mode: 0o644}},
+			wantErr:  "app.conf/app",
+		},
+		{
+			name: "missing local file",
+			src:  "missing", dst: "missing",
+			wantErr: "no such file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+			writeTree(t, dst, tt.existing)
+
+			err := c.Upload(filepath.Join(src, tt.src), filepath.Join(dst, tt.dst), CopyOptions{Recursive: tt.recursive})
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compareTrees(t, readTree(t, dst), tt.want)
+		})
+	}
+}
+
+func TestDownload(t *testing.T) {
+	c := dialTestServer(t)
+	src := t.TempDir()
+	writeTree(t, src, testTree)
+
+	tests := []struct {
+		name string
+		// src and dst are relative to the remote and the local directory.
+		src, dst  string
+		recursive bool
+		existing  map[string]file
+		want      map[string]file
+		wantErr   string
+	}{
+		{
+			name: "file into a directory",
+			src:  "app/app.conf", dst: "etc",
+			existing: map[string]file{"etc/": {mode: 0o755}},
+			want:     map[string]file{"etc/": {mode: 0o755}, "etc/app.conf": testTree["app/app.conf"]},
+		},
+		{
+			name: "file as a new name",
+			src:  "app/bin/run.sh", dst: "start.sh",
+			want: map[string]file{"start.sh": testTree["app/bin/run.sh"]},
+		},
+		{
+			name: "directory without recursive",
+			src:  "app", dst: "srv",
+			wantErr: "is a directory, copy it with --recursive",
+		},
+		{
+			name: "directory into a directory",
+			src:  "app", dst: "srv", recursive: true,
+			existing: map[string]file{"srv/": {mode: 0o755}},
+			want:     withTree(map[string]file{"srv/": {mode: 0o755}}, subTree(testTree, "app", "srv/app")),
+		},
+		{
+			name: "directory as a new name",
+			src:  "app", dst: "app-1.0", recursive: true,
+			want: subTree(testTree, "app", "app-1.0"),
+		},
+		{
+			name: "missing remote file",
+			src:  "missing.conf", dst: "missing.conf",
+			wantErr: "missing.conf: file does not exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := t.TempDir()
+			writeTree(t, dst, tt.existing)
+
+			err := c.Download(filepath.Join(src, tt.src), filepath.Join(dst, tt.dst), CopyOptions{Recursive: tt.recursive})
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			compareTrees(t, readTree(t, dst), tt.want)
+		})
+	}
+}
+
+func TestCopyProgress(t *testing.T) {
+	c := dialTestServer(t)
+	src, dst := t.TempDir(), t.TempDir()
+	writeTree(t, src, testTree)
+
+	for name, copyFn := range map[string]func(src, dst string, opts CopyOptions) error{
+		"upload":   c.Upload,
+		"download": c.Download,
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := newRecorder()
+			if err := copyFn(filepath.Join(src, "app"), filepath.Join(dst, name), CopyOptions{Recursive: true, Progress: r}); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, f := range []string{"app/app.conf", "app/bin/run.sh"} {
+				p := filepath.Join(src, filepath.FromSlash(f))
+				size := int64(len(testTree[f].content))
+				if r.sizes[p] != size || r.added[p] != size {
+					t.Errorf("%s: size %d, added %d, want %d", f, r.sizes[p], r.added[p], size)
+				}
+				if err, ok := r.done[p]; !ok || err != nil {
+					t.Errorf("%s: done %t, %v", f, ok, err)
+				}
+			}
+			if len(r.done) != 2 {
+				t.Errorf("progress of %d files, want 2", len(r.done))
+			}
+		})
+	}
+}
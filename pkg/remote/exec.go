@@ -0,0 +1,226 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultParallel is the number of hosts a command runs on at once.
+const DefaultParallel = 10
+
+// Target is a host to run a command on.
+type Target struct {
+	Name   string
+	Config *Config
+}
+
+// ExecResult is the outcome of a command on a target.
+type ExecResult struct {
+	Name     string
+	ExitCode int
+	// Stdout and Stderr are collected only if ExecOptions has no writers.
+	Stdout, Stderr string
+	Err            error
+	Duration       time.Duration
+}
+
+// ExecOptions controls Exec.
+type ExecOptions struct {
+	Parallel int
+	// Stdout and Stderr receive the output as it comes, each line prefixed with the target name.
+	Stdout, Stderr io.Writer
+}
+
+// Exec runs the command on the targets in parallel and returns the results in the order of targets.
+// Canceling the context closes the connections.
+func Exec(ctx context.Context, targets []Target, cmd string, opts ExecOptions) []ExecResult {
+	var width int
+	for _, t := range targets {
+		width = max(width, len(t.Name))
+	}
+
+	stdout := &syncWriter{w: opts.Stdout}
+	stderr := &syncWriter{w: opts.Stderr}
+
+	results := make([]ExecResult, len(targets))
+	forEach(ctx, len(targets), opts.Parallel, func(n int) {
+		if err := ctx.Err(); err != nil {
+			results[n] = ExecResult{Name: targets[n].Name, ExitCode: ExitUnknown, Err: err}
+			return
+		}
+		results[n] = execTarget(ctx, targets[n], cmd, opts, width, stdout, stderr)
+	})
+
+	return results
+}
+
+// Each connects to the targets in parallel and calls fn with every connection.
+// It returns the errors in the order of targets. Canceling the context closes the connections.
+func Each(ctx context.Context, targets []Target, parallel int, fn func(t Target, c *Client) error) []error {
+	errs := make([]error, len(targets))
+	forEach(ctx, len(targets), parallel, func(n int) {
+		if errs[n] = ctx.Err(); errs[n] != nil {
+			return
+		}
+
+		client, err := Dial(ctx, targets[n].Config)
+		if err != nil {
+			errs[n] = err
+			return
+		}
+		defer client.Close()
+
+		stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+		defer stop()
+
+		if errs[n] = fn(targets[n], client); ctx.Err() != nil {
+			errs[n] = ctx.Err()
+		}
+	})
+
+	return errs
+}
+
+// forEach calls fn for 0..n-1, at most parallel at once.
+func forEach(ctx context.Context, n, parallel int, fn func(i int)) {
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+			}
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func execTarget(
+	ctx context.Context,
+	t Target,
+	cmd string,
+	opts ExecOptions,
+	width int,
+	stdout, stderr *syncWriter,
+) (res ExecResult) {
+	res = ExecResult{Name: t.Name, ExitCode: ExitUnknown}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	client, err := Dial(ctx, t.Config)
+	if err != nil {
+		res.Err = err
+		return
+	}
+	defer client.Close()
+
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	defer stop()
+
+	var outBuf, errBuf bytes.Buffer
+	var outW, errW io.Writer = &outBuf, &errBuf
+	if opts.Stdout != nil {
+		pw := newPrefixWriter(stdout, t.Name, width)
+		defer pw.Flush()
+		outW = pw
+	}
+	if opts.Stderr != nil {
+		pw := newPrefixWriter(stderr, t.Name, width)
+		defer pw.Flush()
+		errW = pw
+	}
+
+	res.ExitCode, res.Err = client.Run(cmd, nil, outW, errW)
+	if ctx.Err() != nil {
+		res.Err = ctx.Err()
+	}
+	res.Stdout, res.Stderr = outBuf.String(), errBuf.String()
+
+	return
+}
+
+// syncWriter serializes the writes of the prefix writers, so lines of the targets don't mix.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.w.Write(p)
+}
+
+// prefixWriter writes whole lines, each prefixed with the padded name.
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, name string, width int) *prefixWriter {
+	prefix := make([]byte, 0, width+3)
+	prefix = append(prefix, name...)
+	for len(prefix) < width {
+		prefix = append(prefix, ' ')
+	}
+
+	return &prefixWriter{w: w, prefix: append(prefix, " | "...)}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return len(p), err
+		}
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// Flush writes the last line if it has no line break.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		_ = w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	_, err := w.w.Write(append(append([]byte{}, w.prefix...), line...))
+	return err
+}
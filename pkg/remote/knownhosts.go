@@ -0,0 +1,93 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ErrHostKeyChanged is returned if the host presents a key other than the known one.
+var ErrHostKeyChanged = errors.New("host key has changed")
+
+// KnownHosts returns a host key callback that trusts a host on first use.
+// The key of an unknown host is added to the file, a changed key is an error.
+// The callback is safe for concurrent use.
+func KnownHosts(path string) (ssh.HostKeyCallback, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+
+	known, err := knownhosts.New(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	added := make(map[string]ssh.PublicKey)
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		host := knownhosts.Normalize(hostname)
+		if k, ok := added[host]; ok {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", host, ErrHostKeyChanged)
+		}
+
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("%s: %w, if the instance was recreated remove line %d of %s",
+				host, ErrHostKeyChanged, keyErr.Want[0].Line, keyErr.Want[0].Filename)
+		}
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err = fmt.Fprintln(f, knownhosts.Line([]string{host}, key)); err != nil {
+			return err
+		}
+		added[host] = key
+		log.Warnf("Permanently added %s (%s) to the list of known hosts", host, key.Type())
+
+		return nil
+	}, nil
+}
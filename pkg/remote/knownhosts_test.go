@@ -0,0 +1,124 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKnownHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ks", "known_hosts")
+	addr := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	key, changed := newSigner(t).PublicKey(), newSigner(t).PublicKey()
+
+	cb, err := KnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trusted on first use and added to the file.
+	if err = cb("10.0.0.1:22", addr, key); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], "10.0.0.1 ") {
+		t.Errorf("known_hosts = %q, want a line of 10.0.0.1", b)
+	}
+
+	if err = cb("10.0.0.1:22", addr, key); err != nil {
+		t.Errorf("known key: %v", err)
+	}
+	if err = cb("10.0.0.1:22", addr, changed); !errors.Is(err, ErrHostKeyChanged) {
+		t.Errorf("changed key: %v, want %v", err, ErrHostKeyChanged)
+	}
+	if err = cb("10.0.0.1:2222", addr, changed); err != nil {
+		t.Errorf("other port: %v", err)
+	}
+
+	// A new callback reads the keys added before.
+	cb, err = KnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cb("10.0.0.1:22", addr, key); err != nil {
+		t.Errorf("known key from the file: %v", err)
+	}
+	err = cb("10.0.0.1:22", addr, changed)
+	if !errors.Is(err, ErrHostKeyChanged) {
+		t.Fatalf("changed key from the file: %v, want %v", err, ErrHostKeyChanged)
+	}
+	if !strings.Contains(err.Error(), "remove line 1 of "+path) {
+		t.Errorf("error %q doesn't point to the line", err)
+	}
+	if err = cb("[10.0.0.1]:2222", addr, changed); err != nil {
+		t.Errorf("other port from the file: %v", err)
+	}
+}
+
+func TestDialKnownHosts(t *testing.T) {
+	key := newSigner(t)
+	srv := newTestServer(t, key)
+	path := filepath.Join(t.TempDir(), "known_hosts")
+
+	for i := 0; i < 2; i++ {
+		cb, err := KnownHosts(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := srv.config("ubuntu", key)
+		cfg.HostKeyCallback = cb
+
+		c, err := Dial(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		_ = c.Close()
+	}
+
+	// The instance was recreated with a new host key at an address known with the old one.
+	recreated := newTestServer(t, key)
+	cb, err := KnownHosts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := net.ResolveTCPAddr("tcp", recreated.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cb(recreated.addr, remote, srv.hostKey.PublicKey()); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := recreated.config("ubuntu", key)
+	cfg.HostKeyCallback = cb
+	c, err := Dial(context.Background(), cfg)
+	if !errors.Is(err, ErrHostKeyChanged) {
+		if c != nil {
+			_ = c.Close()
+		}
+		t.Errorf("dial the recreated instance: %v, want %v", err, ErrHostKeyChanged)
+	}
+}
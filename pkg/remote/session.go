@@ -0,0 +1,104 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// ExitUnknown is the exit status when the command didn't report one, e.g. the connection was lost.
+const ExitUnknown = 255
+
+// Run runs the command and returns its exit status.
+func (c *Client) Run(cmd string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	s, err := c.NewSession()
+	if err != nil {
+		return ExitUnknown, err
+	}
+	defer s.Close()
+
+	s.Stdin, s.Stdout, s.Stderr = stdin, stdout, stderr
+
+	return exitStatus(s.Run(cmd))
+}
+
+// Shell starts the login shell, or runs the command if it is not empty.
+// If stdin is a terminal it is switched to raw mode and the session gets a PTY of the same size.
+func (c *Client) Shell(cmd string, stdin *os.File, stdout, stderr io.Writer) (int, error) {
+	s, err := c.NewSession()
+	if err != nil {
+		return ExitUnknown, err
+	}
+	defer s.Close()
+
+	fd := int(stdin.Fd())
+	if term.IsTerminal(fd) {
+		w, h, err := term.GetSize(fd)
+		if err != nil {
+			return ExitUnknown, err
+		}
+
+		termType := os.Getenv("TERM")
+		if len(termType) == 0 {
+			termType = "xterm-256color"
+		}
+		if err = s.RequestPty(termType, h, w, ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}); err != nil {
+			return ExitUnknown, err
+		}
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return ExitUnknown, err
+		}
+		defer term.Restore(fd, state)
+
+		stop := watchResize(fd, s)
+		defer stop()
+	}
+
+	s.Stdin, s.Stdout, s.Stderr = stdin, stdout, stderr
+
+	if len(cmd) > 0 {
+		return exitStatus(s.Run(cmd))
+	}
+	if err = s.Shell(); err != nil {
+		return ExitUnknown, err
+	}
+
+	return exitStatus(s.Wait())
+}
+
+func exitStatus(err error) (int, error) {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return ExitUnknown, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PollInterval is the delay between connection attempts and status checks.
+const PollInterval = 2 * time.Second
+
+// DialWait retries Dial until it succeeds or the context is done.
+// Early in the boot sshd may refuse connections and the user may not exist yet,
+// so every error is retried except a changed host key.
+func DialWait(ctx context.Context, cfg *Config) (*Client, error) {
+	for {
+		c, err := Dial(ctx, cfg)
+		if err == nil {
+			return c, nil
+		}
+		if errors.Is(err, ErrHostKeyChanged) {
+			return nil, err
+		}
+		log.Debugf("%s, retrying", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(PollInterval):
+		}
+	}
+}
+
+// CloudInitStatus returns the status reported by `cloud-init status`, e.g. "running", "done" or "error".
+func (c *Client) CloudInitStatus() (string, error) {
+	var out bytes.Buffer
+	// The exit code is not zero for failed and degraded runs, the status is printed anyway.
+	if _, err := c.Run("cloud-init status", nil, &out, io.Discard); err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		if st, ok := strings.CutPrefix(line, "status:"); ok {
+			return strings.TrimSpace(st), nil
+		}
+	}
+
+	return "", fmt.Errorf("unexpected output of cloud-init status: %q", out.String())
+}
+
+// WaitCloudInit polls cloud-init until it has finished.
+func (c *Client) WaitCloudInit(ctx context.Context) error {
+	for {
+		st, err := c.CloudInitStatus()
+		if err != nil {
+			return err
+		}
+
+		switch st {
+		case "done", "disabled":
+			return nil
+		case "error":
+			return errors.New("cloud-init failed, see /var/log/cloud-init-output.log")
+		}
+		log.Debugf("cloud-init status: %s", st)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: cloud-init status: %s", ctx.Err(), st)
+		case <-time.After(PollInterval):
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed kubectl-style JSONPath template, e.g. `{.name} {.labels.env}`.
+// Text outside of braces is printed as is, braces contain either a path or a quoted string.
+// A path is a sequence of `.key`, `['key']`, `[index]`, `[*]` and `.*` selectors
+// applied to a value decoded from JSON.
+type JSONPath struct {
+	parts []jsonPathPart
+}
+
+type jsonPathPart struct {
+	text  string
+	path  []jsonPathStep
+	isRaw bool
+}
+
+type jsonPathStep struct {
+	key   string
+	index int
+	all   bool
+	isKey bool
+}
+
+// ParseJSONPath parses a template. A bare path without braces is accepted as well.
+func ParseJSONPath(tpl string) (*JSONPath, error) {
+	if !strings.Contains(tpl, "{") {
+		tpl = "{" + tpl + "}"
+	}
+
+	jp := &JSONPath{}
+	for len(tpl) > 0 {
+		start := strings.IndexByte(tpl, '{')
+		if start < 0 {
+			jp.parts = append(jp.parts, jsonPathPart{text: tpl, isRaw: true})
+			break
+		}
+		if start > 0 {
+			jp.parts = append(jp.parts, jsonPathPart{text: tpl[:start], isRaw: true})
+		}
+
+		end := strings.IndexByte(tpl[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("jsonpath %q: unclosed {", tpl)
+		}
+		expr := strings.TrimSpace(tpl[start+1 : start+end])
+		tpl = tpl[start+end+1:]
+
+		if strings.HasPrefix(expr, `"`) {
+			s, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath: invalid string %s", expr)
+			}
+			jp.parts = append(jp.parts, jsonPathPart{text: s, isRaw: true})
+			continue
+		}
+
+		path, err := parseJSONPathSteps(expr)
+		if err != nil {
+			return nil, err
+		}
+		jp.parts = append(jp.parts, jsonPathPart{text: expr, path: path})
+	}
+
+	return jp, nil
+}
+
+func parseJSONPathSteps(expr string) ([]jsonPathStep, error) {
+	s := strings.TrimPrefix(expr, "$")
+
+	var steps []jsonPathStep
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			if strings.HasPrefix(s, "*") {
+				steps = append(steps, jsonPathStep{all: true})
+				s = s[1:]
+				continue
+			}
+
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if end > 0 {
+				steps = append(steps, jsonPathStep{key: s[:end], isKey: true})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unclosed [", expr)
+			}
+			sel := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+
+			switch {
+			case sel == "*":
+				steps = append(steps, jsonPathStep{all: true})
+			case strings.HasPrefix(sel, "'") || strings.HasPrefix(sel, `"`):
+				steps = append(steps, jsonPathStep{key: strings.Trim(sel, `'"`), isKey: true})
+			default:
+				idx, err := strconv.Atoi(sel)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath %q: invalid index %q", expr, sel)
+				}
+				steps = append(steps, jsonPathStep{index: idx})
+			}
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", expr, s[0])
+		}
+	}
+
+	return steps, nil
+}
+
+// Lookup returns all values matched by the paths of the template.
+func (jp *JSONPath) Lookup(data any) []any {
+	var out []any
+	for _, part := range jp.parts {
+		if !part.isRaw {
+			out = append(out, lookup(data, part.path)...)
+		}
+	}
+
+	return out
+}
+
+// Execute renders the template. Multiple values matched by one path are separated by a space.
+func (jp *JSONPath) Execute(data any) (string, error) {
+	var sb strings.Builder
+	for _, part := range jp.parts {
+		if part.isRaw {
+			sb.WriteString(part.text)
+			continue
+		}
+
+		for i, v := range lookup(data, part.path) {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			s, err := FormatValue(v)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(s)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// FormatValue formats a value decoded from JSON: strings as is, everything else as JSON.
+func FormatValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func lookup(data any, steps []jsonPathStep) []any {
+	cur := []any{data}
+	for _, step := range steps {
+		var next []any
+		for _, v := range cur {
+			switch v := v.(type) {
+			case map[string]any:
+				if step.all {
+					keys := make([]string, 0, len(v))
+					for k := range v {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, v[k])
+					}
+				} else if x, ok := v[step.key]; ok && step.isKey {
+					next = append(next, x)
+				}
+			case []any:
+				switch {
+				case step.all:
+					next = append(next, v...)
+				case step.isKey:
+				default:
+					idx := step.index
+					if idx < 0 {
+						idx += len(v)
+					}
+					if idx >= 0 && idx < len(v) {
+						next = append(next, v[idx])
+					}
+				}
+			}
+		}
+		cur = next
+	}
+
+	return cur
+}
@@ -20,6 +20,8 @@ const Gib int64 = 1024 * 1024 * 1024
 
 func ToGib(v uint) int64 { return int64(v) * Gib }
 
+func Ptr[T any](v T) *T { return &v }
+
 func AllInMap[T comparable](m1, m2 map[string]T) bool {
 	if len(m2) == 0 {
 		return true
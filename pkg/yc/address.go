@@ -0,0 +1,150 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"net"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// VPCAddressReserve reserves a static public IPv4 address in the zone.
+func (c *Client) VPCAddressReserve(
+	ctx context.Context,
+	folderID, zone, name string,
+	labels map[string]string,
+) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	if len(zone) == 0 {
+		zone = DefaultZone
+	}
+
+	return c.wrapOperation(c.backend.Address().Create(cctx, &vpc.CreateAddressRequest{
+		FolderId: folderID,
+		Name:     name,
+		Labels:   labels,
+		AddressSpec: &vpc.CreateAddressRequest_ExternalIpv4AddressSpec{
+			ExternalIpv4AddressSpec: &vpc.ExternalIpv4AddressSpec{ZoneId: zone},
+		},
+	}))
+}
+
+// VPCAddressRelease deletes the address. Addresses used by an instance can't be released.
+func (c *Client) VPCAddressRelease(ctx context.Context, id string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Address().Delete(cctx, &vpc.DeleteAddressRequest{AddressId: id}))
+}
+
+// VPCAddressList returns all addresses of the folder, including the ephemeral ones used by instances.
+func (c *Client) VPCAddressList(ctx context.Context, folderID string) ([]*vpc.Address, error) {
+	req := &vpc.ListAddressesRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*vpc.Address
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.Address().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Addresses...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// VPCAddressResolve finds the address of the folder referenced by name, ID or the IP itself.
+func (c *Client) VPCAddressResolve(ctx context.Context, folderID, ref string) (*vpc.Address, error) {
+	if net.ParseIP(ref) != nil {
+		return c.vpcAddressByValue(ctx, ref)
+	}
+
+	lst, err := c.VPCAddressList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOne("address", folderID, ref, lst, func(a *vpc.Address) (string, string) { return a.Id, a.Name })
+}
+
+// VPCAddressPromote makes the ephemeral address ip static, so it is kept when the instance
+// is stopped or deleted. Nothing is done if the address is reserved already.
+func (c *Client) VPCAddressPromote(
+	ctx context.Context,
+	ip, name string,
+	labels map[string]string,
+) (*vpc.Address, *operation.Operation, error) {
+	address, err := c.vpcAddressByValue(ctx, ip)
+	if err != nil {
+		return nil, nil, err
+	}
+	if address.Reserved {
+		return address, nil, nil
+	}
+
+	paths := []string{"reserved", "labels"}
+	if len(name) > 0 && len(address.Name) == 0 {
+		paths = append(paths, "name")
+	}
+
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for k, v := range address.Labels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	op, err := c.wrapOperation(c.backend.Address().Update(cctx, &vpc.UpdateAddressRequest{
+		AddressId:  address.Id,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+		Name:       name,
+		Labels:     labels,
+		Reserved:   true,
+	}))
+
+	return address, op, err
+}
+
+func (c *Client) vpcAddressByValue(ctx context.Context, ip string) (*vpc.Address, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.backend.Address().GetByValue(cctx, &vpc.GetAddressByValueRequest{
+		Address: &vpc.GetAddressByValueRequest_ExternalIpv4Address{ExternalIpv4Address: ip},
+	})
+}
+
+// AddressIP returns the IP of the address.
+func AddressIP(a *vpc.Address) string {
+	return a.GetExternalIpv4Address().GetAddress()
+}
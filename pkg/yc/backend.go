@@ -0,0 +1,189 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	ycsdk "github.com/yandex-cloud/go-sdk"
+	"google.golang.org/grpc"
+)
+
+// Backend provides the Yandex Cloud services used by Client.
+// The SDK clients satisfy it directly; see pkg/yc/fake for an in-memory implementation.
+type Backend interface {
+	Address() AddressService
+	Disk() DiskService
+	DnsZone() DnsZoneService
+	Gateway() GatewayService
+	Image() ImageService
+	Instance() InstanceService
+	InstanceGroup() InstanceGroupService
+	Network() NetworkService
+	RouteTable() RouteTableService
+	SecurityGroup() SecurityGroupService
+	Subnet() SubnetService
+	ServiceAccount() ServiceAccountService
+	Operation() OperationService
+	Snapshot() SnapshotService
+}
+
+type AddressService interface {
+	Create(ctx context.Context, in *vpc.CreateAddressRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *vpc.DeleteAddressRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *vpc.GetAddressRequest, opts ...grpc.CallOption) (*vpc.Address, error)
+	GetByValue(ctx context.Context, in *vpc.GetAddressByValueRequest, opts ...grpc.CallOption) (*vpc.Address, error)
+	List(ctx context.Context, in *vpc.ListAddressesRequest, opts ...grpc.CallOption) (*vpc.ListAddressesResponse, error)
+	Update(ctx context.Context, in *vpc.UpdateAddressRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type DiskService interface {
+	Create(ctx context.Context, in *compute.CreateDiskRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *compute.DeleteDiskRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *compute.GetDiskRequest, opts ...grpc.CallOption) (*compute.Disk, error)
+	List(ctx context.Context, in *compute.ListDisksRequest, opts ...grpc.CallOption) (*compute.ListDisksResponse, error)
+	Update(ctx context.Context, in *compute.UpdateDiskRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type DnsZoneService interface {
+	Create(ctx context.Context, in *dns.CreateDnsZoneRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *dns.GetDnsZoneRequest, opts ...grpc.CallOption) (*dns.DnsZone, error)
+	List(ctx context.Context, in *dns.ListDnsZonesRequest, opts ...grpc.CallOption) (*dns.ListDnsZonesResponse, error)
+	ListRecordSets(ctx context.Context, in *dns.ListDnsZoneRecordSetsRequest, opts ...grpc.CallOption) (*dns.ListDnsZoneRecordSetsResponse, error)
+	UpsertRecordSets(ctx context.Context, in *dns.UpsertRecordSetsRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type GatewayService interface {
+	Create(ctx context.Context, in *vpc.CreateGatewayRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *vpc.DeleteGatewayRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *vpc.GetGatewayRequest, opts ...grpc.CallOption) (*vpc.Gateway, error)
+	List(ctx context.Context, in *vpc.ListGatewaysRequest, opts ...grpc.CallOption) (*vpc.ListGatewaysResponse, error)
+}
+
+type ImageService interface {
+	Create(ctx context.Context, in *compute.CreateImageRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *compute.GetImageRequest, opts ...grpc.CallOption) (*compute.Image, error)
+	GetLatestByFamily(ctx context.Context, in *compute.GetImageLatestByFamilyRequest, opts ...grpc.CallOption) (*compute.Image, error)
+	List(ctx context.Context, in *compute.ListImagesRequest, opts ...grpc.CallOption) (*compute.ListImagesResponse, error)
+}
+
+type InstanceService interface {
+	AttachDisk(ctx context.Context, in *compute.AttachInstanceDiskRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Create(ctx context.Context, in *compute.CreateInstanceRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *compute.DeleteInstanceRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	DetachDisk(ctx context.Context, in *compute.DetachInstanceDiskRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *compute.GetInstanceRequest, opts ...grpc.CallOption) (*compute.Instance, error)
+	GetSerialPortOutput(ctx context.Context, in *compute.GetInstanceSerialPortOutputRequest, opts ...grpc.CallOption) (*compute.GetInstanceSerialPortOutputResponse, error)
+	List(ctx context.Context, in *compute.ListInstancesRequest, opts ...grpc.CallOption) (*compute.ListInstancesResponse, error)
+	Start(ctx context.Context, in *compute.StartInstanceRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Stop(ctx context.Context, in *compute.StopInstanceRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Update(ctx context.Context, in *compute.UpdateInstanceRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type InstanceGroupService interface {
+	Create(ctx context.Context, in *instancegroup.CreateInstanceGroupRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *instancegroup.DeleteInstanceGroupRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *instancegroup.GetInstanceGroupRequest, opts ...grpc.CallOption) (*instancegroup.InstanceGroup, error)
+	List(ctx context.Context, in *instancegroup.ListInstanceGroupsRequest, opts ...grpc.CallOption) (*instancegroup.ListInstanceGroupsResponse, error)
+	ListInstances(ctx context.Context, in *instancegroup.ListInstanceGroupInstancesRequest, opts ...grpc.CallOption) (*instancegroup.ListInstanceGroupInstancesResponse, error)
+	Update(ctx context.Context, in *instancegroup.UpdateInstanceGroupRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type NetworkService interface {
+	Create(ctx context.Context, in *vpc.CreateNetworkRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *vpc.DeleteNetworkRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *vpc.GetNetworkRequest, opts ...grpc.CallOption) (*vpc.Network, error)
+	List(ctx context.Context, in *vpc.ListNetworksRequest, opts ...grpc.CallOption) (*vpc.ListNetworksResponse, error)
+}
+
+type RouteTableService interface {
+	Create(ctx context.Context, in *vpc.CreateRouteTableRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *vpc.DeleteRouteTableRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *vpc.GetRouteTableRequest, opts ...grpc.CallOption) (*vpc.RouteTable, error)
+	List(ctx context.Context, in *vpc.ListRouteTablesRequest, opts ...grpc.CallOption) (*vpc.ListRouteTablesResponse, error)
+	Update(ctx context.Context, in *vpc.UpdateRouteTableRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type SecurityGroupService interface {
+	Create(ctx context.Context, in *vpc.CreateSecurityGroupRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *vpc.DeleteSecurityGroupRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *vpc.GetSecurityGroupRequest, opts ...grpc.CallOption) (*vpc.SecurityGroup, error)
+	List(ctx context.Context, in *vpc.ListSecurityGroupsRequest, opts ...grpc.CallOption) (*vpc.ListSecurityGroupsResponse, error)
+	UpdateRules(ctx context.Context, in *vpc.UpdateSecurityGroupRulesRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type SubnetService interface {
+	Create(ctx context.Context, in *vpc.CreateSubnetRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *vpc.DeleteSubnetRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *vpc.GetSubnetRequest, opts ...grpc.CallOption) (*vpc.Subnet, error)
+	List(ctx context.Context, in *vpc.ListSubnetsRequest, opts ...grpc.CallOption) (*vpc.ListSubnetsResponse, error)
+	Update(ctx context.Context, in *vpc.UpdateSubnetRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+}
+
+type SnapshotService interface {
+	Create(ctx context.Context, in *compute.CreateSnapshotRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Delete(ctx context.Context, in *compute.DeleteSnapshotRequest, opts ...grpc.CallOption) (*operation.Operation, error)
+	Get(ctx context.Context, in *compute.GetSnapshotRequest, opts ...grpc.CallOption) (*compute.Snapshot, error)
+	List(ctx context.Context, in *compute.ListSnapshotsRequest, opts ...grpc.CallOption) (*compute.ListSnapshotsResponse, error)
+}
+
+type ServiceAccountService interface {
+	List(ctx context.Context, in *iam.ListServiceAccountsRequest, opts ...grpc.CallOption) (*iam.ListServiceAccountsResponse, error)
+}
+
+// OperationService polls long-running operations returned by the other services.
+type OperationService = operation.OperationServiceClient
+
+type sdkBackend struct {
+	sdk *ycsdk.SDK
+}
+
+func (b sdkBackend) Address() AddressService { return b.sdk.VPC().Address() }
+
+func (b sdkBackend) Disk() DiskService { return b.sdk.Compute().Disk() }
+
+func (b sdkBackend) DnsZone() DnsZoneService { return b.sdk.DNS().DnsZone() }
+
+func (b sdkBackend) Gateway() GatewayService { return b.sdk.VPC().Gateway() }
+
+func (b sdkBackend) Image() ImageService { return b.sdk.Compute().Image() }
+
+func (b sdkBackend) Instance() InstanceService { return b.sdk.Compute().Instance() }
+
+func (b sdkBackend) InstanceGroup() InstanceGroupService {
+	return b.sdk.InstanceGroup().InstanceGroup()
+}
+
+func (b sdkBackend) Network() NetworkService { return b.sdk.VPC().Network() }
+
+func (b sdkBackend) RouteTable() RouteTableService { return b.sdk.VPC().RouteTable() }
+
+func (b sdkBackend) SecurityGroup() SecurityGroupService { return b.sdk.VPC().SecurityGroup() }
+
+func (b sdkBackend) Subnet() SubnetService { return b.sdk.VPC().Subnet() }
+
+func (b sdkBackend) ServiceAccount() ServiceAccountService { return b.sdk.IAM().ServiceAccount() }
+
+func (b sdkBackend) Operation() OperationService { return b.sdk.Operation() }
+
+func (b sdkBackend) Snapshot() SnapshotService { return b.sdk.Compute().Snapshot() }
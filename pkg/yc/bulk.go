@@ -0,0 +1,164 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultParallel is the default number of operations a bulk run keeps in flight.
+const DefaultParallel = 5
+
+// BulkTask is an operation on a single resource.
+type BulkTask struct {
+	ID   string
+	Name string
+	Run  func(ctx context.Context) (*operation.Operation, error)
+}
+
+// BulkResult is the outcome of a BulkTask.
+type BulkResult struct {
+	ID          string
+	Name        string
+	OperationID string
+	// Response is the operation response, nil if the operation was not waited for.
+	Response proto.Message
+	Err      error
+	Duration time.Duration
+}
+
+// BulkProgress is notified about the tasks of a bulk run. Calls may come from several goroutines.
+type BulkProgress interface {
+	Start(task BulkTask)
+	Done(result BulkResult)
+}
+
+type BulkOptions struct {
+	// Parallel limits the number of tasks running at once, DefaultParallel if not positive.
+	Parallel int
+	// NoWait makes tasks done as soon as the operation is accepted.
+	NoWait   bool
+	Progress BulkProgress
+}
+
+// RunBulk runs the tasks concurrently, waiting every operation independently.
+// Results are in the order of the tasks.
+func RunBulk(ctx context.Context, tasks []BulkTask, opts BulkOptions) []BulkResult {
+	parallel := opts.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+
+	results := make([]BulkResult, len(tasks))
+	sem := make(chan struct{}, parallel)
+
+	var wg sync.WaitGroup
+	for idx, task := range tasks {
+		wg.Add(1)
+		go func(idx int, task BulkTask) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[idx] = BulkResult{ID: task.ID, Name: task.Name, Err: ctx.Err()}
+				if opts.Progress != nil {
+					opts.Progress.Done(results[idx])
+				}
+				return
+			}
+
+			if opts.Progress != nil {
+				opts.Progress.Start(task)
+			}
+
+			results[idx] = runBulkTask(ctx, task, opts.NoWait)
+
+			if opts.Progress != nil {
+				opts.Progress.Done(results[idx])
+			}
+		}(idx, task)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func runBulkTask(ctx context.Context, task BulkTask, noWait bool) (res BulkResult) {
+	start := time.Now()
+	res = BulkResult{ID: task.ID, Name: task.Name}
+	defer func() { res.Duration = time.Since(start) }()
+
+	op, err := task.Run(ctx)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.OperationID = op.Id()
+
+	if noWait {
+		return res
+	}
+
+	if err = op.Wait(ctx); err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.Response, res.Err = op.Response()
+	return res
+}
+
+// BulkFailed returns the number of failed results.
+func BulkFailed(results []BulkResult) int {
+	var n int
+	for _, res := range results {
+		if res.Err != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ComputeInstanceTasks makes a task applying fn, e.g. Client.ComputeInstanceStop, to every instance.
+func ComputeInstanceTasks(
+	lst []*compute.Instance,
+	fn func(ctx context.Context, id string) (*operation.Operation, error),
+) []BulkTask {
+	tasks := make([]BulkTask, 0, len(lst))
+	for _, i := range lst {
+		id := i.Id
+		tasks = append(tasks, BulkTask{
+			ID:   id,
+			Name: instanceName(i),
+			Run: func(ctx context.Context) (*operation.Operation, error) {
+				return fn(ctx, id)
+			},
+		})
+	}
+
+	return tasks
+}
@@ -18,23 +18,23 @@ package yc
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/remote"
 	"github.com/ks-tool/ks/pkg/utils"
 
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
-	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
 	"github.com/yandex-cloud/go-sdk/operation"
 
 	"github.com/mitchellh/go-homedir"
-	"golang.org/x/crypto/ssh"
 )
 
 var (
@@ -83,8 +83,79 @@ type Filter struct {
 	Value    any
 }
 
+// values returns the value of the filter as a list.
+func (f Filter) values() []string {
+	switch v := f.Value.(type) {
+	case []string:
+		return v
+	case string:
+		return []string{v}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// Validate checks that = and != have a single value and IN and NOT IN at least one.
+func (f Filter) Validate() error {
+	n := len(f.values())
+	switch f.Operator {
+	case OperatorEq, OperatorNe:
+		if n != 1 {
+			return fmt.Errorf("filter %s %s takes one value, got %d", f.Field, f.Operator, n)
+		}
+	case OperatorIn, OperatorNotIn:
+		if n == 0 {
+			return fmt.Errorf("filter %s %s takes at least one value", f.Field, f.Operator)
+		}
+	default:
+		return fmt.Errorf("filter %s: unknown operator %q", f.Field, f.Operator)
+	}
+
+	return nil
+}
+
+// String renders the filter in the API syntax. IN and NOT IN take a []string value.
+// The filter must be valid, see Validate.
 func (f Filter) String() string {
-	return fmt.Sprintf(`%s %s %q`, f.Field, f.Operator, f.Value)
+	values := f.values()
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, strconv.Quote(v))
+	}
+
+	if f.Operator == OperatorIn || f.Operator == OperatorNotIn {
+		return fmt.Sprintf(`%s %s (%s)`, f.Field, f.Operator, strings.Join(quoted, ", "))
+	}
+
+	return fmt.Sprintf(`%s %s %s`, f.Field, f.Operator, strings.Join(quoted, ", "))
+}
+
+// LabelFilters translates required labels into `labels.<key> = "<value>"` filters.
+func LabelFilters(lbl map[string]string) []Filter {
+	keys := make([]string, 0, len(lbl))
+	for k := range lbl {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := make([]Filter, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, Filter{Field: "labels." + k, Operator: OperatorEq, Value: lbl[k]})
+	}
+
+	return out
+}
+
+func joinFilters(filters []Filter) (string, error) {
+	out := make([]string, 0, len(filters))
+	for _, f := range filters {
+		if err := f.Validate(); err != nil {
+			return "", err
+		}
+		out = append(out, f.String())
+	}
+
+	return strings.Join(out, " AND "), nil
 }
 
 type ComputeInstanceConfig struct {
@@ -96,6 +167,12 @@ type ComputeInstanceConfig struct {
 	Zone     string `mapstructure:"zone"`
 	Address  string `mapstructure:"address"`
 
+	// SubnetLabels (key=value) and SubnetCIDR pick the subnet of the zone unless SubnetID is given.
+	SubnetLabels []string `mapstructure:"subnet-label"`
+	SubnetCIDR   string   `mapstructure:"subnet-cidr"`
+	// AutoNetwork creates the ks network and its subnets if the zone has no subnet.
+	AutoNetwork bool `mapstructure:"auto-network"`
+
 	Cores        uint `mapstructure:"cores"`
 	CoreFraction uint `mapstructure:"core-fraction"`
 	Memory       uint `mapstructure:"memory"`
@@ -104,6 +181,19 @@ type ComputeInstanceConfig struct {
 	DiskID   string `mapstructure:"disk-id"`
 	DiskSize uint   `mapstructure:"disk-size"`
 
+	// ImageFamily is resolved to the latest image of the family unless DiskID is given.
+	ImageFamily   string `mapstructure:"image-family"`
+	ImageFolderID string `mapstructure:"image-folder"`
+
+	SecondaryDisks []string `mapstructure:"secondary-disk"`
+	// NetworkInterfaces replace the interface given by SubnetID, Address and NoPublicIP.
+	NetworkInterfaces []string `mapstructure:"network-interface"`
+
+	// SecurityGroups (names or IDs) and the groups of SecurityGroupPresets are attached
+	// to the network interfaces without security-group-ids.
+	SecurityGroups       []string `mapstructure:"security-group"`
+	SecurityGroupPresets []string `mapstructure:"security-group-preset"`
+
 	Preemptible    bool   `mapstructure:"preemptible"`
 	NoPublicIP     bool   `mapstructure:"no-public-ip"`
 	ServiceAccount string `mapstructure:"sa"`
@@ -131,10 +221,23 @@ func (cfg *ComputeInstanceConfig) SetUserData(tpl string) error {
 		cfg.Metadata = make(map[string]string)
 	}
 	if _, ok := cfg.Metadata[common.UserDataKey]; !ok {
+		disks, err := cfg.secondaryDisks()
+		if err != nil {
+			return err
+		}
+
+		var mounts []SecondaryDisk
+		for _, d := range disks {
+			if len(d.Mount) > 0 {
+				mounts = append(mounts, d)
+			}
+		}
+
 		userData, err := utils.Template(tpl, map[string]any{
 			"user":              cfg.User,
 			"sshAuthorizedKeys": cfg.SshAuthorizedKeys,
 			"shell":             cfg.Shell,
+			"mounts":            mounts,
 		})
 		if err != nil {
 			return err
@@ -146,84 +249,112 @@ func (cfg *ComputeInstanceConfig) SetUserData(tpl string) error {
 	return nil
 }
 
-func (cfg *ComputeInstanceConfig) fillSshKeys() error {
-	if len(cfg.SshAuthorizedKeys) > 0 {
-		return nil
+func (cfg *ComputeInstanceConfig) secondaryDisks() ([]SecondaryDisk, error) {
+	out := make([]SecondaryDisk, 0, len(cfg.SecondaryDisks))
+	devices := make(map[string]struct{})
+	for _, spec := range cfg.SecondaryDisks {
+		d, err := ParseSecondaryDisk(spec)
+		if err != nil {
+			return nil, err
+		}
+		if len(d.DeviceName) > 0 {
+			if _, ok := devices[d.DeviceName]; ok {
+				return nil, fmt.Errorf("secondary disk name %q is used twice", d.DeviceName)
+			}
+			devices[d.DeviceName] = struct{}{}
+		}
+		out = append(out, d)
 	}
 
-	usr, err := user.Current()
-	if err != nil {
-		return err
+	return out, nil
+}
+
+// subnetInZone returns the preferred subnet of the zone, creating the auto network first if needed.
+func (c *Client) subnetInZone(ctx context.Context, cfg *ComputeInstanceConfig, pref SubnetPreference) (string, error) {
+	id, err := c.FirstSubnetInZone(ctx, cfg.FolderID, cfg.Zone, pref)
+	if !errors.Is(err, ErrNoSubnet) || !cfg.AutoNetwork {
+		return id, err
 	}
 
-	if len(cfg.SshPublicKeyFiles) > 0 {
-		for _, key := range cfg.SshPublicKeyFiles {
-			key, err = homedir.Expand(key)
-			if err != nil {
-				return err
-			}
-			b, err := os.ReadFile(key)
-			if err != nil {
-				return err
-			}
+	if _, _, err = c.EnsureAutoNetwork(ctx, cfg.FolderID); err != nil {
+		return "", err
+	}
 
-			keyData := strings.TrimSpace(string(b))
-			keyParts := strings.Split(keyData, " ")
-			cfg.SshAuthorizedKeys = append(cfg.SshAuthorizedKeys, strings.Join(keyParts[:2], " "))
-		}
+	return c.FirstSubnetInZone(ctx, cfg.FolderID, cfg.Zone, pref)
+}
 
-		return nil
+// networkInterfaces parses the network interfaces. Their subnet defaults to SubnetID.
+func (cfg *ComputeInstanceConfig) networkInterfaces() ([]NetworkInterface, error) {
+	if len(cfg.NetworkInterfaces) == 0 {
+		return []NetworkInterface{{
+			SubnetID:   cfg.SubnetID,
+			NAT:        !cfg.NoPublicIP,
+			NATAddress: cfg.Address,
+		}}, nil
 	}
 
-	sshDir := filepath.Join(usr.HomeDir, ".ssh")
-	dir, err := os.ReadDir(sshDir)
-	if err != nil {
-		return err
+	out := make([]NetworkInterface, 0, len(cfg.NetworkInterfaces))
+	for _, spec := range cfg.NetworkInterfaces {
+		nic, err := ParseNetworkInterface(spec)
+		if err != nil {
+			return nil, err
+		}
+		if len(nic.SubnetID) == 0 {
+			nic.SubnetID = cfg.SubnetID
+		}
+		out = append(out, nic)
 	}
 
-	for _, file := range dir {
-		filename := file.Name()
-		if file.IsDir() || filename == "config" || strings.HasPrefix(filename, "known_hosts") {
-			continue
-		}
+	return out, nil
+}
+
+func (cfg *ComputeInstanceConfig) fillSshKeys() error {
+	if len(cfg.SshAuthorizedKeys) > 0 {
+		return nil
+	}
 
-		b, err := os.ReadFile(filepath.Join(sshDir, filename))
+	files := cfg.SshPublicKeyFiles
+	if len(files) == 0 {
+		usr, err := user.Current()
 		if err != nil {
 			return err
 		}
 
-		keyData := strings.TrimSpace(string(b))
-		if strings.Contains(keyData, "PRIVATE KEY") {
-			continue
+		if files, err = remote.PublicKeyFiles(filepath.Join(usr.HomeDir, ".ssh")); err != nil {
+			return err
 		}
+	}
 
-		keyParts := strings.Split(keyData, " ")
-		if len(keyParts) < 2 {
-			continue
+	for _, key := range files {
+		key, err := homedir.Expand(key)
+		if err != nil {
+			return err
 		}
-
-		keyBytes, err := base64.StdEncoding.DecodeString(keyParts[1])
+		b, err := os.ReadFile(key)
 		if err != nil {
-			continue
+			return err
 		}
 
-		if _, err := ssh.ParsePublicKey(keyBytes); err == nil {
-			key := strings.Join(keyParts[:2], " ")
-			cfg.SshAuthorizedKeys = append(cfg.SshAuthorizedKeys, key)
-		}
+		keyData := strings.TrimSpace(string(b))
+		keyParts := strings.Split(keyData, " ")
+		cfg.SshAuthorizedKeys = append(cfg.SshAuthorizedKeys, strings.Join(keyParts[:2], " "))
 	}
 
 	return nil
 }
 
-func (c *Client) ComputeInstanceCreate(ctx context.Context, cfg *ComputeInstanceConfig) (*operation.Operation, error) {
+// setDefaults fills the zone and the platform if they are not set.
+func (cfg *ComputeInstanceConfig) setDefaults() {
 	if len(cfg.Zone) == 0 {
 		cfg.Zone = DefaultZone
 	}
 	if len(cfg.PlatformID) == 0 {
 		cfg.PlatformID = DefaultPlatformID
 	}
+}
 
+// resourcesSpec returns the resources of the instance, the defaults for the ones not set.
+func (cfg *ComputeInstanceConfig) resourcesSpec() *compute.ResourcesSpec {
 	computeResources := &compute.ResourcesSpec{
 		Cores:        int64(cfg.Cores),
 		Memory:       utils.ToGib(cfg.Memory),
@@ -239,11 +370,21 @@ func (c *Client) ComputeInstanceCreate(ctx context.Context, cfg *ComputeInstance
 		computeResources.CoreFraction = DefaultCoreFraction
 	}
 
+	return computeResources
+}
+
+// bootDiskSpec returns the spec of a new boot disk from the image of the instance.
+func (c *Client) bootDiskSpec(ctx context.Context, cfg *ComputeInstanceConfig) (*compute.AttachedDiskSpec_DiskSpec, error) {
+	imageID, err := c.bootImageID(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	diskSpec := &compute.AttachedDiskSpec_DiskSpec{
 		TypeId: cfg.DiskType,
 		Size:   utils.ToGib(cfg.DiskSize),
 		Source: &compute.AttachedDiskSpec_DiskSpec_ImageId{
-			ImageId: cfg.DiskID,
+			ImageId: imageID,
 		},
 	}
 	if len(diskSpec.TypeId) == 0 {
@@ -252,26 +393,53 @@ func (c *Client) ComputeInstanceCreate(ctx context.Context, cfg *ComputeInstance
 	if diskSpec.Size == 0 {
 		diskSpec.Size = DefaultDiskSizeGib * utils.Gib
 	}
-	if len(diskSpec.GetImageId()) == 0 {
-		diskSpec.SetImageId(DefaultDiskID)
+
+	return diskSpec, nil
+}
+
+func (c *Client) ComputeInstanceCreate(ctx context.Context, cfg *ComputeInstanceConfig) (*operation.Operation, error) {
+	cfg.setDefaults()
+	computeResources := cfg.resourcesSpec()
+
+	diskSpec, err := c.bootDiskSpec(ctx, cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	networkSpec := &compute.NetworkInterfaceSpec{
-		SubnetId:             cfg.SubnetID,
-		PrimaryV4AddressSpec: &compute.PrimaryAddressSpec{},
+	disks, err := cfg.secondaryDisks()
+	if err != nil {
+		return nil, err
+	}
+	secondaryDiskSpecs := make([]*compute.AttachedDiskSpec, 0, len(disks))
+	for _, d := range disks {
+		secondaryDiskSpecs = append(secondaryDiskSpecs, d.Spec(cfg.Name))
 	}
-	if len(networkSpec.SubnetId) == 0 {
-		subnetId, err := c.FirstSubnetInZone(ctx, cfg.FolderID, cfg.Zone)
-		if err != nil {
-			return nil, err
-		}
 
-		networkSpec.SubnetId = subnetId
+	nics, err := cfg.networkInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	pref, err := ParseSubnetPreference(cfg.SubnetLabels, cfg.SubnetCIDR)
+	if err != nil {
+		return nil, err
+	}
+	networkSpecs := make([]*compute.NetworkInterfaceSpec, 0, len(nics))
+	for _, nic := range nics {
+		if len(nic.SubnetID) == 0 {
+			if nic.SubnetID, err = c.subnetInZone(ctx, cfg, pref); err != nil {
+				return nil, err
+			}
+		}
+		networkSpecs = append(networkSpecs, nic.Spec())
 	}
-	if !cfg.NoPublicIP {
-		networkSpec.PrimaryV4AddressSpec.OneToOneNatSpec = &compute.OneToOneNatSpec{
-			IpVersion: compute.IpVersion_IPV4,
-			Address:   cfg.Address,
+	if len(cfg.SecurityGroups) > 0 || len(cfg.SecurityGroupPresets) > 0 {
+		for _, spec := range networkSpecs {
+			if len(spec.SecurityGroupIds) > 0 {
+				continue
+			}
+			if spec.SecurityGroupIds, err = c.securityGroupIDs(ctx, cfg, spec.SubnetId); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -293,19 +461,19 @@ func (c *Client) ComputeInstanceCreate(ctx context.Context, cfg *ComputeInstance
 				DiskSpec: diskSpec,
 			},
 		},
-		NetworkInterfaceSpecs: []*compute.NetworkInterfaceSpec{networkSpec},
+		SecondaryDiskSpecs:    secondaryDiskSpecs,
+		NetworkInterfaceSpecs: networkSpecs,
 		SchedulingPolicy:      &compute.SchedulingPolicy{Preemptible: cfg.Preemptible},
 	}
 
 	if len(cfg.ServiceAccount) > 0 {
-		var err error
-		request.ServiceAccountId, err = c.IAMServiceAccountGetIdByName(ctx, cfg.ServiceAccount)
+		request.ServiceAccountId, err = c.IAMServiceAccountGetIdByName(ctx, cfg.FolderID, cfg.ServiceAccount)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return c.sdk.WrapOperation(c.sdk.Compute().Instance().Create(ctx, request))
+	return c.wrapOperation(c.backend.Instance().Create(ctx, request))
 }
 
 func (c *Client) ComputeInstanceDelete(ctx context.Context, id string) (*operation.Operation, error) {
@@ -313,38 +481,168 @@ func (c *Client) ComputeInstanceDelete(ctx context.Context, id string) (*operati
 	defer cancel()
 
 	op := &compute.DeleteInstanceRequest{InstanceId: id}
-	return c.sdk.WrapOperation(c.sdk.Compute().Instance().Delete(cctx, op))
+	return c.wrapOperation(c.backend.Instance().Delete(cctx, op))
 }
 
+// ComputeInstanceGet returns the instance including its metadata.
+func (c *Client) ComputeInstanceGet(ctx context.Context, id string) (*compute.Instance, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.backend.Instance().Get(cctx, &compute.GetInstanceRequest{
+		InstanceId: id,
+		View:       compute.InstanceView_FULL,
+	})
+}
+
+// ComputeInstanceList returns all instances of the folder which have the labels and match the filters.
+// Both labels and filters are evaluated by the API.
 func (c *Client) ComputeInstanceList(
 	ctx context.Context,
 	folderID string,
 	lbl map[string]string,
 	filters ...Filter,
 ) ([]*compute.Instance, error) {
-	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
-	defer cancel()
+	var out []*compute.Instance
 
-	op := &compute.ListInstancesRequest{
-		FolderId: folderID,
-		PageSize: 1000,
+	it := c.ComputeInstanceIterator(ctx, folderID, lbl, filters...)
+	for it.Next() {
+		out = append(out, it.Value())
 	}
 
-	var filter []string
-	for _, f := range filters {
-		filter = append(filter, f.String())
+	return out, it.Error()
+}
+
+// ComputeInstanceIterator returns an iterator over the instances ComputeInstanceList would return,
+// requesting the pages lazily.
+func (c *Client) ComputeInstanceIterator(
+	ctx context.Context,
+	folderID string,
+	lbl map[string]string,
+	filters ...Filter,
+) *InstanceIterator {
+	// An invalid filter fails the first Next.
+	filter, err := joinFilters(append(LabelFilters(lbl), filters...))
+
+	return &InstanceIterator{
+		ctx:    ctx,
+		client: c,
+		request: &compute.ListInstancesRequest{
+			FolderId: folderID,
+			PageSize: listPageSize,
+			Filter:   filter,
+		},
+		err: err,
+	}
+}
+
+// InstanceIterator iterates over the pages of a ListInstancesRequest:
+//
+//	for it.Next() {
+//		instance := it.Value()
+//	}
+//	if err := it.Error(); err != nil {
+//	}
+type InstanceIterator struct {
+	ctx     context.Context
+	client  *Client
+	request *compute.ListInstancesRequest
+
+	items []*compute.Instance
+	value *compute.Instance
+	err   error
+	last  bool
+}
+
+// Next advances to the next instance, requesting the next page if needed.
+// It returns false at the end of the list or on error.
+func (it *InstanceIterator) Next() bool {
+	for len(it.items) == 0 {
+		if it.err != nil || it.last {
+			return false
+		}
+
+		it.fetch()
 	}
-	op.Filter = strings.Join(filter, " AND ")
 
-	lst, err := c.sdk.Compute().Instance().List(cctx, op)
+	it.value, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+func (it *InstanceIterator) fetch() {
+	cctx, cancel := context.WithTimeout(it.ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := it.client.backend.Instance().List(cctx, it.request)
 	if err != nil {
-		return nil, err
+		it.err = err
+		return
+	}
+
+	it.items = resp.Instances
+	it.request.PageToken = resp.NextPageToken
+	it.last = len(resp.NextPageToken) == 0
+}
+
+func (it *InstanceIterator) Value() *compute.Instance { return it.value }
+
+func (it *InstanceIterator) Error() error { return it.err }
+
+// ComputeInstanceResolve finds the instances of the folder referenced by name or ID, in the order of refs,
+// followed by the instances matching the selector. Every ref must match exactly one instance.
+// A nil selector selects nothing.
+func (c *Client) ComputeInstanceResolve(
+	ctx context.Context,
+	folderID string,
+	refs []string,
+	sel *Selector,
+) ([]*compute.Instance, error) {
+	var all []*compute.Instance
+	if len(refs) > 0 {
+		var err error
+		if all, err = c.ComputeInstanceList(ctx, folderID, nil); err != nil {
+			return nil, err
+		}
 	}
 
 	var out []*compute.Instance
-	for _, item := range lst.Instances {
-		if utils.AllInMap(item.Labels, lbl) {
-			out = append(out, item)
+	seen := make(map[string]struct{})
+	add := func(i *compute.Instance) {
+		if _, ok := seen[i.Id]; !ok {
+			seen[i.Id] = struct{}{}
+			out = append(out, i)
+		}
+	}
+
+	for _, ref := range refs {
+		var found []*compute.Instance
+		for _, i := range all {
+			if i.Id == ref || i.Name == ref {
+				found = append(found, i)
+			}
+		}
+
+		switch len(found) {
+		case 0:
+			return nil, fmt.Errorf("compute instance %q not found in folder %s", ref, folderID)
+		case 1:
+			add(found[0])
+		default:
+			ids := make([]string, 0, len(found))
+			for _, i := range found {
+				ids = append(ids, i.Id)
+			}
+			return nil, fmt.Errorf("compute instance %q is ambiguous, use one of the IDs: %s", ref, strings.Join(ids, ", "))
+		}
+	}
+
+	if sel != nil {
+		lst, err := c.ComputeInstanceSelect(ctx, folderID, *sel)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range lst {
+			add(i)
 		}
 	}
 
@@ -356,7 +654,7 @@ func (c *Client) ComputeInstanceStart(ctx context.Context, id string) (*operatio
 	defer cancel()
 
 	op := &compute.StartInstanceRequest{InstanceId: id}
-	return c.sdk.WrapOperation(c.sdk.Compute().Instance().Start(cctx, op))
+	return c.wrapOperation(c.backend.Instance().Start(cctx, op))
 }
 
 func (c *Client) ComputeInstanceStop(ctx context.Context, id string) (*operation.Operation, error) {
@@ -364,21 +662,5 @@ func (c *Client) ComputeInstanceStop(ctx context.Context, id string) (*operation
 	defer cancel()
 
 	op := &compute.StopInstanceRequest{InstanceId: id}
-	return c.sdk.WrapOperation(c.sdk.Compute().Instance().Stop(cctx, op))
-}
-
-func (c *Client) ComputeInstanceGroupCreate(ctx context.Context, id string) (*operation.Operation, error) {
-	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
-	defer cancel()
-
-	op := &instancegroup.CreateInstanceGroupRequest{}
-	return c.sdk.WrapOperation(c.sdk.InstanceGroup().InstanceGroup().Create(cctx, op))
-}
-
-func (c *Client) ComputeInstanceGroupDelete(ctx context.Context, id string) (*operation.Operation, error) {
-	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
-	defer cancel()
-
-	op := &instancegroup.DeleteInstanceGroupRequest{InstanceGroupId: id}
-	return c.sdk.WrapOperation(c.sdk.InstanceGroup().InstanceGroup().Delete(cctx, op))
+	return c.wrapOperation(c.backend.Instance().Stop(cctx, op))
 }
@@ -0,0 +1,83 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterString(t *testing.T) {
+	tests := []struct {
+		filter Filter
+		want   string
+	}{
+		{Filter{Field: "name", Operator: OperatorEq, Value: "web-1"}, `name = "web-1"`},
+		{Filter{Field: "name", Operator: OperatorNe, Value: "web-1"}, `name != "web-1"`},
+		{Filter{Field: "name", Operator: OperatorEq, Value: []string{"web-1"}}, `name = "web-1"`},
+		{Filter{Field: "labels.env", Operator: OperatorEq, Value: `say "hi"`}, `labels.env = "say \"hi\""`},
+		{Filter{Field: "status", Operator: OperatorIn, Value: []string{"RUNNING"}}, `status IN ("RUNNING")`},
+		{Filter{Field: "status", Operator: OperatorIn, Value: []string{"RUNNING", "STOPPED"}}, `status IN ("RUNNING", "STOPPED")`},
+		{Filter{Field: "id", Operator: OperatorNotIn, Value: []string{"a", "b"}}, `id NOT IN ("a", "b")`},
+		{Filter{Field: "id", Operator: OperatorNotIn, Value: "a"}, `id NOT IN ("a")`},
+	}
+	for _, tt := range tests {
+		if err := tt.filter.Validate(); err != nil {
+			t.Errorf("%+v: %v", tt.filter, err)
+		}
+		if got := tt.filter.String(); got != tt.want {
+			t.Errorf("%+v = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		filter  Filter
+		wantErr string
+	}{
+		{Filter{Field: "name", Operator: OperatorEq, Value: []string{"a", "b"}}, "filter name = takes one value, got 2"},
+		{Filter{Field: "name", Operator: OperatorNe, Value: []string{}}, "filter name != takes one value, got 0"},
+		{Filter{Field: "id", Operator: OperatorIn, Value: []string{}}, "filter id IN takes at least one value"},
+		{Filter{Field: "id", Operator: "~"}, `filter id: unknown operator "~"`},
+	}
+	for _, tt := range tests {
+		err := tt.filter.Validate()
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("%+v: error %v, want %q", tt.filter, err, tt.wantErr)
+		}
+	}
+}
+
+func TestJoinFilters(t *testing.T) {
+	got, err := joinFilters(append(
+		LabelFilters(map[string]string{"team": "web", "env": "prod"}),
+		Filter{Field: "status", Operator: OperatorIn, Value: []string{"RUNNING", "STOPPED"}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `labels.env = "prod" AND labels.team = "web" AND status IN ("RUNNING", "STOPPED")`
+	if got != want {
+		t.Errorf("joinFilters = %s, want %s", got, want)
+	}
+
+	_, err = joinFilters([]Filter{{Field: "name", Operator: OperatorEq, Value: []string{"a", "b"}}})
+	if err == nil || !strings.Contains(err.Error(), "takes one value") {
+		t.Errorf("error %v for an = filter with two values", err)
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+	ycsdk "github.com/yandex-cloud/go-sdk"
+	"github.com/yandex-cloud/go-sdk/iamkey"
+
+	"github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// IAMTokenPrefix is the prefix of IAM tokens; everything else is treated as an OAuth token.
+	IAMTokenPrefix = "t1."
+
+	DefaultCLIConfigFile = "~/.config/yandex-cloud/config.yaml"
+
+	metadataTokenPath    = "/computeMetadata/v1/instance/service-accounts/default/token"
+	metadataProbeTimeout = 500 * time.Millisecond
+)
+
+var ErrNoCredentials = errors.New("no credentials found: use --sa-key-file, --token, " +
+	"run on a compute instance with a service account or configure the yc CLI")
+
+// CredentialsConfig describes where to look for credentials.
+// Sources are tried by ResolveCredentials in the order of the fields.
+type CredentialsConfig struct {
+	// ServiceAccountKeyFile is a path to an authorized key JSON of a service account.
+	ServiceAccountKeyFile string
+	// Token is either an IAM token or an OAuth token.
+	Token string
+	// TokenFile is a file with an IAM or OAuth token, read when Token is empty.
+	TokenFile string
+	// MetadataAddr is host[:port] of the compute metadata service.
+	// Defaults to $YC_METADATA_ADDR or 169.254.169.254.
+	MetadataAddr string
+	// SkipMetadata disables the metadata service probe.
+	SkipMetadata bool
+	// CLIConfigFile is the yc CLI config. Defaults to DefaultCLIConfigFile.
+	CLIConfigFile string
+	// CLIProfile overrides the active yc CLI profile.
+	CLIProfile string
+}
+
+// ResolveCredentials returns the first credentials available from the sources of cfg.
+func ResolveCredentials(ctx context.Context, cfg CredentialsConfig) (ycsdk.Credentials, error) {
+	if len(cfg.ServiceAccountKeyFile) > 0 {
+		file, err := homedir.Expand(cfg.ServiceAccountKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := iamkey.ReadFromJSONFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read service account key: %w", err)
+		}
+
+		log.Debugf("Using service account key %s", file)
+		return ycsdk.ServiceAccountKey(key)
+	}
+
+	if len(cfg.Token) == 0 && len(cfg.TokenFile) > 0 {
+		file, err := homedir.Expand(cfg.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read token file: %w", err)
+		}
+		cfg.Token = string(b)
+	}
+
+	if token := strings.TrimSpace(cfg.Token); len(token) > 0 {
+		if strings.HasPrefix(token, IAMTokenPrefix) {
+			log.Debug("Using IAM token")
+			return ycsdk.NewIAMTokenCredentials(token), nil
+		}
+
+		log.Debug("Using OAuth token")
+		return ycsdk.OAuthToken(token), nil
+	}
+
+	if !cfg.SkipMetadata {
+		creds := NewMetadataCredentials(cfg.MetadataAddr)
+
+		pctx, cancel := context.WithTimeout(ctx, metadataProbeTimeout)
+		_, err := creds.IAMToken(pctx)
+		cancel()
+		if err == nil {
+			log.Debugf("Using instance service account from %s", creds.addr)
+			return creds, nil
+		}
+
+		log.Debugf("Metadata service is not available: %s", err)
+	}
+
+	profile, err := LoadCLIProfile(cfg.CLIConfigFile, cfg.CLIProfile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoCredentials
+		}
+
+		return nil, err
+	}
+
+	log.Debugf("Using yc CLI profile %q", profile.Name)
+	return profile.Credentials()
+}
+
+// MetadataCredentials obtains IAM tokens of the service account attached to
+// the compute instance from the metadata service.
+type MetadataCredentials struct {
+	addr   string
+	client *http.Client
+}
+
+var _ ycsdk.NonExchangeableCredentials = (*MetadataCredentials)(nil)
+
+func NewMetadataCredentials(addr string) *MetadataCredentials {
+	if len(addr) == 0 {
+		addr = os.Getenv(ycsdk.InstanceMetadataOverrideEnvVar)
+	}
+	if len(addr) == 0 {
+		addr = ycsdk.InstanceMetadataAddr
+	}
+
+	return &MetadataCredentials{
+		addr:   addr,
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (c *MetadataCredentials) YandexCloudAPICredentials() {}
+
+func (c *MetadataCredentials) IAMToken(ctx context.Context) (*iam.CreateIamTokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+c.addr+metadataTokenPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata service: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err = json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("metadata service: %w", err)
+	}
+	if len(token.AccessToken) == 0 {
+		return nil, errors.New("metadata service: empty access token")
+	}
+
+	return &iam.CreateIamTokenResponse{
+		IamToken:  token.AccessToken,
+		ExpiresAt: timestamppb.New(time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)),
+	}, nil
+}
+
+// CLIProfile is a profile of the yc CLI config.
+type CLIProfile struct {
+	Name string `yaml:"-"`
+
+	Token                  string         `yaml:"token"`
+	ServiceAccountKey      map[string]any `yaml:"service-account-key"`
+	InstanceServiceAccount bool           `yaml:"instance-service-account"`
+	FederationID           string         `yaml:"federation-id"`
+
+	CloudID  string `yaml:"cloud-id"`
+	FolderID string `yaml:"folder-id"`
+	Zone     string `yaml:"compute-default-zone"`
+}
+
+// LoadCLIProfile reads the profile from the yc CLI config file.
+// Empty file means DefaultCLIConfigFile, empty name means the active profile.
+func LoadCLIProfile(file, name string) (*CLIProfile, error) {
+	if len(file) == 0 {
+		file = DefaultCLIConfigFile
+	}
+	file, err := homedir.Expand(file)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		Current  string                 `yaml:"current"`
+		Profiles map[string]*CLIProfile `yaml:"profiles"`
+	}
+	if err = yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(file), err)
+	}
+
+	if len(name) == 0 {
+		name = config.Current
+	}
+	profile, ok := config.Profiles[name]
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("yc CLI profile %q not found in %s", name, file)
+	}
+	profile.Name = name
+
+	return profile, nil
+}
+
+// Credentials returns credentials configured in the profile.
+func (p *CLIProfile) Credentials() (ycsdk.Credentials, error) {
+	switch {
+	case len(p.ServiceAccountKey) > 0:
+		b, err := json.Marshal(p.ServiceAccountKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := iamkey.ReadFromJSONBytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("yc CLI profile %q: %w", p.Name, err)
+		}
+
+		return ycsdk.ServiceAccountKey(key)
+	case len(p.Token) > 0:
+		if strings.HasPrefix(p.Token, IAMTokenPrefix) {
+			return ycsdk.NewIAMTokenCredentials(p.Token), nil
+		}
+
+		return ycsdk.OAuthToken(p.Token), nil
+	case p.InstanceServiceAccount:
+		return NewMetadataCredentials(""), nil
+	case len(p.FederationID) > 0:
+		return nil, fmt.Errorf("yc CLI profile %q: federated accounts are not supported, use `yc iam create-token`", p.Name)
+	}
+
+	return nil, fmt.Errorf("yc CLI profile %q has no credentials", p.Name)
+}
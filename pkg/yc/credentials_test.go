@@ -0,0 +1,283 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ycsdk "github.com/yandex-cloud/go-sdk"
+)
+
+const testCLIConfig = `current: default
+profiles:
+  default:
+    token: AQAD-profile
+    folder-id: b1gprofile
+  iam:
+    token: t1.profile
+  federated:
+    federation-id: bpf123
+`
+
+// newMetadataServer starts a stand-in metadata service and points YC_METADATA_ADDR at it.
+func newMetadataServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	t.Setenv(ycsdk.InstanceMetadataOverrideEnvVar, strings.TrimPrefix(srv.URL, "http://"))
+}
+
+func metadataToken(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != metadataTokenPath || r.Header.Get("Metadata-Flavor") != "Google" {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = fmt.Fprint(w, `{"access_token":"t1.metadata","expires_in":3600,"token_type":"Bearer"}`)
+}
+
+func metadataError(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, "no service account", http.StatusInternalServerError)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func writeServiceAccountKey(t *testing.T) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(map[string]string{
+		"id":                 "ajekey",
+		"service_account_id": "ajesa",
+		"private_key":        string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writeFile(t, "key.json", string(b))
+}
+
+// describeCredentials tells the kind of the credentials and the token they carry.
+func describeCredentials(t *testing.T, creds ycsdk.Credentials) string {
+	t.Helper()
+
+	switch c := creds.(type) {
+	case *MetadataCredentials:
+		return "metadata"
+	case *ycsdk.IAMTokenCredentials:
+		resp, err := c.IAMToken(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "iam " + resp.IamToken
+	case ycsdk.ExchangeableCredentials:
+		req, err := c.IAMTokenRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(req.GetJwt()) > 0 {
+			return "sa-key"
+		}
+		return "oauth " + req.GetYandexPassportOauthToken()
+	}
+
+	t.Fatalf("unexpected credentials %T", creds)
+	return ""
+}
+
+func TestResolveCredentials(t *testing.T) {
+	keyFile := writeServiceAccountKey(t)
+	tokenFile := writeFile(t, "token", "t1.file\n")
+	cliConfig := writeFile(t, "config.yaml", testCLIConfig)
+	noCLIConfig := filepath.Join(t.TempDir(), "missing.yaml")
+
+	tests := []struct {
+		name     string
+		cfg      CredentialsConfig
+		metadata http.HandlerFunc
+		want     string
+		wantErr  error
+	}{
+		{
+			name: "service account key first",
+			cfg: CredentialsConfig{
+				ServiceAccountKeyFile: keyFile,
+				Token:                 "t1.flag",
+				TokenFile:             tokenFile,
+				CLIConfigFile:         cliConfig,
+			},
+			metadata: metadataToken,
+			want:     "sa-key",
+		},
+		{
+			name:     "token over token file",
+			cfg:      CredentialsConfig{Token: "AQAD-flag", TokenFile: tokenFile, CLIConfigFile: cliConfig},
+			metadata: metadataToken,
+			want:     "oauth AQAD-flag",
+		},
+		{
+			name:     "token file is trimmed",
+			cfg:      CredentialsConfig{TokenFile: tokenFile, CLIConfigFile: cliConfig},
+			metadata: metadataToken,
+			want:     "iam t1.file",
+		},
+		{
+			name:     "IAM token by prefix",
+			cfg:      CredentialsConfig{Token: " t1.flag ", CLIConfigFile: cliConfig},
+			metadata: metadataToken,
+			want:     "iam t1.flag",
+		},
+		{
+			name:     "metadata over CLI profile",
+			cfg:      CredentialsConfig{CLIConfigFile: cliConfig},
+			metadata: metadataToken,
+			want:     "metadata",
+		},
+		{
+			name:     "CLI profile when metadata fails",
+			cfg:      CredentialsConfig{CLIConfigFile: cliConfig},
+			metadata: metadataError,
+			want:     "oauth AQAD-profile",
+		},
+		{
+			name:     "CLI profile when metadata is skipped",
+			cfg:      CredentialsConfig{CLIConfigFile: cliConfig, CLIProfile: "iam", SkipMetadata: true},
+			metadata: metadataToken,
+			want:     "iam t1.profile",
+		},
+		{
+			name:     "no credentials",
+			cfg:      CredentialsConfig{CLIConfigFile: noCLIConfig},
+			metadata: metadataError,
+			wantErr:  ErrNoCredentials,
+		},
+		{
+			name:     "federated CLI profile",
+			cfg:      CredentialsConfig{CLIConfigFile: cliConfig, CLIProfile: "federated"},
+			metadata: metadataError,
+			wantErr:  errors.New(`yc CLI profile "federated": federated accounts are not supported`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newMetadataServer(t, tt.metadata)
+
+			creds, err := ResolveCredentials(context.Background(), tt.cfg)
+			if tt.wantErr != nil {
+				if err == nil || !errors.Is(err, tt.wantErr) && !strings.HasPrefix(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := describeCredentials(t, creds); got != tt.want {
+				t.Fatalf("got %s credentials, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveCredentialsMetadataTimeout(t *testing.T) {
+	newMetadataServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			metadataToken(w, r)
+		}
+	})
+
+	start := time.Now()
+	creds, err := ResolveCredentials(context.Background(), CredentialsConfig{
+		CLIConfigFile: writeFile(t, "config.yaml", testCLIConfig),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*metadataProbeTimeout {
+		t.Fatalf("metadata probe took %s, want about %s", elapsed, metadataProbeTimeout)
+	}
+	if got := describeCredentials(t, creds); got != "oauth AQAD-profile" {
+		t.Fatalf("got %s credentials, want the CLI profile", got)
+	}
+}
+
+func TestMetadataCredentials(t *testing.T) {
+	newMetadataServer(t, metadataToken)
+
+	resp, err := NewMetadataCredentials("").IAMToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.IamToken != "t1.metadata" {
+		t.Fatalf("token = %q, want t1.metadata", resp.IamToken)
+	}
+	if ttl := time.Until(resp.ExpiresAt.AsTime()); ttl < 59*time.Minute || ttl > time.Hour {
+		t.Fatalf("token expires in %s, want 1h", ttl)
+	}
+}
+
+func TestLoadCLIProfile(t *testing.T) {
+	file := writeFile(t, "config.yaml", testCLIConfig)
+
+	profile, err := LoadCLIProfile(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "default" || profile.FolderID != "b1gprofile" {
+		t.Fatalf("active profile = %q with folder %q, want default with b1gprofile", profile.Name, profile.FolderID)
+	}
+
+	if _, err = LoadCLIProfile(file, "missing"); err == nil {
+		t.Fatal("missing profile: want an error")
+	}
+	if _, err = LoadCLIProfile(filepath.Join(t.TempDir(), "missing.yaml"), ""); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing file: got %v, want os.ErrNotExist", err)
+	}
+}
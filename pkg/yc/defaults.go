@@ -24,9 +24,16 @@ const (
 	KsToolKey = "yc.ks-tool.dev"
 
 	DefaultDiskType          = "network-ssd"
-	DefaultDiskID            = "fd83j4siasgfq4pi1qif" //debian-12-v20240920
 	DefaultDiskSizeGib int64 = 10
 
+	// DefaultDiskID is the image of debian-12-v20240920.
+	//
+	// Deprecated: images are resolved by family, use DefaultImageFamily in DefaultImageFolderID.
+	DefaultDiskID = "fd83j4siasgfq4pi1qif"
+
+	DefaultImageFamily   = "debian-12"
+	DefaultImageFolderID = "standard-images"
+
 	DefaultPlatformID = "standard-v3"
 	DefaultZone       = "ru-central1-d"
 
@@ -35,4 +42,6 @@ const (
 	DefaultMemoryGib    int64 = 2
 
 	requestTimeout = 15 * time.Second
+	waitInterval   = 2 * time.Second
+	listPageSize   = 1000
 )
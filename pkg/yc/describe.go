@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/utils"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// HideUserData returns a copy of the instance with the user-data replaced by its size.
+func HideUserData(i *compute.Instance) *compute.Instance {
+	ud, ok := i.Metadata[common.UserDataKey]
+	if !ok {
+		return i
+	}
+
+	out := proto.Clone(i).(*compute.Instance)
+	out.Metadata[common.UserDataKey] = fmt.Sprintf("<hidden, %d bytes>", len(ud))
+
+	return out
+}
+
+// FPrintComputeDescribe prints the details of the compute instance in a human-readable form.
+func FPrintComputeDescribe(w io.Writer, i *compute.Instance) error {
+	d := describer{tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)}
+
+	d.field(0, "ID", i.Id)
+	d.field(0, "Name", i.Name)
+	if len(i.Description) > 0 {
+		d.field(0, "Description", i.Description)
+	}
+	d.field(0, "Folder", i.FolderId)
+	d.field(0, "Zone", i.ZoneId)
+	d.field(0, "Platform", i.PlatformId)
+	d.field(0, "Status", i.Status)
+	d.field(0, "FQDN", i.Fqdn)
+	d.field(0, "Created", formatTime(i.CreatedAt))
+	d.field(0, "Service account", orNone(i.ServiceAccountId))
+
+	r := i.GetResources()
+	d.section(0, "Resources")
+	d.field(1, "Cores", r.GetCores())
+	d.field(1, "Core fraction", fmt.Sprintf("%d%%", r.GetCoreFraction()))
+	d.field(1, "Memory", fmt.Sprintf("%dG", r.GetMemory()/utils.Gib))
+	if r.GetGpus() > 0 {
+		d.field(1, "GPUs", r.GetGpus())
+	}
+
+	d.section(0, "Scheduling policy")
+	d.field(1, "Preemptible", i.GetSchedulingPolicy().GetPreemptible())
+
+	d.section(0, "Boot disk")
+	d.disk(i.BootDisk)
+	if len(i.SecondaryDisks) == 0 {
+		d.field(0, "Secondary disks", "<none>")
+	} else {
+		d.section(0, "Secondary disks")
+		for _, disk := range i.SecondaryDisks {
+			d.disk(disk)
+		}
+	}
+
+	if len(i.NetworkInterfaces) == 0 {
+		d.field(0, "Network interfaces", "<none>")
+	} else {
+		d.section(0, "Network interfaces")
+	}
+	for _, nic := range i.NetworkInterfaces {
+		d.section(1, "eth"+nic.Index)
+		d.field(2, "Subnet", nic.SubnetId)
+		d.field(2, "MAC", nic.MacAddress)
+		if v4 := nic.PrimaryV4Address; v4 != nil {
+			d.field(2, "Internal IPv4", v4.Address)
+			d.field(2, "NAT IPv4", orNone(v4.GetOneToOneNat().GetAddress()))
+		}
+		if v6 := nic.PrimaryV6Address; v6 != nil {
+			d.field(2, "IPv6", v6.Address)
+		}
+		d.field(2, "Security groups", orNone(strings.Join(nic.SecurityGroupIds, ", ")))
+	}
+
+	d.values("Labels", i.Labels)
+	d.values("Metadata", i.Metadata)
+
+	return d.Flush()
+}
+
+type describer struct {
+	*tabwriter.Writer
+}
+
+func (d describer) field(indent int, name string, value any) {
+	_, _ = fmt.Fprintf(d, "%s%s:\t%v\n", strings.Repeat("  ", indent), name, value)
+}
+
+func (d describer) section(indent int, name string) {
+	_, _ = fmt.Fprintf(d, "%s%s:\n", strings.Repeat("  ", indent), name)
+}
+
+func (d describer) disk(disk *compute.AttachedDisk) {
+	if disk == nil {
+		d.field(1, "ID", "<none>")
+		return
+	}
+
+	d.field(1, "ID", disk.DiskId)
+	d.field(1, "Device", disk.DeviceName)
+	d.field(1, "Mode", disk.Mode)
+	d.field(1, "Auto delete", disk.AutoDelete)
+}
+
+// values prints a map sorted by key. Multi-line values such as the user-data are printed as a block.
+func (d describer) values(name string, m map[string]string) {
+	if len(m) == 0 {
+		d.field(0, name, "<none>")
+		return
+	}
+
+	d.section(0, name)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := strings.TrimRight(m[k], "\n")
+		if !strings.Contains(v, "\n") {
+			d.field(1, k, v)
+			continue
+		}
+
+		d.field(1, k, "|")
+		for _, line := range strings.Split(v, "\n") {
+			// Tabs would be taken for cell separators.
+			_, _ = fmt.Fprintf(d, "      %s\n", strings.ReplaceAll(line, "\t", "    "))
+		}
+	}
+}
+
+func orNone(s string) string {
+	if len(s) == 0 {
+		return "<none>"
+	}
+
+	return s
+}
@@ -0,0 +1,378 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ks-tool/ks/pkg/utils"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// DefaultFilesystem is the filesystem a mounted disk is formatted with unless given.
+const DefaultFilesystem = "ext4"
+
+var (
+	deviceNameRe = regexp.MustCompile(`^[a-z][-_0-9a-z]{0,19}$`)
+	mountPointRe = regexp.MustCompile(`^/[-_.0-9A-Za-z/]*$`)
+	filesystemRe = regexp.MustCompile(`^[0-9a-z]+$`)
+)
+
+// DiskConfig describes a disk to create. The disk is empty unless a snapshot or an image is given.
+type DiskConfig struct {
+	FolderID   string
+	Name       string
+	Zone       string
+	TypeID     string
+	SizeGib    int64
+	SnapshotID string
+	ImageID    string
+	Labels     map[string]string
+}
+
+func (c *Client) ComputeDiskCreate(ctx context.Context, cfg *DiskConfig) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req := &compute.CreateDiskRequest{
+		FolderId: cfg.FolderID,
+		Name:     cfg.Name,
+		Labels:   cfg.Labels,
+		TypeId:   cfg.TypeID,
+		ZoneId:   cfg.Zone,
+		Size:     cfg.SizeGib * utils.Gib,
+	}
+	if len(req.TypeId) == 0 {
+		req.TypeId = DefaultDiskType
+	}
+	if len(req.ZoneId) == 0 {
+		req.ZoneId = DefaultZone
+	}
+	switch {
+	case len(cfg.SnapshotID) > 0:
+		req.Source = &compute.CreateDiskRequest_SnapshotId{SnapshotId: cfg.SnapshotID}
+	case len(cfg.ImageID) > 0:
+		req.Source = &compute.CreateDiskRequest_ImageId{ImageId: cfg.ImageID}
+	}
+
+	return c.wrapOperation(c.backend.Disk().Create(cctx, req))
+}
+
+func (c *Client) ComputeDiskDelete(ctx context.Context, id string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Disk().Delete(cctx, &compute.DeleteDiskRequest{DiskId: id}))
+}
+
+func (c *Client) ComputeDiskGet(ctx context.Context, id string) (*compute.Disk, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.backend.Disk().Get(cctx, &compute.GetDiskRequest{DiskId: id})
+}
+
+// ComputeDiskList returns all disks of the folder.
+func (c *Client) ComputeDiskList(ctx context.Context, folderID string) ([]*compute.Disk, error) {
+	req := &compute.ListDisksRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*compute.Disk
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.Disk().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Disks...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// ComputeDiskResolve finds the disk of the folder referenced by name or ID.
+func (c *Client) ComputeDiskResolve(ctx context.Context, folderID, ref string) (*compute.Disk, error) {
+	lst, err := c.ComputeDiskList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOne("disk", folderID, ref, lst, func(d *compute.Disk) (string, string) { return d.Id, d.Name })
+}
+
+// ComputeDiskResize grows the disk to sizeGib. Disks can't shrink.
+func (c *Client) ComputeDiskResize(ctx context.Context, id string, sizeGib int64) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Disk().Update(cctx, &compute.UpdateDiskRequest{
+		DiskId:     id,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"size"}},
+		Size:       sizeGib * utils.Gib,
+	}))
+}
+
+func (c *Client) ComputeInstanceAttachDisk(ctx context.Context, instanceID string, spec *compute.AttachedDiskSpec) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Instance().AttachDisk(cctx, &compute.AttachInstanceDiskRequest{
+		InstanceId:       instanceID,
+		AttachedDiskSpec: spec,
+	}))
+}
+
+func (c *Client) ComputeInstanceDetachDisk(ctx context.Context, instanceID, diskID string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Instance().DetachDisk(cctx, &compute.DetachInstanceDiskRequest{
+		InstanceId: instanceID,
+		Disk:       &compute.DetachInstanceDiskRequest_DiskId{DiskId: diskID},
+	}))
+}
+
+func (c *Client) ComputeSnapshotCreate(
+	ctx context.Context,
+	folderID, diskID, name string,
+	labels map[string]string,
+) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Snapshot().Create(cctx, &compute.CreateSnapshotRequest{
+		FolderId: folderID,
+		DiskId:   diskID,
+		Name:     name,
+		Labels:   labels,
+	}))
+}
+
+func (c *Client) ComputeSnapshotDelete(ctx context.Context, id string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Snapshot().Delete(cctx, &compute.DeleteSnapshotRequest{SnapshotId: id}))
+}
+
+// ComputeSnapshotList returns all snapshots of the folder.
+func (c *Client) ComputeSnapshotList(ctx context.Context, folderID string) ([]*compute.Snapshot, error) {
+	req := &compute.ListSnapshotsRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*compute.Snapshot
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.Snapshot().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Snapshots...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// ComputeSnapshotResolve finds the snapshot of the folder referenced by name or ID.
+func (c *Client) ComputeSnapshotResolve(ctx context.Context, folderID, ref string) (*compute.Snapshot, error) {
+	lst, err := c.ComputeSnapshotList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOne("snapshot", folderID, ref, lst, func(s *compute.Snapshot) (string, string) { return s.Id, s.Name })
+}
+
+// resolveOne returns the only item whose ID or name is ref.
+func resolveOne[T any](kind, folderID, ref string, items []T, key func(T) (id, name string)) (T, error) {
+	var found []T
+	var ids []string
+	for _, item := range items {
+		if id, name := key(item); id == ref || name == ref {
+			found = append(found, item)
+			ids = append(ids, id)
+		}
+	}
+
+	var zero T
+	switch len(found) {
+	case 0:
+		return zero, fmt.Errorf("%s %q not found in folder %s", kind, ref, folderID)
+	case 1:
+		return found[0], nil
+	default:
+		return zero, fmt.Errorf("%s %q is ambiguous, use one of the IDs: %s", kind, ref, strings.Join(ids, ", "))
+	}
+}
+
+// SecondaryDisk is a disk attached to an instance in addition to the boot disk.
+type SecondaryDisk struct {
+	// DeviceName is the serial of the disk in the guest, see Device.
+	DeviceName string
+	TypeID     string
+	SizeGib    int64
+	// DiskID attaches an existing disk instead of creating one.
+	DiskID string
+	// SnapshotID restores a new disk from the snapshot.
+	SnapshotID string
+	AutoDelete bool
+	// Mount is the directory the disk is mounted to, the disk is not mounted if empty.
+	// A disk without a filesystem is formatted with FS before mounting.
+	Mount string
+	FS    string
+}
+
+// ParseSecondaryDisk parses a comma separated list of key=value pairs, e.g.
+// `size=50,type=network-ssd,name=data,auto-delete=false,mount=/var/lib/etcd`.
+// Keys: name, size (GiB), type, id, snapshot, auto-delete, mount and fs.
+//
+// The name is the device name of the disk and, prefixed with the instance name, the name of a new disk.
+// Either size or id is required. New disks are deleted with the instance unless auto-delete=false,
+// existing ones are kept unless auto-delete=true.
+func ParseSecondaryDisk(spec string) (SecondaryDisk, error) {
+	var d SecondaryDisk
+	var autoDelete *bool
+
+	for _, kv := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || len(value) == 0 {
+			return d, fmt.Errorf("secondary disk %q: key=value expected, got %q", spec, kv)
+		}
+
+		var err error
+		switch key {
+		case "name":
+			d.DeviceName = value
+		case "size":
+			d.SizeGib, err = strconv.ParseInt(value, 10, 64)
+			if err == nil && d.SizeGib <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "type":
+			d.TypeID = value
+		case "id":
+			d.DiskID = value
+		case "snapshot":
+			d.SnapshotID = value
+		case "auto-delete":
+			var v bool
+			v, err = strconv.ParseBool(value)
+			autoDelete = &v
+		case "mount":
+			d.Mount = value
+			if !mountPointRe.MatchString(value) {
+				err = fmt.Errorf("absolute path expected")
+			}
+		case "fs":
+			d.FS = value
+			if !filesystemRe.MatchString(value) {
+				err = fmt.Errorf("invalid filesystem")
+			}
+		default:
+			err = fmt.Errorf("unknown key, allow: name, size, type, id, snapshot, auto-delete, mount, fs")
+		}
+		if err != nil {
+			return d, fmt.Errorf("secondary disk %q: %s: %w", spec, key, err)
+		}
+	}
+
+	if len(d.DeviceName) > 0 && !deviceNameRe.MatchString(d.DeviceName) {
+		return d, fmt.Errorf("secondary disk %q: invalid name %q, allow: %s", spec, d.DeviceName, deviceNameRe)
+	}
+	if len(d.DiskID) > 0 {
+		if d.SizeGib > 0 || len(d.TypeID) > 0 || len(d.SnapshotID) > 0 {
+			return d, fmt.Errorf("secondary disk %q: size, type and snapshot are not allowed with id", spec)
+		}
+	} else if d.SizeGib == 0 {
+		return d, fmt.Errorf("secondary disk %q: size or id required", spec)
+	}
+	if len(d.Mount) > 0 && len(d.DeviceName) == 0 {
+		return d, fmt.Errorf("secondary disk %q: name required to mount the disk", spec)
+	}
+	if len(d.FS) == 0 {
+		d.FS = DefaultFilesystem
+	}
+
+	d.AutoDelete = len(d.DiskID) == 0
+	if autoDelete != nil {
+		d.AutoDelete = *autoDelete
+	}
+
+	return d, nil
+}
+
+// Device is the path of the disk in a Linux guest.
+func (d SecondaryDisk) Device() string {
+	return "/dev/disk/by-id/virtio-" + d.DeviceName
+}
+
+// Spec returns the attachment of the disk to the named instance.
+func (d SecondaryDisk) Spec(instanceName string) *compute.AttachedDiskSpec {
+	spec := &compute.AttachedDiskSpec{
+		Mode:       compute.AttachedDiskSpec_READ_WRITE,
+		DeviceName: d.DeviceName,
+		AutoDelete: d.AutoDelete,
+	}
+	if len(d.DiskID) > 0 {
+		spec.Disk = &compute.AttachedDiskSpec_DiskId{DiskId: d.DiskID}
+		return spec
+	}
+
+	diskSpec := &compute.AttachedDiskSpec_DiskSpec{
+		TypeId: d.TypeID,
+		Size:   d.SizeGib * utils.Gib,
+	}
+	if len(instanceName) > 0 && len(d.DeviceName) > 0 {
+		diskSpec.Name = instanceName + "-" + d.DeviceName
+	}
+	if len(diskSpec.TypeId) == 0 {
+		diskSpec.TypeId = DefaultDiskType
+	}
+	if len(d.SnapshotID) > 0 {
+		diskSpec.Source = &compute.AttachedDiskSpec_DiskSpec_SnapshotId{SnapshotId: d.SnapshotID}
+	}
+	spec.Disk = &compute.AttachedDiskSpec_DiskSpec_{DiskSpec: diskSpec}
+
+	return spec
+}
+
+// MountScript returns a shell script which formats the disk unless it has a filesystem,
+// and mounts it persistently. It waits for the device to appear after a hot attach.
+func (d SecondaryDisk) MountScript() string {
+	dev := d.Device()
+	return fmt.Sprintf(`set -e
+for i in $(seq 30); do [ -e %[1]s ] && break; sleep 1; done
+sudo blkid %[1]s >/dev/null || sudo mkfs -t %[3]s %[1]s
+sudo mkdir -p %[2]s
+grep -q '^%[1]s ' /etc/fstab || echo '%[1]s %[2]s %[3]s defaults,nofail 0 2' | sudo tee -a /etc/fstab >/dev/null
+mountpoint -q %[2]s || sudo mount %[2]s
+`, dev, d.Mount, d.FS)
+}
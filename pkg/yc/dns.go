@@ -0,0 +1,400 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ks-tool/ks/pkg/common"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+)
+
+// DNSRecordTTL is the TTL of the records of instances. It is short, as the IPs of preemptible instances change.
+const DNSRecordTTL = 60
+
+// instanceRecordTypes are the types of the records managed for instances.
+var instanceRecordTypes = []string{"A", "AAAA"}
+
+// dnsOwnerPrefix is prepended to the name of the records of an instance for the TXT record
+// marking them as created by ks. Only the records so marked are removed once the instance is gone.
+const dnsOwnerPrefix = "_ks."
+
+// dnsOwnerData is the data of the TXT records marking the records created by ks.
+var dnsOwnerData = common.ManagedKey + "=" + KsToolKey
+
+// FQDN returns the name with the trailing dot.
+func FQDN(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+
+	return name + "."
+}
+
+// DNSZoneConfig describes a DNS zone to ensure. The zone is private to the networks unless it is public.
+type DNSZoneConfig struct {
+	FolderID   string
+	Zone       string
+	Name       string
+	NetworkIDs []string
+	Public     bool
+}
+
+// DNSZoneList returns all DNS zones of the folder.
+func (c *Client) DNSZoneList(ctx context.Context, folderID string) ([]*dns.DnsZone, error) {
+	req := &dns.ListDnsZonesRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*dns.DnsZone
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.DnsZone().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.DnsZones...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+func (c *Client) DNSZoneGet(ctx context.Context, id string) (*dns.DnsZone, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.backend.DnsZone().Get(cctx, &dns.GetDnsZoneRequest{DnsZoneId: id})
+}
+
+// DNSZoneResolve finds the DNS zone of the folder referenced by its domain, name or ID.
+func (c *Client) DNSZoneResolve(ctx context.Context, folderID, ref string) (*dns.DnsZone, error) {
+	lst, err := c.DNSZoneList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range lst {
+		if zone.Zone == FQDN(ref) {
+			return zone, nil
+		}
+	}
+
+	return resolveOne("DNS zone", folderID, ref, lst, func(z *dns.DnsZone) (string, string) { return z.Id, z.Name })
+}
+
+// DNSZoneEnsure returns the DNS zone of the domain, creating it if the folder has none.
+// The name of a new zone defaults to the domain with dots replaced by dashes.
+func (c *Client) DNSZoneEnsure(ctx context.Context, cfg *DNSZoneConfig) (*dns.DnsZone, error) {
+	lst, err := c.DNSZoneList(ctx, cfg.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, zone := range lst {
+		if zone.Zone == FQDN(cfg.Zone) {
+			return zone, nil
+		}
+	}
+
+	req := &dns.CreateDnsZoneRequest{
+		FolderId:    cfg.FolderID,
+		Name:        cfg.Name,
+		Description: "Created by ks dns zone ensure",
+		Labels:      map[string]string{common.ManagedKey: KsToolKey},
+		Zone:        FQDN(cfg.Zone),
+	}
+	if len(req.Name) == 0 {
+		req.Name = strings.ReplaceAll(strings.TrimSuffix(cfg.Zone, "."), ".", "-")
+	}
+	if cfg.Public {
+		req.PublicVisibility = &dns.PublicVisibility{}
+	} else {
+		req.PrivateVisibility = &dns.PrivateVisibility{NetworkIds: cfg.NetworkIDs}
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	op, err := c.wrapOperation(c.backend.DnsZone().Create(cctx, req))
+	cancel()
+
+	return operationResult[*dns.DnsZone](ctx, op, err)
+}
+
+// DNSRecordList returns all record sets of the DNS zone.
+func (c *Client) DNSRecordList(ctx context.Context, zoneID string) ([]*dns.RecordSet, error) {
+	req := &dns.ListDnsZoneRecordSetsRequest{DnsZoneId: zoneID, PageSize: listPageSize}
+
+	var out []*dns.RecordSet
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.DnsZone().ListRecordSets(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.RecordSets...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// InstanceRecordName returns the name of the records of the instance in the zone, <name>.<zone>.
+func InstanceRecordName(instance *compute.Instance, zone *dns.DnsZone) string {
+	return instanceName(instance) + "." + FQDN(zone.Zone)
+}
+
+// InstanceRecordSets returns the A and AAAA records of the instance in the zone.
+// A public zone gets the external IPv4 address, a private one the internal address.
+func InstanceRecordSets(instance *compute.Instance, zone *dns.DnsZone) []*dns.RecordSet {
+	if len(instance.NetworkInterfaces) == 0 {
+		return nil
+	}
+	nic := instance.NetworkInterfaces[0]
+
+	ipv4 := nic.GetPrimaryV4Address().GetAddress()
+	if zone.PublicVisibility != nil {
+		ipv4 = nic.GetPrimaryV4Address().GetOneToOneNat().GetAddress()
+	}
+
+	name := InstanceRecordName(instance, zone)
+	var out []*dns.RecordSet
+	if len(ipv4) > 0 {
+		out = append(out, &dns.RecordSet{Name: name, Type: "A", Ttl: DNSRecordTTL, Data: []string{ipv4}})
+	}
+	if ipv6 := nic.GetPrimaryV6Address().GetAddress(); len(ipv6) > 0 {
+		out = append(out, &dns.RecordSet{Name: name, Type: "AAAA", Ttl: DNSRecordTTL, Data: []string{ipv6}})
+	}
+
+	return out
+}
+
+// ownedRecordSets returns the records of the instance in the zone with the TXT record marking them
+// as created by ks, none if the instance has no IPs.
+func ownedRecordSets(instance *compute.Instance, zone *dns.DnsZone) []*dns.RecordSet {
+	out := InstanceRecordSets(instance, zone)
+	if len(out) == 0 {
+		return nil
+	}
+
+	return append(out, &dns.RecordSet{
+		Name: dnsOwnerPrefix + InstanceRecordName(instance, zone),
+		Type: "TXT",
+		Ttl:  DNSRecordTTL,
+		Data: []string{dnsOwnerData},
+	})
+}
+
+// DNSInstanceRecordsUpdate sets the records of the instance in the zone to its current IPs.
+// It returns nil if the records are up to date.
+func (c *Client) DNSInstanceRecordsUpdate(
+	ctx context.Context,
+	zone *dns.DnsZone,
+	instance *compute.Instance,
+) (*operation.Operation, error) {
+	current, err := c.DNSRecordList(ctx, zone.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	name := InstanceRecordName(instance, zone)
+	replacements, deletions := diffRecordSets(instanceRecords(current, name), ownedRecordSets(instance, zone))
+
+	return c.upsertRecordSets(ctx, zone.Id, replacements, deletions)
+}
+
+// DNSInstanceRecordsRemove removes the records of the instance from the zone.
+// It returns nil if there are none.
+func (c *Client) DNSInstanceRecordsRemove(
+	ctx context.Context,
+	zone *dns.DnsZone,
+	instance *compute.Instance,
+) (*operation.Operation, error) {
+	current, err := c.DNSRecordList(ctx, zone.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.upsertRecordSets(ctx, zone.Id, nil, instanceRecords(current, InstanceRecordName(instance, zone)))
+}
+
+// DNSRecordChange is a record set changed by DNSSync.
+type DNSRecordChange struct {
+	Zone    string
+	Name    string
+	Type    string
+	Data    []string
+	Deleted bool
+}
+
+// DNSSync reconciles the records of the instances managed by ks with their DNS zones.
+// Running instances get records of their current IPs, the records of the others are removed.
+// The records of names marked as created by ks and having no instance are removed too,
+// e.g. of deleted instances. Records not created by ks are left as is.
+func (c *Client) DNSSync(ctx context.Context, folderID string) ([]DNSRecordChange, error) {
+	instances, err := c.ComputeInstanceList(ctx, folderID, map[string]string{common.ManagedKey: KsToolKey})
+	if err != nil {
+		return nil, err
+	}
+	zones, err := c.DNSZoneList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []DNSRecordChange
+	for _, zone := range zones {
+		var desired []*dns.RecordSet
+		names := make(map[string]bool)
+		for _, instance := range instances {
+			if instance.Labels[common.LabelDNSZoneKey] != zone.Id {
+				continue
+			}
+			names[InstanceRecordName(instance, zone)] = true
+			if instance.Status == compute.Instance_RUNNING {
+				desired = append(desired, ownedRecordSets(instance, zone)...)
+			}
+		}
+
+		records, err := c.DNSRecordList(ctx, zone.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, rs := range records {
+			if name, ok := ownerRecordName(rs); ok {
+				names[name] = true
+			}
+		}
+
+		var current []*dns.RecordSet
+		for _, rs := range records {
+			if name, ok := recordInstanceName(rs); ok && names[name] {
+				current = append(current, rs)
+			}
+		}
+
+		replacements, deletions := diffRecordSets(current, desired)
+		op, err := c.upsertRecordSets(ctx, zone.Id, replacements, deletions)
+		if err != nil {
+			return nil, err
+		}
+		if op == nil {
+			continue
+		}
+		if err = op.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("DNS zone %s: %w", zone.Name, err)
+		}
+
+		for _, rs := range replacements {
+			out = append(out, DNSRecordChange{Zone: zone.Zone, Name: rs.Name, Type: rs.Type, Data: rs.Data})
+		}
+		for _, rs := range deletions {
+			out = append(out, DNSRecordChange{Zone: zone.Zone, Name: rs.Name, Type: rs.Type, Data: rs.Data, Deleted: true})
+		}
+	}
+
+	return out, nil
+}
+
+func (c *Client) upsertRecordSets(
+	ctx context.Context,
+	zoneID string,
+	replacements, deletions []*dns.RecordSet,
+) (*operation.Operation, error) {
+	if len(replacements) == 0 && len(deletions) == 0 {
+		return nil, nil
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.DnsZone().UpsertRecordSets(cctx, &dns.UpsertRecordSetsRequest{
+		DnsZoneId:    zoneID,
+		Replacements: replacements,
+		Deletions:    deletions,
+	}))
+}
+
+// instanceRecords returns the record sets of the name of the types managed for instances,
+// and the TXT record marking them as created by ks.
+func instanceRecords(lst []*dns.RecordSet, name string) []*dns.RecordSet {
+	var out []*dns.RecordSet
+	for _, rs := range lst {
+		if n, ok := recordInstanceName(rs); ok && n == name {
+			out = append(out, rs)
+		}
+	}
+
+	return out
+}
+
+// recordInstanceName returns the name of the instance records the record set is one of,
+// false if it is neither of a type managed for instances nor the TXT record marking them.
+func recordInstanceName(rs *dns.RecordSet) (string, bool) {
+	if slices.Contains(instanceRecordTypes, rs.Type) {
+		return rs.Name, true
+	}
+
+	return ownerRecordName(rs)
+}
+
+// ownerRecordName returns the name of the records the TXT record marks as created by ks.
+// The data is compared unquoted, as the API may return it quoted.
+func ownerRecordName(rs *dns.RecordSet) (string, bool) {
+	name, ok := strings.CutPrefix(rs.Name, dnsOwnerPrefix)
+	if !ok || rs.Type != "TXT" {
+		return "", false
+	}
+	for _, d := range rs.Data {
+		if strings.Trim(d, `"`) == dnsOwnerData {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// diffRecordSets returns the desired record sets which differ from the current ones,
+// and the current record sets which are not desired.
+func diffRecordSets(current, desired []*dns.RecordSet) (replacements, deletions []*dns.RecordSet) {
+	key := func(rs *dns.RecordSet) string { return rs.Name + " " + rs.Type }
+
+	have := make(map[string]*dns.RecordSet, len(current))
+	for _, rs := range current {
+		have[key(rs)] = rs
+	}
+	want := make(map[string]bool, len(desired))
+	for _, rs := range desired {
+		want[key(rs)] = true
+		if cur, ok := have[key(rs)]; !ok || cur.Ttl != rs.Ttl || !slices.Equal(cur.Data, rs.Data) {
+			replacements = append(replacements, rs)
+		}
+	}
+	for _, rs := range current {
+		if !want[key(rs)] {
+			deletions = append(deletions, rs)
+		}
+	}
+
+	return replacements, deletions
+}
@@ -0,0 +1,80 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+)
+
+func TestDiffRecordSets(t *testing.T) {
+	a := func(name string, data ...string) *dns.RecordSet {
+		return &dns.RecordSet{Name: name, Type: "A", Ttl: DNSRecordTTL, Data: data}
+	}
+	current := []*dns.RecordSet{
+		a("same.", "10.0.0.1"),
+		a("changed.", "10.0.0.2"),
+		{Name: "ttl.", Type: "A", Ttl: 300, Data: []string{"10.0.0.3"}},
+		a("stale.", "10.0.0.4"),
+	}
+	desired := []*dns.RecordSet{
+		a("same.", "10.0.0.1"),
+		a("changed.", "10.0.0.20"),
+		a("ttl.", "10.0.0.3"),
+		a("new.", "10.0.0.5"),
+		{Name: "same.", Type: "AAAA", Ttl: DNSRecordTTL, Data: []string{"fd00::1"}},
+	}
+
+	names := func(lst []*dns.RecordSet) []string {
+		var out []string
+		for _, rs := range lst {
+			out = append(out, rs.Name+" "+rs.Type)
+		}
+		return out
+	}
+	replacements, deletions := diffRecordSets(current, desired)
+	if got, want := names(replacements), []string{"changed. A", "ttl. A", "new. A", "same. AAAA"}; !slices.Equal(got, want) {
+		t.Errorf("replacements %v, want %v", got, want)
+	}
+	if got, want := names(deletions), []string{"stale. A"}; !slices.Equal(got, want) {
+		t.Errorf("deletions %v, want %v", got, want)
+	}
+}
+
+func TestRecordInstanceName(t *testing.T) {
+	tests := []struct {
+		rs   *dns.RecordSet
+		want string
+		ok   bool
+	}{
+		{&dns.RecordSet{Name: "web.ks.", Type: "A"}, "web.ks.", true},
+		{&dns.RecordSet{Name: "web.ks.", Type: "AAAA"}, "web.ks.", true},
+		{&dns.RecordSet{Name: "_ks.web.ks.", Type: "TXT", Data: []string{dnsOwnerData}}, "web.ks.", true},
+		{&dns.RecordSet{Name: "_ks.web.ks.", Type: "TXT", Data: []string{`"` + dnsOwnerData + `"`}}, "web.ks.", true},
+		{&dns.RecordSet{Name: "_ks.web.ks.", Type: "TXT", Data: []string{"v=spf1 -all"}}, "", false},
+		{&dns.RecordSet{Name: "web.ks.", Type: "TXT", Data: []string{dnsOwnerData}}, "", false},
+		{&dns.RecordSet{Name: "web.ks.", Type: "CNAME"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := recordInstanceName(tt.rs)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s %s %v: got %q, %v, want %q, %v", tt.rs.Name, tt.rs.Type, tt.rs.Data, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/ks-tool/ks/pkg/remote"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// FPrintExecResults prints the summary of a remote command.
+func FPrintExecResults(w io.Writer, results []remote.ExecResult) {
+	tbl := table.NewWriter()
+	tbl.SetOutputMirror(w)
+	tbl.AppendHeader(table.Row{"Name", "Exit", "Duration", "Error"})
+
+	for _, res := range results {
+		var msg string
+		if res.Err != nil {
+			msg = res.Err.Error()
+		}
+
+		tbl.AppendRow(table.Row{res.Name, res.ExitCode, res.Duration.Round(time.Second / 10).String(), msg})
+	}
+
+	tbl.Render()
+}
+
+// FPrintExecReport prints the results of a remote command including its output as JSON or YAML.
+func FPrintExecReport(w io.Writer, p *Printer, results []remote.ExecResult) error {
+	type entry struct {
+		Name     string `json:"name"`
+		ExitCode int    `json:"exit_code"`
+		Stdout   string `json:"stdout"`
+		Stderr   string `json:"stderr"`
+		Error    string `json:"error,omitempty"`
+		Duration string `json:"duration"`
+	}
+
+	report := make([]entry, 0, len(results))
+	for _, res := range results {
+		e := entry{
+			Name:     res.Name,
+			ExitCode: res.ExitCode,
+			Stdout:   res.Stdout,
+			Stderr:   res.Stderr,
+			Duration: res.Duration.Round(time.Millisecond).String(),
+		}
+		if res.Err != nil {
+			e.Error = res.Err.Error()
+		}
+		report = append(report, e)
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return p.write(w, b)
+}
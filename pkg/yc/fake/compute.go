@@ -0,0 +1,569 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type instanceService Cloud
+
+func (s *instanceService) Create(_ context.Context, in *compute.CreateInstanceRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	for _, i := range c.instances {
+		if len(in.Name) > 0 && i.FolderId == in.FolderId && i.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "instance with name %s already exists", in.Name)
+		}
+	}
+	if len(in.ServiceAccountId) > 0 {
+		if _, ok := c.serviceAccounts[in.ServiceAccountId]; !ok {
+			return nil, notFound("service account", in.ServiceAccountId)
+		}
+	}
+
+	instance := &compute.Instance{
+		Id:               c.newID("fhm"),
+		FolderId:         in.FolderId,
+		CreatedAt:        timestamppb.Now(),
+		Name:             in.Name,
+		Description:      in.Description,
+		Labels:           in.Labels,
+		ZoneId:           in.ZoneId,
+		PlatformId:       in.PlatformId,
+		Status:           compute.Instance_PROVISIONING,
+		Metadata:         in.Metadata,
+		MetadataOptions:  in.MetadataOptions,
+		SchedulingPolicy: in.SchedulingPolicy,
+		ServiceAccountId: in.ServiceAccountId,
+	}
+	if r := in.ResourcesSpec; r != nil {
+		instance.Resources = &compute.Resources{
+			Memory:       r.Memory,
+			Cores:        r.Cores,
+			CoreFraction: r.CoreFraction,
+			Gpus:         r.Gpus,
+		}
+	}
+	if in.BootDiskSpec == nil {
+		return nil, status.Error(codes.InvalidArgument, "boot_disk_spec is required")
+	}
+	var disks []*compute.Disk
+	for n, spec := range append([]*compute.AttachedDiskSpec{in.BootDiskSpec}, in.SecondaryDiskSpecs...) {
+		disk, attached, err := c.attachedDisk(instance, spec)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			instance.BootDisk = attached
+		} else {
+			instance.SecondaryDisks = append(instance.SecondaryDisks, attached)
+		}
+		disks = append(disks, disk)
+	}
+
+	for idx, spec := range in.NetworkInterfaceSpecs {
+		nic, err := c.networkInterface(idx, spec)
+		if err != nil {
+			return nil, err
+		}
+		instance.NetworkInterfaces = append(instance.NetworkInterfaces, nic)
+	}
+	instance.Fqdn = instance.Id + ".auto.internal"
+
+	c.instances[instance.Id] = instance
+	for _, disk := range disks {
+		disk.InstanceIds = append(disk.InstanceIds, instance.Id)
+		c.disks[disk.Id] = disk
+	}
+	for _, nic := range instance.NetworkInterfaces {
+		if nat := nic.GetPrimaryV4Address().GetOneToOneNat(); nat != nil {
+			c.bindExternalAddress(instance, nat.Address)
+		}
+	}
+
+	return c.startOperation(
+		"Create instance",
+		&compute.CreateInstanceMetadata{InstanceId: instance.Id},
+		func() (proto.Message, error) {
+			instance.Status = compute.Instance_RUNNING
+			return proto.Clone(instance), nil
+		},
+	)
+}
+
+func (s *instanceService) AttachDisk(_ context.Context, in *compute.AttachInstanceDiskRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[in.InstanceId]
+	if !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+	if in.AttachedDiskSpec == nil {
+		return nil, status.Error(codes.InvalidArgument, "attached_disk_spec is required")
+	}
+
+	disk, attached, err := c.attachedDisk(instance, in.AttachedDiskSpec)
+	if err != nil {
+		return nil, err
+	}
+	disk.InstanceIds = append(disk.InstanceIds, instance.Id)
+	c.disks[disk.Id] = disk
+
+	return c.startOperation(
+		"Attach disk",
+		&compute.AttachInstanceDiskMetadata{InstanceId: instance.Id, DiskId: disk.Id},
+		func() (proto.Message, error) {
+			instance.SecondaryDisks = append(instance.SecondaryDisks, attached)
+			return proto.Clone(instance), nil
+		},
+	)
+}
+
+func (s *instanceService) Delete(_ context.Context, in *compute.DeleteInstanceRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[in.InstanceId]
+	if !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+	instance.Status = compute.Instance_DELETING
+
+	return c.startOperation(
+		"Delete instance",
+		&compute.DeleteInstanceMetadata{InstanceId: instance.Id},
+		func() (proto.Message, error) {
+			for _, a := range instanceDisks(instance) {
+				if a.AutoDelete {
+					delete(c.disks, a.DiskId)
+				} else if disk, ok := c.disks[a.DiskId]; ok {
+					disk.InstanceIds = slices.DeleteFunc(disk.InstanceIds, func(id string) bool { return id == instance.Id })
+				}
+			}
+			for _, nic := range instance.NetworkInterfaces {
+				c.releaseExternalAddress(nic.GetPrimaryV4Address().GetOneToOneNat().GetAddress())
+			}
+			delete(c.instances, instance.Id)
+			delete(c.serialOutput, instance.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *instanceService) DetachDisk(_ context.Context, in *compute.DetachInstanceDiskRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[in.InstanceId]
+	if !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+	match := func(a *compute.AttachedDisk) bool {
+		if id := in.GetDiskId(); len(id) > 0 {
+			return a.DiskId == id
+		}
+		return len(in.GetDeviceName()) > 0 && a.DeviceName == in.GetDeviceName()
+	}
+	if instance.BootDisk != nil && match(instance.BootDisk) {
+		return nil, status.Errorf(codes.InvalidArgument, "boot disk of instance %s can't be detached", instance.Id)
+	}
+
+	idx := slices.IndexFunc(instance.SecondaryDisks, match)
+	if idx < 0 {
+		return nil, status.Errorf(codes.NotFound, "disk is not attached to instance %s", instance.Id)
+	}
+	diskID := instance.SecondaryDisks[idx].DiskId
+
+	return c.startOperation(
+		"Detach disk",
+		&compute.DetachInstanceDiskMetadata{InstanceId: instance.Id, DiskId: diskID},
+		func() (proto.Message, error) {
+			instance.SecondaryDisks = slices.DeleteFunc(instance.SecondaryDisks, func(a *compute.AttachedDisk) bool {
+				return a.DiskId == diskID
+			})
+			if disk, ok := c.disks[diskID]; ok {
+				disk.InstanceIds = slices.DeleteFunc(disk.InstanceIds, func(id string) bool { return id == instance.Id })
+			}
+			return proto.Clone(instance), nil
+		},
+	)
+}
+
+func (s *instanceService) Get(_ context.Context, in *compute.GetInstanceRequest, _ ...grpc.CallOption) (*compute.Instance, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[in.InstanceId]
+	if !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+
+	out := proto.Clone(instance).(*compute.Instance)
+	if in.View != compute.InstanceView_FULL {
+		out.Metadata = nil
+	}
+
+	return out, nil
+}
+
+func (s *instanceService) GetSerialPortOutput(
+	_ context.Context,
+	in *compute.GetInstanceSerialPortOutputRequest,
+	_ ...grpc.CallOption,
+) (*compute.GetInstanceSerialPortOutputResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.instances[in.InstanceId]; !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+
+	return &compute.GetInstanceSerialPortOutputResponse{Contents: c.serialOutput[in.InstanceId]}, nil
+}
+
+func (s *instanceService) List(_ context.Context, in *compute.ListInstancesRequest, _ ...grpc.CallOption) (*compute.ListInstancesResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*compute.Instance
+	for _, id := range sortedKeys(c.instances) {
+		i := c.instances[id]
+		if i.FolderId == in.FolderId && matchFilter(conds, instanceField(i)) {
+			items = append(items, proto.Clone(i).(*compute.Instance))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compute.ListInstancesResponse{Instances: items, NextPageToken: next}, nil
+}
+
+func (s *instanceService) Start(_ context.Context, in *compute.StartInstanceRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[in.InstanceId]
+	if !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+	if instance.Status != compute.Instance_STOPPED {
+		return nil, status.Errorf(codes.FailedPrecondition, "instance %s is %s", instance.Id, instance.Status)
+	}
+	instance.Status = compute.Instance_STARTING
+
+	return c.startOperation(
+		"Start instance",
+		&compute.StartInstanceMetadata{InstanceId: instance.Id},
+		func() (proto.Message, error) {
+			instance.Status = compute.Instance_RUNNING
+			return proto.Clone(instance), nil
+		},
+	)
+}
+
+func (s *instanceService) Stop(_ context.Context, in *compute.StopInstanceRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[in.InstanceId]
+	if !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+	if instance.Status != compute.Instance_RUNNING {
+		return nil, status.Errorf(codes.FailedPrecondition, "instance %s is %s", instance.Id, instance.Status)
+	}
+	instance.Status = compute.Instance_STOPPING
+
+	return c.startOperation(
+		"Stop instance",
+		&compute.StopInstanceMetadata{InstanceId: instance.Id},
+		func() (proto.Message, error) {
+			instance.Status = compute.Instance_STOPPED
+			return proto.Clone(instance), nil
+		},
+	)
+}
+
+// stoppedOnlyPaths are the update mask paths which require the instance to be stopped.
+var stoppedOnlyPaths = map[string]bool{
+	"platform_id":       true,
+	"resources_spec":    true,
+	"scheduling_policy": true,
+}
+
+func (s *instanceService) Update(_ context.Context, in *compute.UpdateInstanceRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	instance, ok := c.instances[in.InstanceId]
+	if !ok {
+		return nil, notFound("instance", in.InstanceId)
+	}
+
+	paths := in.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+	for _, path := range paths {
+		if stoppedOnlyPaths[path] && instance.Status != compute.Instance_STOPPED {
+			return nil, status.Errorf(codes.FailedPrecondition, "instance %s must be stopped to update %s", instance.Id, path)
+		}
+	}
+	if len(in.ServiceAccountId) > 0 {
+		if _, ok := c.serviceAccounts[in.ServiceAccountId]; !ok {
+			return nil, notFound("service account", in.ServiceAccountId)
+		}
+	}
+
+	updated := proto.Clone(instance).(*compute.Instance)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			updated.Name = in.Name
+		case "description":
+			updated.Description = in.Description
+		case "labels":
+			updated.Labels = in.Labels
+		case "metadata":
+			updated.Metadata = in.Metadata
+		case "platform_id":
+			updated.PlatformId = in.PlatformId
+		case "resources_spec":
+			r := in.GetResourcesSpec()
+			updated.Resources = &compute.Resources{
+				Memory:       r.GetMemory(),
+				Cores:        r.GetCores(),
+				CoreFraction: r.GetCoreFraction(),
+				Gpus:         r.GetGpus(),
+			}
+		case "scheduling_policy":
+			updated.SchedulingPolicy = in.SchedulingPolicy
+		case "service_account_id":
+			updated.ServiceAccountId = in.ServiceAccountId
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask path %s", path)
+		}
+	}
+
+	return c.startOperation(
+		"Update instance",
+		&compute.UpdateInstanceMetadata{InstanceId: instance.Id},
+		func() (proto.Message, error) {
+			proto.Reset(instance)
+			proto.Merge(instance, updated)
+			return proto.Clone(instance), nil
+		},
+	)
+}
+
+// networkInterface builds an attached interface, allocating the internal address
+// from the subnet CIDR and, when NAT is requested, a public address. Must be called with c.mu held.
+func (c *Cloud) networkInterface(idx int, spec *compute.NetworkInterfaceSpec) (*compute.NetworkInterface, error) {
+	subnet, ok := c.subnets[spec.SubnetId]
+	if !ok {
+		return nil, notFound("subnet", spec.SubnetId)
+	}
+	for _, id := range spec.SecurityGroupIds {
+		group, ok := c.securityGroups[id]
+		if !ok {
+			return nil, notFound("security group", id)
+		}
+		if group.NetworkId != subnet.NetworkId {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"security group %s is not in network %s of subnet %s", id, subnet.NetworkId, subnet.Id)
+		}
+	}
+
+	nic := &compute.NetworkInterface{
+		Index:            strconv.Itoa(idx),
+		MacAddress:       fmt.Sprintf("d0:0d:%02x:%02x:%02x:%02x", byte(c.seq>>16), byte(c.seq>>8), byte(c.seq), byte(idx)),
+		SubnetId:         subnet.Id,
+		SecurityGroupIds: spec.SecurityGroupIds,
+	}
+
+	if v4 := spec.PrimaryV4AddressSpec; v4 != nil {
+		address := v4.Address
+		if len(address) > 0 {
+			if err := c.checkStaticAddress(subnet, address); err != nil {
+				return nil, err
+			}
+		}
+		for len(address) == 0 || c.addressInUse(subnet.Id, address) {
+			var err error
+			if address, err = c.allocateAddress(subnet.Id, subnet.V4CidrBlocks); err != nil {
+				return nil, err
+			}
+		}
+		nic.PrimaryV4Address = &compute.PrimaryAddress{Address: address}
+
+		if nat := v4.OneToOneNatSpec; nat != nil {
+			public, err := c.externalAddress(nat.Address)
+			if err != nil {
+				return nil, err
+			}
+			nic.PrimaryV4Address.OneToOneNat = &compute.OneToOneNat{
+				Address:   public,
+				IpVersion: compute.IpVersion_IPV4,
+			}
+		}
+	}
+
+	if v6 := spec.PrimaryV6AddressSpec; v6 != nil {
+		if len(subnet.V6CidrBlocks) == 0 {
+			return nil, status.Errorf(codes.FailedPrecondition, "subnet %s has no IPv6 CIDR blocks", subnet.Id)
+		}
+		address := v6.Address
+		if len(address) == 0 {
+			_, ipnet, err := net.ParseCIDR(subnet.V6CidrBlocks[0])
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "subnet %s: %s", subnet.Id, err)
+			}
+			key := subnet.Id + "/" + ipnet.String()
+			c.addresses[key]++
+			ip := slices.Clone(ipnet.IP.To16())
+			ip[14], ip[15] = byte(c.addresses[key]>>8), byte(c.addresses[key])
+			address = ip.String()
+		}
+		nic.PrimaryV6Address = &compute.PrimaryAddress{Address: address}
+	}
+
+	return nic, nil
+}
+
+// checkStaticAddress checks that the address is a free host address of the subnet. Must be called with c.mu held.
+func (c *Cloud) checkStaticAddress(subnet *vpc.Subnet, address string) error {
+	ip := net.ParseIP(address)
+	var inSubnet bool
+	for _, cidr := range subnet.V4CidrBlocks {
+		if _, ipnet, err := net.ParseCIDR(cidr); err == nil && ip != nil && ipnet.Contains(ip) {
+			inSubnet = true
+		}
+	}
+	if !inSubnet {
+		return status.Errorf(codes.InvalidArgument, "address %s is not in subnet %s", address, subnet.Id)
+	}
+	if c.addressInUse(subnet.Id, address) {
+		return status.Errorf(codes.AlreadyExists, "address %s is already used in subnet %s", address, subnet.Id)
+	}
+
+	return nil
+}
+
+// addressInUse reports whether an interface in the subnet has the internal address. Must be called with c.mu held.
+func (c *Cloud) addressInUse(subnetID, address string) bool {
+	for _, i := range c.instances {
+		for _, nic := range i.NetworkInterfaces {
+			if nic.SubnetId == subnetID && nic.GetPrimaryV4Address().GetAddress() == address {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// allocateAddress returns the next free host address of the first CIDR block,
+// skipping the network, gateway and DNS addresses. Must be called with c.mu held.
+func (c *Cloud) allocateAddress(pool string, cidrs []string) (string, error) {
+	if len(cidrs) == 0 {
+		return "", status.Errorf(codes.FailedPrecondition, "subnet %s has no IPv4 CIDR blocks", pool)
+	}
+
+	ip, ipnet, err := net.ParseCIDR(cidrs[0])
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "subnet %s: %s", pool, err)
+	}
+
+	key := pool + "/" + ipnet.String()
+	c.addresses[key]++
+	n := c.addresses[key] + 2
+
+	ip = ip.Mask(ipnet.Mask).To4()
+	if ip == nil {
+		return "", status.Errorf(codes.Internal, "subnet %s: IPv4 CIDR expected", pool)
+	}
+	v := uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+	v += uint32(n)
+
+	out := net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	if !ipnet.Contains(out) {
+		return "", status.Errorf(codes.ResourceExhausted, "no free addresses in %s", ipnet)
+	}
+
+	return out.String(), nil
+}
+
+func instanceField(i *compute.Instance) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return i.Id, true
+		case "name":
+			return i.Name, true
+		case "status":
+			return i.Status.String(), true
+		case "zone_id", "zoneId":
+			return i.ZoneId, true
+		case "platform_id", "platformId":
+			return i.PlatformId, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := i.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
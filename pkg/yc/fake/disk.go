@@ -0,0 +1,413 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// imageDiskSize is the size of a disk created from an image without the size given.
+const imageDiskSize = 10 << 30
+
+type diskService Cloud
+
+func (s *diskService) Create(_ context.Context, in *compute.CreateDiskRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	if len(in.ZoneId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "zone_id is required")
+	}
+
+	disk, err := c.newDisk(in.FolderId, in.ZoneId, in.Name, in.TypeId, in.Size, in.GetSnapshotId(), in.GetImageId())
+	if err != nil {
+		return nil, err
+	}
+	disk.Description = in.Description
+	disk.Labels = in.Labels
+	disk.Status = compute.Disk_CREATING
+	c.disks[disk.Id] = disk
+
+	return c.startOperation(
+		"Create disk",
+		&compute.CreateDiskMetadata{DiskId: disk.Id},
+		func() (proto.Message, error) {
+			disk.Status = compute.Disk_READY
+			return proto.Clone(disk), nil
+		},
+	)
+}
+
+func (s *diskService) Delete(_ context.Context, in *compute.DeleteDiskRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	disk, ok := c.disks[in.DiskId]
+	if !ok {
+		return nil, notFound("disk", in.DiskId)
+	}
+	if len(disk.InstanceIds) > 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "disk %s is attached to instance %s", disk.Id, disk.InstanceIds[0])
+	}
+	disk.Status = compute.Disk_DELETING
+
+	return c.startOperation(
+		"Delete disk",
+		&compute.DeleteDiskMetadata{DiskId: disk.Id},
+		func() (proto.Message, error) {
+			delete(c.disks, disk.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *diskService) Get(_ context.Context, in *compute.GetDiskRequest, _ ...grpc.CallOption) (*compute.Disk, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	disk, ok := c.disks[in.DiskId]
+	if !ok {
+		return nil, notFound("disk", in.DiskId)
+	}
+
+	return proto.Clone(disk).(*compute.Disk), nil
+}
+
+func (s *diskService) List(_ context.Context, in *compute.ListDisksRequest, _ ...grpc.CallOption) (*compute.ListDisksResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*compute.Disk
+	for _, id := range sortedKeys(c.disks) {
+		disk := c.disks[id]
+		if disk.FolderId == in.FolderId && matchFilter(conds, diskField(disk)) {
+			items = append(items, proto.Clone(disk).(*compute.Disk))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compute.ListDisksResponse{Disks: items, NextPageToken: next}, nil
+}
+
+func (s *diskService) Update(_ context.Context, in *compute.UpdateDiskRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	disk, ok := c.disks[in.DiskId]
+	if !ok {
+		return nil, notFound("disk", in.DiskId)
+	}
+
+	paths := in.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+
+	updated := proto.Clone(disk).(*compute.Disk)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			updated.Name = in.Name
+		case "description":
+			updated.Description = in.Description
+		case "labels":
+			updated.Labels = in.Labels
+		case "size":
+			if in.Size < disk.Size {
+				return nil, status.Errorf(codes.InvalidArgument, "disk %s can't be shrunk from %d to %d bytes", disk.Id, disk.Size, in.Size)
+			}
+			updated.Size = in.Size
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask path %s", path)
+		}
+	}
+
+	return c.startOperation(
+		"Update disk",
+		&compute.UpdateDiskMetadata{DiskId: disk.Id},
+		func() (proto.Message, error) {
+			proto.Reset(disk)
+			proto.Merge(disk, updated)
+			return proto.Clone(disk), nil
+		},
+	)
+}
+
+// newDisk builds a disk which is not registered yet, checking the name and the source.
+// Must be called with c.mu held.
+func (c *Cloud) newDisk(folderID, zone, name, typeID string, size int64, snapshotID, imageID string) (*compute.Disk, error) {
+	if size <= 0 && len(imageID) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "size is required")
+	}
+	if size <= 0 {
+		size = imageDiskSize
+	}
+	for _, d := range c.disks {
+		if len(name) > 0 && d.FolderId == folderID && d.Name == name {
+			return nil, status.Errorf(codes.AlreadyExists, "disk with name %s already exists", name)
+		}
+	}
+
+	disk := &compute.Disk{
+		Id:        c.newID("fhd"),
+		FolderId:  folderID,
+		CreatedAt: timestamppb.Now(),
+		Name:      name,
+		TypeId:    typeID,
+		ZoneId:    zone,
+		Size:      size,
+		BlockSize: 4096,
+		Status:    compute.Disk_READY,
+	}
+	switch {
+	case len(snapshotID) > 0:
+		snapshot, ok := c.snapshots[snapshotID]
+		if !ok {
+			return nil, notFound("snapshot", snapshotID)
+		}
+		if size < snapshot.DiskSize {
+			return nil, status.Errorf(codes.InvalidArgument, "disk size must be at least %d bytes of snapshot %s", snapshot.DiskSize, snapshot.Id)
+		}
+		disk.Source = &compute.Disk_SourceSnapshotId{SourceSnapshotId: snapshotID}
+	case len(imageID) > 0:
+		disk.Source = &compute.Disk_SourceImageId{SourceImageId: imageID}
+	}
+
+	return disk, nil
+}
+
+// attachedDisk resolves the attachment spec to an existing disk or a new one,
+// which is not registered yet. Must be called with c.mu held.
+func (c *Cloud) attachedDisk(instance *compute.Instance, spec *compute.AttachedDiskSpec) (*compute.Disk, *compute.AttachedDisk, error) {
+	var disk *compute.Disk
+	switch src := spec.Disk.(type) {
+	case *compute.AttachedDiskSpec_DiskId:
+		d, ok := c.disks[src.DiskId]
+		if !ok {
+			return nil, nil, notFound("disk", src.DiskId)
+		}
+		if len(d.InstanceIds) > 0 {
+			return nil, nil, status.Errorf(codes.FailedPrecondition, "disk %s is attached to instance %s", d.Id, d.InstanceIds[0])
+		}
+		if d.ZoneId != instance.ZoneId {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "disk %s is in zone %s, instance in %s", d.Id, d.ZoneId, instance.ZoneId)
+		}
+		disk = d
+	case *compute.AttachedDiskSpec_DiskSpec_:
+		s := src.DiskSpec
+		d, err := c.newDisk(instance.FolderId, instance.ZoneId, s.Name, s.TypeId, s.Size, s.GetSnapshotId(), s.GetImageId())
+		if err != nil {
+			return nil, nil, err
+		}
+		d.Description = s.Description
+		disk = d
+	default:
+		return nil, nil, status.Error(codes.InvalidArgument, "disk is required")
+	}
+
+	deviceName := spec.DeviceName
+	if len(deviceName) == 0 {
+		deviceName = disk.Id
+	}
+	for _, a := range instanceDisks(instance) {
+		if a.DeviceName == deviceName {
+			return nil, nil, status.Errorf(codes.AlreadyExists, "instance %s already has device %s", instance.Id, deviceName)
+		}
+	}
+
+	mode := compute.AttachedDisk_READ_WRITE
+	if spec.Mode == compute.AttachedDiskSpec_READ_ONLY {
+		mode = compute.AttachedDisk_READ_ONLY
+	}
+
+	return disk, &compute.AttachedDisk{
+		Mode:       mode,
+		DeviceName: deviceName,
+		AutoDelete: spec.AutoDelete,
+		DiskId:     disk.Id,
+	}, nil
+}
+
+// instanceDisks returns the boot and the secondary disks of the instance.
+func instanceDisks(i *compute.Instance) []*compute.AttachedDisk {
+	var out []*compute.AttachedDisk
+	if i.BootDisk != nil {
+		out = append(out, i.BootDisk)
+	}
+
+	return append(out, i.SecondaryDisks...)
+}
+
+func diskField(d *compute.Disk) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return d.Id, true
+		case "name":
+			return d.Name, true
+		case "status":
+			return d.Status.String(), true
+		case "zone_id", "zoneId":
+			return d.ZoneId, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := d.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
+
+type snapshotService Cloud
+
+func (s *snapshotService) Create(_ context.Context, in *compute.CreateSnapshotRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	disk, ok := c.disks[in.DiskId]
+	if !ok {
+		return nil, notFound("disk", in.DiskId)
+	}
+	for _, sn := range c.snapshots {
+		if len(in.Name) > 0 && sn.FolderId == in.FolderId && sn.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "snapshot with name %s already exists", in.Name)
+		}
+	}
+
+	snapshot := &compute.Snapshot{
+		Id:           c.newID("fd8"),
+		FolderId:     in.FolderId,
+		CreatedAt:    timestamppb.Now(),
+		Name:         in.Name,
+		Description:  in.Description,
+		Labels:       in.Labels,
+		StorageSize:  disk.Size,
+		DiskSize:     disk.Size,
+		Status:       compute.Snapshot_CREATING,
+		SourceDiskId: disk.Id,
+	}
+	c.snapshots[snapshot.Id] = snapshot
+
+	return c.startOperation(
+		"Create snapshot",
+		&compute.CreateSnapshotMetadata{SnapshotId: snapshot.Id, DiskId: disk.Id},
+		func() (proto.Message, error) {
+			snapshot.Status = compute.Snapshot_READY
+			return proto.Clone(snapshot), nil
+		},
+	)
+}
+
+func (s *snapshotService) Delete(_ context.Context, in *compute.DeleteSnapshotRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot, ok := c.snapshots[in.SnapshotId]
+	if !ok {
+		return nil, notFound("snapshot", in.SnapshotId)
+	}
+	snapshot.Status = compute.Snapshot_DELETING
+
+	return c.startOperation(
+		"Delete snapshot",
+		&compute.DeleteSnapshotMetadata{SnapshotId: snapshot.Id},
+		func() (proto.Message, error) {
+			delete(c.snapshots, snapshot.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *snapshotService) Get(_ context.Context, in *compute.GetSnapshotRequest, _ ...grpc.CallOption) (*compute.Snapshot, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snapshot, ok := c.snapshots[in.SnapshotId]
+	if !ok {
+		return nil, notFound("snapshot", in.SnapshotId)
+	}
+
+	return proto.Clone(snapshot).(*compute.Snapshot), nil
+}
+
+func (s *snapshotService) List(_ context.Context, in *compute.ListSnapshotsRequest, _ ...grpc.CallOption) (*compute.ListSnapshotsResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*compute.Snapshot
+	for _, id := range sortedKeys(c.snapshots) {
+		sn := c.snapshots[id]
+		field := func(field string) (string, bool) {
+			switch field {
+			case "id":
+				return sn.Id, true
+			case "name":
+				return sn.Name, true
+			}
+			return "", false
+		}
+		if sn.FolderId == in.FolderId && matchFilter(conds, field) {
+			items = append(items, proto.Clone(sn).(*compute.Snapshot))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compute.ListSnapshotsResponse{Snapshots: items, NextPageToken: next}, nil
+}
@@ -0,0 +1,275 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type dnsZoneService Cloud
+
+func (s *dnsZoneService) Create(_ context.Context, in *dns.CreateDnsZoneRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	if !strings.HasSuffix(in.Zone, ".") {
+		return nil, status.Errorf(codes.InvalidArgument, "zone %q must end with a dot", in.Zone)
+	}
+	for _, id := range in.GetPrivateVisibility().GetNetworkIds() {
+		if _, ok := c.networks[id]; !ok {
+			return nil, notFound("network", id)
+		}
+	}
+	for _, z := range c.dnsZones {
+		if len(in.Name) > 0 && z.FolderId == in.FolderId && z.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "DNS zone with name %s already exists", in.Name)
+		}
+	}
+
+	zone := &dns.DnsZone{
+		Id:                 c.newID("dns"),
+		FolderId:           in.FolderId,
+		CreatedAt:          timestamppb.Now(),
+		Name:               in.Name,
+		Description:        in.Description,
+		Labels:             in.Labels,
+		Zone:               in.Zone,
+		PrivateVisibility:  in.PrivateVisibility,
+		PublicVisibility:   in.PublicVisibility,
+		DeletionProtection: in.DeletionProtection,
+	}
+	c.dnsZones[zone.Id] = zone
+	c.recordSets[zone.Id] = make(map[string]*dns.RecordSet)
+
+	return c.startOperation(
+		"Create DNS zone",
+		&dns.CreateDnsZoneMetadata{DnsZoneId: zone.Id},
+		func() (proto.Message, error) {
+			return proto.Clone(zone), nil
+		},
+	)
+}
+
+func (s *dnsZoneService) Get(_ context.Context, in *dns.GetDnsZoneRequest, _ ...grpc.CallOption) (*dns.DnsZone, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zone, ok := c.dnsZones[in.DnsZoneId]
+	if !ok {
+		return nil, notFound("DNS zone", in.DnsZoneId)
+	}
+
+	return proto.Clone(zone).(*dns.DnsZone), nil
+}
+
+func (s *dnsZoneService) List(_ context.Context, in *dns.ListDnsZonesRequest, _ ...grpc.CallOption) (*dns.ListDnsZonesResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*dns.DnsZone
+	for _, id := range sortedKeys(c.dnsZones) {
+		zone := c.dnsZones[id]
+		if zone.FolderId == in.FolderId && matchFilter(conds, dnsZoneField(zone)) {
+			items = append(items, proto.Clone(zone).(*dns.DnsZone))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dns.ListDnsZonesResponse{DnsZones: items, NextPageToken: next}, nil
+}
+
+func (s *dnsZoneService) ListRecordSets(
+	_ context.Context,
+	in *dns.ListDnsZoneRecordSetsRequest,
+	_ ...grpc.CallOption,
+) (*dns.ListDnsZoneRecordSetsResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	records, ok := c.recordSets[in.DnsZoneId]
+	if !ok {
+		return nil, notFound("DNS zone", in.DnsZoneId)
+	}
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*dns.RecordSet
+	for _, key := range sortedKeys(records) {
+		rs := records[key]
+		if matchFilter(conds, recordSetField(rs)) {
+			items = append(items, proto.Clone(rs).(*dns.RecordSet))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dns.ListDnsZoneRecordSetsResponse{RecordSets: items, NextPageToken: next}, nil
+}
+
+// UpsertRecordSets deletes the given records, replaces whole record sets and merges records into record sets,
+// in this order. Relative names are made absolute within the zone.
+func (s *dnsZoneService) UpsertRecordSets(_ context.Context, in *dns.UpsertRecordSetsRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	zone, ok := c.dnsZones[in.DnsZoneId]
+	if !ok {
+		return nil, notFound("DNS zone", in.DnsZoneId)
+	}
+
+	normalize := func(lst []*dns.RecordSet) ([]*dns.RecordSet, error) {
+		out := make([]*dns.RecordSet, 0, len(lst))
+		for _, rs := range lst {
+			rs = proto.Clone(rs).(*dns.RecordSet)
+			if !strings.HasSuffix(rs.Name, ".") {
+				rs.Name += "." + zone.Zone
+			}
+			if rs.Name != zone.Zone && !strings.HasSuffix(rs.Name, "."+zone.Zone) {
+				return nil, status.Errorf(codes.InvalidArgument, "record %s is out of zone %s", rs.Name, zone.Zone)
+			}
+			if len(rs.Type) == 0 {
+				return nil, status.Errorf(codes.InvalidArgument, "type of record %s is required", rs.Name)
+			}
+			out = append(out, rs)
+		}
+		return out, nil
+	}
+	deletions, err := normalize(in.Deletions)
+	if err != nil {
+		return nil, err
+	}
+	replacements, err := normalize(in.Replacements)
+	if err != nil {
+		return nil, err
+	}
+	merges, err := normalize(in.Merges)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.startOperation(
+		"Upsert record sets",
+		&dns.UpsertRecordSetsMetadata{},
+		func() (proto.Message, error) {
+			records := c.recordSets[zone.Id]
+			diff := &dns.RecordSetDiff{}
+			for _, rs := range deletions {
+				cur, ok := records[recordSetKey(rs)]
+				if !ok {
+					continue
+				}
+				cur.Data = slices.DeleteFunc(cur.Data, func(d string) bool { return slices.Contains(rs.Data, d) })
+				if len(cur.Data) == 0 {
+					delete(records, recordSetKey(rs))
+				}
+				diff.Deletions = append(diff.Deletions, rs)
+			}
+			for _, rs := range replacements {
+				if cur, ok := records[recordSetKey(rs)]; ok {
+					diff.Deletions = append(diff.Deletions, proto.Clone(cur).(*dns.RecordSet))
+				}
+				records[recordSetKey(rs)] = rs
+				diff.Additions = append(diff.Additions, proto.Clone(rs).(*dns.RecordSet))
+			}
+			for _, rs := range merges {
+				cur, ok := records[recordSetKey(rs)]
+				if !ok {
+					records[recordSetKey(rs)] = rs
+				} else {
+					for _, d := range rs.Data {
+						if !slices.Contains(cur.Data, d) {
+							cur.Data = append(cur.Data, d)
+						}
+					}
+					cur.Ttl = rs.Ttl
+				}
+				diff.Additions = append(diff.Additions, proto.Clone(rs).(*dns.RecordSet))
+			}
+
+			return diff, nil
+		},
+	)
+}
+
+func recordSetKey(rs *dns.RecordSet) string {
+	return rs.Name + " " + rs.Type
+}
+
+func dnsZoneField(z *dns.DnsZone) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return z.Id, true
+		case "name":
+			return z.Name, true
+		case "zone":
+			return z.Zone, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := z.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
+
+func recordSetField(rs *dns.RecordSet) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "name":
+			return rs.Name, true
+		case "type":
+			return rs.Type, true
+		}
+
+		return "", false
+	}
+}
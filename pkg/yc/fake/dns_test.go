@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/yc"
+	"github.com/ks-tool/ks/pkg/yc/fake"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+)
+
+func TestDNSSync(t *testing.T) {
+	f, client := newTestCloud(t)
+	ctx := context.Background()
+
+	zone, err := client.DNSZoneEnsure(ctx, &yc.DNSZoneConfig{FolderID: testFolderID, Zone: "ks.internal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A record added by hand, e.g. of a load balancer, and a stale one of a deleted instance created by ks.
+	// The operation of the backend completes at once without polls.
+	f.Polls = 0
+	_, err = f.DnsZone().UpsertRecordSets(ctx, &dns.UpsertRecordSetsRequest{
+		DnsZoneId: zone.Id,
+		Replacements: []*dns.RecordSet{
+			{Name: "api.ks.internal.", Type: "A", Ttl: 300, Data: []string{"10.128.0.100"}},
+			{Name: "gone.ks.internal.", Type: "A", Ttl: 60, Data: []string{"10.128.0.9"}},
+			{Name: "_ks.gone.ks.internal.", Type: "TXT", Ttl: 60, Data: []string{`"managed=` + yc.KsToolKey + `"`}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Polls = fake.DefaultPolls
+
+	labels := map[string]string{common.ManagedKey: yc.KsToolKey, common.LabelDNSZoneKey: zone.Id}
+	nic := func(ip string) []*compute.NetworkInterface {
+		return []*compute.NetworkInterface{{PrimaryV4Address: &compute.PrimaryAddress{Address: ip}}}
+	}
+	f.AddInstance(&compute.Instance{FolderId: testFolderID, Name: "web-1", Labels: labels, NetworkInterfaces: nic("10.128.0.11")})
+	stopped := f.AddInstance(&compute.Instance{
+		FolderId:          testFolderID,
+		Name:              "web-2",
+		Labels:            labels,
+		Status:            compute.Instance_STOPPED,
+		NetworkInterfaces: nic("10.128.0.12"),
+	})
+	op, err := client.DNSInstanceRecordsUpdate(ctx, zone, &compute.Instance{
+		Name: stopped.Name, Labels: labels, NetworkInterfaces: stopped.NetworkInterfaces,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = op.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := client.DNSSync(ctx, testFolderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range changes {
+		change := "+"
+		if c.Deleted {
+			change = "-"
+		}
+		got = append(got, change+c.Name+" "+c.Type)
+	}
+	slices.Sort(got)
+	want := []string{
+		"+_ks.web-1.ks.internal. TXT",
+		"+web-1.ks.internal. A",
+		"-_ks.gone.ks.internal. TXT",
+		"-_ks.web-2.ks.internal. TXT",
+		"-gone.ks.internal. A",
+		"-web-2.ks.internal. A",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got changes %v, want %v", got, want)
+	}
+
+	records, err := client.DNSRecordList(ctx, zone.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, rs := range records {
+		names = append(names, rs.Name+" "+rs.Type)
+	}
+	slices.Sort(names)
+	want = []string{"_ks.web-1.ks.internal. TXT", "api.ks.internal. A", "web-1.ks.internal. A"}
+	if !slices.Equal(names, want) {
+		t.Fatalf("got records %v, want %v", names, want)
+	}
+
+	if changes, err = client.DNSSync(ctx, testFolderID); err != nil || len(changes) != 0 {
+		t.Fatalf("second sync: got changes %v, error %v", changes, err)
+	}
+}
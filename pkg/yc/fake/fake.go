@@ -0,0 +1,327 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake implements yc.Backend in memory. It simulates compute instances, disks,
+// snapshots, images, networks, subnets, route tables, NAT gateways, security groups, addresses,
+// DNS zones, service accounts and long-running operations, so that code built on pkg/yc can be
+// tested without a real Yandex Cloud folder.
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultPageSize = 100
+
+// DefaultPolls is the number of operation polls it takes an operation to complete.
+const DefaultPolls = 1
+
+// Cloud is an in-memory Yandex Cloud. The zero value is not usable, use New.
+type Cloud struct {
+	mu sync.Mutex
+	// Polls is the number of times an operation has to be polled before it is done.
+	// Zero makes every operation done as soon as it is returned.
+	Polls int
+
+	seq             int
+	instances       map[string]*compute.Instance
+	disks           map[string]*compute.Disk
+	snapshots       map[string]*compute.Snapshot
+	images          map[string]*compute.Image
+	instanceGroups  map[string]*instancegroup.InstanceGroup
+	groupInstances  map[string][]*instancegroup.ManagedInstance
+	networks        map[string]*vpc.Network
+	routeTables     map[string]*vpc.RouteTable
+	gateways        map[string]*vpc.Gateway
+	subnets         map[string]*vpc.Subnet
+	securityGroups  map[string]*vpc.SecurityGroup
+	serviceAccounts map[string]*iam.ServiceAccount
+	operations      map[string]*pendingOperation
+	addresses       map[string]int
+	vpcAddresses    map[string]*vpc.Address
+	dnsZones        map[string]*dns.DnsZone
+	recordSets      map[string]map[string]*dns.RecordSet
+	serialOutput    map[string]string
+}
+
+var _ yc.Backend = (*Cloud)(nil)
+
+func New() *Cloud {
+	return &Cloud{
+		Polls:           DefaultPolls,
+		instances:       make(map[string]*compute.Instance),
+		disks:           make(map[string]*compute.Disk),
+		snapshots:       make(map[string]*compute.Snapshot),
+		images:          make(map[string]*compute.Image),
+		instanceGroups:  make(map[string]*instancegroup.InstanceGroup),
+		groupInstances:  make(map[string][]*instancegroup.ManagedInstance),
+		networks:        make(map[string]*vpc.Network),
+		routeTables:     make(map[string]*vpc.RouteTable),
+		gateways:        make(map[string]*vpc.Gateway),
+		subnets:         make(map[string]*vpc.Subnet),
+		securityGroups:  make(map[string]*vpc.SecurityGroup),
+		serviceAccounts: make(map[string]*iam.ServiceAccount),
+		operations:      make(map[string]*pendingOperation),
+		addresses:       make(map[string]int),
+		vpcAddresses:    make(map[string]*vpc.Address),
+		dnsZones:        make(map[string]*dns.DnsZone),
+		recordSets:      make(map[string]map[string]*dns.RecordSet),
+		serialOutput:    make(map[string]string),
+	}
+}
+
+func (c *Cloud) Address() yc.AddressService { return (*addressService)(c) }
+
+func (c *Cloud) Disk() yc.DiskService { return (*diskService)(c) }
+
+func (c *Cloud) DnsZone() yc.DnsZoneService { return (*dnsZoneService)(c) }
+
+func (c *Cloud) Gateway() yc.GatewayService { return (*gatewayService)(c) }
+
+func (c *Cloud) Image() yc.ImageService { return (*imageService)(c) }
+
+func (c *Cloud) Instance() yc.InstanceService { return (*instanceService)(c) }
+
+func (c *Cloud) InstanceGroup() yc.InstanceGroupService { return (*instanceGroupService)(c) }
+
+func (c *Cloud) Network() yc.NetworkService { return (*networkService)(c) }
+
+func (c *Cloud) RouteTable() yc.RouteTableService { return (*routeTableService)(c) }
+
+func (c *Cloud) SecurityGroup() yc.SecurityGroupService { return (*securityGroupService)(c) }
+
+func (c *Cloud) Subnet() yc.SubnetService { return (*subnetService)(c) }
+
+func (c *Cloud) ServiceAccount() yc.ServiceAccountService { return (*serviceAccountService)(c) }
+
+func (c *Cloud) Operation() yc.OperationService { return (*operationService)(c) }
+
+func (c *Cloud) Snapshot() yc.SnapshotService { return (*snapshotService)(c) }
+
+// AddSubnet registers a subnet. Id and CreatedAt are generated when empty.
+func (c *Cloud) AddSubnet(s *vpc.Subnet) *vpc.Subnet {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s = proto.Clone(s).(*vpc.Subnet)
+	if len(s.Id) == 0 {
+		s.Id = c.newID("e9b")
+	}
+	if s.CreatedAt == nil {
+		s.CreatedAt = timestamppb.Now()
+	}
+	c.subnets[s.Id] = s
+
+	return proto.Clone(s).(*vpc.Subnet)
+}
+
+// AddNetwork registers a network. Id and CreatedAt are generated when empty.
+func (c *Cloud) AddNetwork(n *vpc.Network) *vpc.Network {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n = proto.Clone(n).(*vpc.Network)
+	if len(n.Id) == 0 {
+		n.Id = c.newID("enp")
+	}
+	if n.CreatedAt == nil {
+		n.CreatedAt = timestamppb.Now()
+	}
+	c.networks[n.Id] = n
+
+	return proto.Clone(n).(*vpc.Network)
+}
+
+// AddServiceAccount registers a service account. Id and CreatedAt are generated when empty.
+func (c *Cloud) AddServiceAccount(sa *iam.ServiceAccount) *iam.ServiceAccount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sa = proto.Clone(sa).(*iam.ServiceAccount)
+	if len(sa.Id) == 0 {
+		sa.Id = c.newID("aje")
+	}
+	if sa.CreatedAt == nil {
+		sa.CreatedAt = timestamppb.Now()
+	}
+	c.serviceAccounts[sa.Id] = sa
+
+	return proto.Clone(sa).(*iam.ServiceAccount)
+}
+
+// AddImage registers an image. Id, CreatedAt and Status are generated when empty.
+func (c *Cloud) AddImage(i *compute.Image) *compute.Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i = proto.Clone(i).(*compute.Image)
+	if len(i.Id) == 0 {
+		i.Id = c.newID("fd8")
+	}
+	if i.CreatedAt == nil {
+		i.CreatedAt = timestamppb.Now()
+	}
+	if i.Status == compute.Image_STATUS_UNSPECIFIED {
+		i.Status = compute.Image_READY
+	}
+	c.images[i.Id] = i
+
+	return proto.Clone(i).(*compute.Image)
+}
+
+// AddInstance registers an instance as is, bypassing operations.
+// Id, CreatedAt and Status are generated when empty.
+func (c *Cloud) AddInstance(i *compute.Instance) *compute.Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i = proto.Clone(i).(*compute.Instance)
+	if len(i.Id) == 0 {
+		i.Id = c.newID("fhm")
+	}
+	if i.CreatedAt == nil {
+		i.CreatedAt = timestamppb.Now()
+	}
+	if i.Status == compute.Instance_STATUS_UNSPECIFIED {
+		i.Status = compute.Instance_RUNNING
+	}
+	c.instances[i.Id] = i
+
+	return proto.Clone(i).(*compute.Instance)
+}
+
+// AppendSerialPortOutput adds the text to the serial port output of the instance.
+func (c *Cloud) AppendSerialPortOutput(id, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.serialOutput[id] += text
+}
+
+// GetDisk returns a copy of the disk with the given id, or nil.
+func (c *Cloud) GetDisk(id string) *compute.Disk {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.disks[id]
+	if !ok {
+		return nil
+	}
+
+	return proto.Clone(d).(*compute.Disk)
+}
+
+// GetInstance returns a copy of the instance with the given id, or nil.
+func (c *Cloud) GetInstance(id string) *compute.Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i, ok := c.instances[id]
+	if !ok {
+		return nil
+	}
+
+	return proto.Clone(i).(*compute.Instance)
+}
+
+// Instances returns copies of all instances ordered by id.
+func (c *Cloud) Instances() []*compute.Instance {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var out []*compute.Instance
+	for _, id := range sortedKeys(c.instances) {
+		out = append(out, proto.Clone(c.instances[id]).(*compute.Instance))
+	}
+
+	return out
+}
+
+// Pending returns the number of operations which are not done yet.
+func (c *Cloud) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int
+	for _, op := range c.operations {
+		if !op.proto.Done {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (c *Cloud) newID(prefix string) string {
+	c.seq++
+	return fmt.Sprintf("%s%017d", prefix, c.seq)
+}
+
+func notFound(kind, id string) error {
+	return status.Errorf(codes.NotFound, "%s %s not found", kind, id)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// page returns a page of items. Page tokens are offsets into the full listing.
+func page[T any](items []T, size int64, token string) ([]T, string, error) {
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	var offset int
+	if len(token) > 0 {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 || offset > len(items) {
+			return nil, "", status.Errorf(codes.InvalidArgument, "invalid page token %q", token)
+		}
+	}
+
+	end := offset + int(size)
+	if end >= len(items) {
+		return items[offset:], "", nil
+	}
+
+	return items[offset:end], strconv.Itoa(end), nil
+}
+
+func labelKey(field string) (string, bool) {
+	return strings.CutPrefix(field, "labels.")
+}
@@ -0,0 +1,233 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ks-tool/ks/pkg/yc"
+	"github.com/ks-tool/ks/pkg/yc/fake"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testFolderID = "b1gtest"
+
+func newTestCloud(t *testing.T) (*fake.Cloud, *yc.Client) {
+	t.Helper()
+
+	f := fake.New()
+	f.AddImage(&compute.Image{FolderId: testFolderID, Family: "ubuntu", Status: compute.Image_READY})
+	network := f.AddNetwork(&vpc.Network{FolderId: testFolderID, Name: "default"})
+	f.AddSubnet(&vpc.Subnet{
+		FolderId:     testFolderID,
+		NetworkId:    network.Id,
+		Name:         "default-a",
+		ZoneId:       yc.DefaultZone,
+		V4CidrBlocks: []string{"10.128.0.0/24"},
+	})
+
+	return f, yc.NewFromBackend(f)
+}
+
+func TestOperationLifecycle(t *testing.T) {
+	f, client := newTestCloud(t)
+	f.Polls = 2
+	ctx := context.Background()
+
+	op, err := client.ComputeInstanceCreate(ctx, &yc.ComputeInstanceConfig{
+		Name:          "web-1",
+		FolderID:      testFolderID,
+		ImageFamily:   "ubuntu",
+		ImageFolderID: testFolderID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op.Done() {
+		t.Fatal("operation done before it was polled")
+	}
+
+	meta, err := op.Metadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := meta.(*compute.CreateInstanceMetadata).InstanceId
+
+	instance, err := client.ComputeInstanceGet(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status != compute.Instance_PROVISIONING {
+		t.Fatalf("status before the operation is done = %s, want PROVISIONING", instance.Status)
+	}
+
+	if err = op.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if op.Done() {
+		t.Fatal("operation done after the first of two polls")
+	}
+	if err = op.WaitInterval(ctx, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := op.Response()
+	if err != nil {
+		t.Fatal(err)
+	}
+	instance = resp.(*compute.Instance)
+	if instance.Status != compute.Instance_RUNNING {
+		t.Fatalf("status after create = %s, want RUNNING", instance.Status)
+	}
+	if yc.GetIPv4(instance).Internal() != "10.128.0.3" {
+		t.Fatalf("internal IP = %q, want the first host address of the subnet", yc.GetIPv4(instance).Internal())
+	}
+
+	_, err = client.ComputeInstanceStart(ctx, id)
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("start of a running instance: got %v, want FailedPrecondition", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		run  func(context.Context, string) (*operation.Operation, error)
+		want compute.Instance_Status
+	}{
+		{name: "stop", run: client.ComputeInstanceStop, want: compute.Instance_STOPPED},
+		{name: "start", run: client.ComputeInstanceStart, want: compute.Instance_RUNNING},
+	} {
+		op, err := tt.run(ctx, id)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if err = op.WaitInterval(ctx, time.Millisecond); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if instance, err = client.ComputeInstanceGet(ctx, id); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if instance.Status != tt.want {
+			t.Fatalf("%s: status = %s, want %s", tt.name, instance.Status, tt.want)
+		}
+	}
+
+	op, err = client.ComputeInstanceDelete(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = op.WaitInterval(ctx, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.ComputeInstanceGet(ctx, id); status.Code(err) != codes.NotFound {
+		t.Fatalf("get after delete: got %v, want NotFound", err)
+	}
+}
+
+func TestOperationCancel(t *testing.T) {
+	f, client := newTestCloud(t)
+	f.Polls = 2
+	ctx := context.Background()
+
+	instance := f.AddInstance(&compute.Instance{FolderId: testFolderID, Name: "web-1"})
+	op, err := client.ComputeInstanceStop(ctx, instance.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = op.Cancel(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !op.Failed() || status.Code(op.Error()) != codes.Canceled {
+		t.Fatalf("canceled operation: failed %t, error %v", op.Failed(), op.Error())
+	}
+
+	// A canceled operation is not applied.
+	if instance, err = client.ComputeInstanceGet(ctx, instance.Id); err != nil {
+		t.Fatal(err)
+	}
+	if instance.Status != compute.Instance_STOPPING {
+		t.Fatalf("status after cancel = %s, want STOPPING", instance.Status)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	f, client := newTestCloud(t)
+	ctx := context.Background()
+
+	for n := 1; n <= 5; n++ {
+		labels := map[string]string{"role": "web"}
+		if n%2 == 0 {
+			labels["role"] = "db"
+		}
+		f.AddInstance(&compute.Instance{FolderId: testFolderID, Name: fmt.Sprintf("vm-%d", n), Labels: labels})
+	}
+	f.AddInstance(&compute.Instance{FolderId: "b1gother", Name: "other"})
+
+	var pages, total int
+	req := &compute.ListInstancesRequest{FolderId: testFolderID, PageSize: 2}
+	for {
+		resp, err := f.Instance().List(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		total += len(resp.Instances)
+		if len(resp.NextPageToken) == 0 {
+			break
+		}
+		req.PageToken = resp.NextPageToken
+	}
+	if pages != 3 || total != 5 {
+		t.Fatalf("got %d instances in %d pages, want 5 in 3", total, pages)
+	}
+
+	req.PageToken = "bogus"
+	if _, err := f.Instance().List(ctx, req); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid page token: got %v, want InvalidArgument", err)
+	}
+
+	for _, tt := range []struct {
+		labels map[string]string
+		want   int
+	}{
+		{labels: nil, want: 5},
+		{labels: map[string]string{"role": "web"}, want: 3},
+		{labels: map[string]string{"role": "db"}, want: 2},
+		{labels: map[string]string{"role": "cache"}, want: 0},
+	} {
+		lst, err := client.ComputeInstanceList(ctx, testFolderID, tt.labels)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lst) != tt.want {
+			t.Errorf("list with labels %v: got %d instances, want %d", tt.labels, len(lst), tt.want)
+		}
+	}
+
+	invalid := yc.Filter{Field: "name", Operator: yc.OperatorEq, Value: []string{"vm-1", "vm-2"}}
+	if _, err := client.ComputeInstanceList(ctx, testFolderID, nil, invalid); err == nil {
+		t.Error("no error listing with an = filter of two values")
+	}
+}
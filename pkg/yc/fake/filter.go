@@ -0,0 +1,212 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// condition is a single `<field> <op> <values>` term of an API filter.
+type condition struct {
+	field  string
+	op     string
+	values []string
+}
+
+// fieldFunc returns the value of a filter field of a resource.
+type fieldFunc func(field string) (string, bool)
+
+// parseFilter parses the subset of the Yandex Cloud filter syntax produced by
+// yc.Filter: terms joined by AND, operators =, !=, IN and NOT IN.
+func parseFilter(filter string) ([]condition, error) {
+	p := &filterParser{s: filter}
+
+	var out []condition
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		if len(out) > 0 {
+			if !p.keyword("AND") {
+				return nil, p.errorf("AND expected")
+			}
+			p.skipSpace()
+		}
+
+		cond, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, cond)
+	}
+
+	return out, nil
+}
+
+func matchFilter(conds []condition, get fieldFunc) bool {
+	for _, cond := range conds {
+		v, _ := get(cond.field)
+
+		var in bool
+		for _, want := range cond.values {
+			if v == want {
+				in = true
+				break
+			}
+		}
+
+		switch cond.op {
+		case "=", "IN":
+			if !in {
+				return false
+			}
+		case "!=", "NOT IN":
+			if in {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+type filterParser struct {
+	s   string
+	pos int
+}
+
+func (p *filterParser) condition() (condition, error) {
+	var cond condition
+
+	start := p.pos
+	for !p.eof() && (isIdent(rune(p.s[p.pos])) || p.s[p.pos] == '.') {
+		p.pos++
+	}
+	cond.field = p.s[start:p.pos]
+	if len(cond.field) == 0 {
+		return cond, p.errorf("field name expected")
+	}
+
+	p.skipSpace()
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "!="):
+		cond.op = "!="
+		p.pos += 2
+	case strings.HasPrefix(p.s[p.pos:], "="):
+		cond.op = "="
+		p.pos++
+	case p.keyword("IN"):
+		cond.op = "IN"
+	case p.keyword("NOT"):
+		p.skipSpace()
+		if !p.keyword("IN") {
+			return cond, p.errorf("IN expected")
+		}
+		cond.op = "NOT IN"
+	default:
+		return cond, p.errorf("operator expected")
+	}
+
+	p.skipSpace()
+	if cond.op == "IN" || cond.op == "NOT IN" {
+		if p.eof() || p.s[p.pos] != '(' {
+			return cond, p.errorf("( expected")
+		}
+		p.pos++
+		for {
+			p.skipSpace()
+			v, err := p.value()
+			if err != nil {
+				return cond, err
+			}
+			cond.values = append(cond.values, v)
+
+			p.skipSpace()
+			if p.eof() {
+				return cond, p.errorf(") expected")
+			}
+			if p.s[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			if p.s[p.pos] != ',' {
+				return cond, p.errorf(", expected")
+			}
+			p.pos++
+		}
+
+		return cond, nil
+	}
+
+	v, err := p.value()
+	if err != nil {
+		return cond, err
+	}
+	cond.values = []string{v}
+
+	return cond, nil
+}
+
+func (p *filterParser) value() (string, error) {
+	if p.eof() || p.s[p.pos] != '"' {
+		return "", p.errorf("quoted string expected")
+	}
+
+	prefix, err := strconv.QuotedPrefix(p.s[p.pos:])
+	if err != nil {
+		return "", p.errorf("invalid string")
+	}
+	p.pos += len(prefix)
+
+	return strconv.Unquote(prefix)
+}
+
+func (p *filterParser) keyword(kw string) bool {
+	end := p.pos + len(kw)
+	if end > len(p.s) || !strings.EqualFold(p.s[p.pos:end], kw) {
+		return false
+	}
+	if end < len(p.s) && isIdent(rune(p.s[end])) {
+		return false
+	}
+	p.pos = end
+
+	return true
+}
+
+func (p *filterParser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *filterParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *filterParser) errorf(msg string) error {
+	return status.Error(codes.InvalidArgument, fmt.Sprintf("invalid filter %q at %d: %s", p.s, p.pos, msg))
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || r == '-' || r == '/' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,343 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"net"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type gatewayService Cloud
+
+func (s *gatewayService) Create(_ context.Context, in *vpc.CreateGatewayRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	if in.GetSharedEgressGatewaySpec() == nil {
+		return nil, status.Error(codes.InvalidArgument, "shared_egress_gateway_spec is required")
+	}
+	for _, g := range c.gateways {
+		if len(in.Name) > 0 && g.FolderId == in.FolderId && g.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "gateway with name %s already exists", in.Name)
+		}
+	}
+
+	gateway := &vpc.Gateway{
+		Id:          c.newID("enp"),
+		FolderId:    in.FolderId,
+		CreatedAt:   timestamppb.Now(),
+		Name:        in.Name,
+		Description: in.Description,
+		Labels:      in.Labels,
+		Gateway:     &vpc.Gateway_SharedEgressGateway{SharedEgressGateway: &vpc.SharedEgressGateway{}},
+	}
+	c.gateways[gateway.Id] = gateway
+
+	return c.startOperation(
+		"Create gateway",
+		&vpc.CreateGatewayMetadata{GatewayId: gateway.Id},
+		func() (proto.Message, error) {
+			return proto.Clone(gateway), nil
+		},
+	)
+}
+
+func (s *gatewayService) Delete(_ context.Context, in *vpc.DeleteGatewayRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	gateway, ok := c.gateways[in.GatewayId]
+	if !ok {
+		return nil, notFound("gateway", in.GatewayId)
+	}
+	for _, table := range c.routeTables {
+		for _, route := range table.StaticRoutes {
+			if route.GetGatewayId() == gateway.Id {
+				return nil, status.Errorf(codes.FailedPrecondition, "gateway %s is used by route table %s", gateway.Id, table.Id)
+			}
+		}
+	}
+
+	return c.startOperation(
+		"Delete gateway",
+		&vpc.DeleteGatewayMetadata{GatewayId: gateway.Id},
+		func() (proto.Message, error) {
+			delete(c.gateways, gateway.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *gatewayService) Get(_ context.Context, in *vpc.GetGatewayRequest, _ ...grpc.CallOption) (*vpc.Gateway, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	gateway, ok := c.gateways[in.GatewayId]
+	if !ok {
+		return nil, notFound("gateway", in.GatewayId)
+	}
+
+	return proto.Clone(gateway).(*vpc.Gateway), nil
+}
+
+func (s *gatewayService) List(_ context.Context, in *vpc.ListGatewaysRequest, _ ...grpc.CallOption) (*vpc.ListGatewaysResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*vpc.Gateway
+	for _, id := range sortedKeys(c.gateways) {
+		gateway := c.gateways[id]
+		if gateway.FolderId == in.FolderId && matchFilter(conds, gatewayField(gateway)) {
+			items = append(items, proto.Clone(gateway).(*vpc.Gateway))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vpc.ListGatewaysResponse{Gateways: items, NextPageToken: next}, nil
+}
+
+func gatewayField(g *vpc.Gateway) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return g.Id, true
+		case "name":
+			return g.Name, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := g.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
+
+type routeTableService Cloud
+
+func (s *routeTableService) Create(_ context.Context, in *vpc.CreateRouteTableRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	if _, ok := c.networks[in.NetworkId]; !ok {
+		return nil, notFound("network", in.NetworkId)
+	}
+	for _, t := range c.routeTables {
+		if len(in.Name) > 0 && t.FolderId == in.FolderId && t.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "route table with name %s already exists", in.Name)
+		}
+	}
+	if err := c.checkStaticRoutes(in.StaticRoutes); err != nil {
+		return nil, err
+	}
+
+	table := &vpc.RouteTable{
+		Id:           c.newID("enp"),
+		FolderId:     in.FolderId,
+		CreatedAt:    timestamppb.Now(),
+		Name:         in.Name,
+		Description:  in.Description,
+		Labels:       in.Labels,
+		NetworkId:    in.NetworkId,
+		StaticRoutes: in.StaticRoutes,
+	}
+	c.routeTables[table.Id] = table
+
+	return c.startOperation(
+		"Create route table",
+		&vpc.CreateRouteTableMetadata{RouteTableId: table.Id},
+		func() (proto.Message, error) {
+			return proto.Clone(table), nil
+		},
+	)
+}
+
+func (s *routeTableService) Delete(_ context.Context, in *vpc.DeleteRouteTableRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table, ok := c.routeTables[in.RouteTableId]
+	if !ok {
+		return nil, notFound("route table", in.RouteTableId)
+	}
+	for _, subnet := range c.subnets {
+		if subnet.RouteTableId == table.Id {
+			return nil, status.Errorf(codes.FailedPrecondition, "route table %s is used by subnet %s", table.Id, subnet.Id)
+		}
+	}
+
+	return c.startOperation(
+		"Delete route table",
+		&vpc.DeleteRouteTableMetadata{RouteTableId: table.Id},
+		func() (proto.Message, error) {
+			delete(c.routeTables, table.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *routeTableService) Get(_ context.Context, in *vpc.GetRouteTableRequest, _ ...grpc.CallOption) (*vpc.RouteTable, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table, ok := c.routeTables[in.RouteTableId]
+	if !ok {
+		return nil, notFound("route table", in.RouteTableId)
+	}
+
+	return proto.Clone(table).(*vpc.RouteTable), nil
+}
+
+func (s *routeTableService) List(_ context.Context, in *vpc.ListRouteTablesRequest, _ ...grpc.CallOption) (*vpc.ListRouteTablesResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*vpc.RouteTable
+	for _, id := range sortedKeys(c.routeTables) {
+		table := c.routeTables[id]
+		if table.FolderId == in.FolderId && matchFilter(conds, routeTableField(table)) {
+			items = append(items, proto.Clone(table).(*vpc.RouteTable))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vpc.ListRouteTablesResponse{RouteTables: items, NextPageToken: next}, nil
+}
+
+func (s *routeTableService) Update(_ context.Context, in *vpc.UpdateRouteTableRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	table, ok := c.routeTables[in.RouteTableId]
+	if !ok {
+		return nil, notFound("route table", in.RouteTableId)
+	}
+
+	paths := in.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+
+	updated := proto.Clone(table).(*vpc.RouteTable)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			updated.Name = in.Name
+		case "description":
+			updated.Description = in.Description
+		case "labels":
+			updated.Labels = in.Labels
+		case "static_routes":
+			if err := c.checkStaticRoutes(in.StaticRoutes); err != nil {
+				return nil, err
+			}
+			updated.StaticRoutes = in.StaticRoutes
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask path %s", path)
+		}
+	}
+
+	return c.startOperation(
+		"Update route table",
+		&vpc.UpdateRouteTableMetadata{RouteTableId: table.Id},
+		func() (proto.Message, error) {
+			proto.Reset(table)
+			proto.Merge(table, updated)
+			return proto.Clone(table), nil
+		},
+	)
+}
+
+// checkStaticRoutes validates the destinations of the routes and that their gateways exist.
+// Must be called with c.mu held.
+func (c *Cloud) checkStaticRoutes(routes []*vpc.StaticRoute) error {
+	for _, route := range routes {
+		if _, _, err := net.ParseCIDR(route.GetDestinationPrefix()); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid destination prefix %q", route.GetDestinationPrefix())
+		}
+		if id := route.GetGatewayId(); len(id) > 0 {
+			if _, ok := c.gateways[id]; !ok {
+				return notFound("gateway", id)
+			}
+		} else if net.ParseIP(route.GetNextHopAddress()) == nil {
+			return status.Errorf(codes.InvalidArgument, "invalid next hop address %q", route.GetNextHopAddress())
+		}
+	}
+
+	return nil
+}
+
+func routeTableField(t *vpc.RouteTable) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return t.Id, true
+		case "name":
+			return t.Name, true
+		case "network_id", "networkId":
+			return t.NetworkId, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := t.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+type serviceAccountService Cloud
+
+func (s *serviceAccountService) List(_ context.Context, in *iam.ListServiceAccountsRequest, _ ...grpc.CallOption) (*iam.ListServiceAccountsResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*iam.ServiceAccount
+	for _, id := range sortedKeys(c.serviceAccounts) {
+		sa := c.serviceAccounts[id]
+		field := func(field string) (string, bool) {
+			switch field {
+			case "id":
+				return sa.Id, true
+			case "name":
+				return sa.Name, true
+			}
+			return "", false
+		}
+		if sa.FolderId == in.FolderId && matchFilter(conds, field) {
+			items = append(items, proto.Clone(sa).(*iam.ServiceAccount))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &iam.ListServiceAccountsResponse{ServiceAccounts: items, NextPageToken: next}, nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type imageService Cloud
+
+func (s *imageService) Create(_ context.Context, in *compute.CreateImageRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	for _, i := range c.images {
+		if len(in.Name) > 0 && i.FolderId == in.FolderId && i.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "image with name %s already exists", in.Name)
+		}
+	}
+
+	if len(in.GetDiskId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "only disk sources are supported")
+	}
+	disk, ok := c.disks[in.GetDiskId()]
+	if !ok {
+		return nil, notFound("disk", in.GetDiskId())
+	}
+
+	image := &compute.Image{
+		Id:          c.newID("fd8"),
+		FolderId:    in.FolderId,
+		CreatedAt:   timestamppb.Now(),
+		Name:        in.Name,
+		Description: in.Description,
+		Labels:      in.Labels,
+		Family:      in.Family,
+		StorageSize: disk.Size,
+		MinDiskSize: max(in.MinDiskSize, disk.Size),
+		Status:      compute.Image_CREATING,
+		Os:          in.Os,
+	}
+	c.images[image.Id] = image
+
+	return c.startOperation(
+		"Create image",
+		&compute.CreateImageMetadata{ImageId: image.Id},
+		func() (proto.Message, error) {
+			image.Status = compute.Image_READY
+			return proto.Clone(image), nil
+		},
+	)
+}
+
+func (s *imageService) Get(_ context.Context, in *compute.GetImageRequest, _ ...grpc.CallOption) (*compute.Image, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	image, ok := c.images[in.ImageId]
+	if !ok {
+		return nil, notFound("image", in.ImageId)
+	}
+
+	return proto.Clone(image).(*compute.Image), nil
+}
+
+func (s *imageService) GetLatestByFamily(
+	_ context.Context,
+	in *compute.GetImageLatestByFamilyRequest,
+	_ ...grpc.CallOption,
+) (*compute.Image, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var latest *compute.Image
+	for _, image := range c.images {
+		if image.FolderId != in.FolderId || image.Family != in.Family || image.Status != compute.Image_READY {
+			continue
+		}
+		if latest == nil || image.CreatedAt.AsTime().After(latest.CreatedAt.AsTime()) {
+			latest = image
+		}
+	}
+	if latest == nil {
+		return nil, status.Errorf(codes.NotFound, "image family %s not found in folder %s", in.Family, in.FolderId)
+	}
+
+	return proto.Clone(latest).(*compute.Image), nil
+}
+
+func (s *imageService) List(_ context.Context, in *compute.ListImagesRequest, _ ...grpc.CallOption) (*compute.ListImagesResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*compute.Image
+	for _, id := range sortedKeys(c.images) {
+		image := c.images[id]
+		if image.FolderId == in.FolderId && matchFilter(conds, imageField(image)) {
+			items = append(items, proto.Clone(image).(*compute.Image))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compute.ListImagesResponse{Images: items, NextPageToken: next}, nil
+}
+
+func imageField(i *compute.Image) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return i.Id, true
+		case "name":
+			return i.Name, true
+		case "family":
+			return i.Family, true
+		case "status":
+			return i.Status.String(), true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := i.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
@@ -0,0 +1,363 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// instanceGroupService manages the instances of a group without creating compute instances:
+// the group gets managed instances up to the target size of its scale policy as soon as an operation is done.
+type instanceGroupService Cloud
+
+func (s *instanceGroupService) Create(_ context.Context, in *instancegroup.CreateInstanceGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	if len(in.ServiceAccountId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "service_account_id is required")
+	}
+	if in.InstanceTemplate == nil {
+		return nil, status.Error(codes.InvalidArgument, "instance_template is required")
+	}
+	if len(in.GetAllocationPolicy().GetZones()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "allocation_policy.zones is required")
+	}
+	if err := checkScalePolicy(in.ScalePolicy); err != nil {
+		return nil, err
+	}
+	if err := checkDeployPolicy(in.DeployPolicy); err != nil {
+		return nil, err
+	}
+	for _, nic := range in.InstanceTemplate.NetworkInterfaceSpecs {
+		if _, ok := c.networks[nic.NetworkId]; !ok {
+			return nil, notFound("network", nic.NetworkId)
+		}
+		for _, id := range nic.SubnetIds {
+			if _, ok := c.subnets[id]; !ok {
+				return nil, notFound("subnet", id)
+			}
+		}
+	}
+	for _, g := range c.instanceGroups {
+		if len(in.Name) > 0 && g.FolderId == in.FolderId && g.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "instance group with name %s already exists", in.Name)
+		}
+	}
+
+	group := &instancegroup.InstanceGroup{
+		Id:               c.newID("cl1"),
+		FolderId:         in.FolderId,
+		CreatedAt:        timestamppb.Now(),
+		Name:             in.Name,
+		Description:      in.Description,
+		Labels:           in.Labels,
+		InstanceTemplate: in.InstanceTemplate,
+		ScalePolicy:      in.ScalePolicy,
+		DeployPolicy:     in.DeployPolicy,
+		AllocationPolicy: in.AllocationPolicy,
+		ServiceAccountId: in.ServiceAccountId,
+		Status:           instancegroup.InstanceGroup_STARTING,
+	}
+	c.instanceGroups[group.Id] = group
+
+	return c.startOperation(
+		"Create instance group",
+		&instancegroup.CreateInstanceGroupMetadata{InstanceGroupId: group.Id},
+		func() (proto.Message, error) {
+			group.Status = instancegroup.InstanceGroup_ACTIVE
+			c.resizeGroup(group)
+			return proto.Clone(group), nil
+		},
+	)
+}
+
+func (s *instanceGroupService) Delete(_ context.Context, in *instancegroup.DeleteInstanceGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.instanceGroups[in.InstanceGroupId]
+	if !ok {
+		return nil, notFound("instance group", in.InstanceGroupId)
+	}
+	group.Status = instancegroup.InstanceGroup_DELETING
+
+	return c.startOperation(
+		"Delete instance group",
+		&instancegroup.DeleteInstanceGroupMetadata{InstanceGroupId: group.Id},
+		func() (proto.Message, error) {
+			delete(c.instanceGroups, group.Id)
+			delete(c.groupInstances, group.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *instanceGroupService) Get(_ context.Context, in *instancegroup.GetInstanceGroupRequest, _ ...grpc.CallOption) (*instancegroup.InstanceGroup, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.instanceGroups[in.InstanceGroupId]
+	if !ok {
+		return nil, notFound("instance group", in.InstanceGroupId)
+	}
+
+	return proto.Clone(group).(*instancegroup.InstanceGroup), nil
+}
+
+func (s *instanceGroupService) List(
+	_ context.Context,
+	in *instancegroup.ListInstanceGroupsRequest,
+	_ ...grpc.CallOption,
+) (*instancegroup.ListInstanceGroupsResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*instancegroup.InstanceGroup
+	for _, id := range sortedKeys(c.instanceGroups) {
+		group := c.instanceGroups[id]
+		if group.FolderId == in.FolderId && matchFilter(conds, instanceGroupField(group)) {
+			items = append(items, proto.Clone(group).(*instancegroup.InstanceGroup))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instancegroup.ListInstanceGroupsResponse{InstanceGroups: items, NextPageToken: next}, nil
+}
+
+func (s *instanceGroupService) ListInstances(
+	_ context.Context,
+	in *instancegroup.ListInstanceGroupInstancesRequest,
+	_ ...grpc.CallOption,
+) (*instancegroup.ListInstanceGroupInstancesResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.instanceGroups[in.InstanceGroupId]; !ok {
+		return nil, notFound("instance group", in.InstanceGroupId)
+	}
+
+	var items []*instancegroup.ManagedInstance
+	for _, mi := range c.groupInstances[in.InstanceGroupId] {
+		items = append(items, proto.Clone(mi).(*instancegroup.ManagedInstance))
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instancegroup.ListInstanceGroupInstancesResponse{Instances: items, NextPageToken: next}, nil
+}
+
+func (s *instanceGroupService) Update(_ context.Context, in *instancegroup.UpdateInstanceGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.instanceGroups[in.InstanceGroupId]
+	if !ok {
+		return nil, notFound("instance group", in.InstanceGroupId)
+	}
+
+	paths := in.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+
+	updated := proto.Clone(group).(*instancegroup.InstanceGroup)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			updated.Name = in.Name
+		case "description":
+			updated.Description = in.Description
+		case "labels":
+			updated.Labels = in.Labels
+		case "scale_policy":
+			if err := checkScalePolicy(in.ScalePolicy); err != nil {
+				return nil, err
+			}
+			updated.ScalePolicy = in.ScalePolicy
+		case "deploy_policy":
+			if err := checkDeployPolicy(in.DeployPolicy); err != nil {
+				return nil, err
+			}
+			updated.DeployPolicy = in.DeployPolicy
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask path %s", path)
+		}
+	}
+
+	return c.startOperation(
+		"Update instance group",
+		&instancegroup.UpdateInstanceGroupMetadata{InstanceGroupId: group.Id},
+		func() (proto.Message, error) {
+			proto.Reset(group)
+			proto.Merge(group, updated)
+			c.resizeGroup(group)
+			return proto.Clone(group), nil
+		},
+	)
+}
+
+func checkScalePolicy(p *instancegroup.ScalePolicy) error {
+	switch {
+	case p.GetFixedScale() != nil:
+		return nil
+	case p.GetAutoScale() != nil:
+		auto := p.GetAutoScale()
+		if auto.MaxSize <= 0 {
+			return status.Error(codes.InvalidArgument, "scale_policy.auto_scale.max_size must be positive")
+		}
+		if auto.MeasurementDuration == nil {
+			return status.Error(codes.InvalidArgument, "scale_policy.auto_scale.measurement_duration is required")
+		}
+		return nil
+	}
+
+	return status.Error(codes.InvalidArgument, "scale_policy is required")
+}
+
+func checkDeployPolicy(p *instancegroup.DeployPolicy) error {
+	if p == nil {
+		return status.Error(codes.InvalidArgument, "deploy_policy is required")
+	}
+	if p.MaxUnavailable == 0 && p.MaxExpansion == 0 {
+		return status.Error(codes.InvalidArgument, "deploy_policy: max_unavailable and max_expansion can't both be zero")
+	}
+
+	return nil
+}
+
+// targetSize returns the number of instances the scale policy asks for.
+// An auto scale group is kept at its initial size, the fake has no load to scale on.
+func targetSize(group *instancegroup.InstanceGroup) int64 {
+	if fixed := group.GetScalePolicy().GetFixedScale(); fixed != nil {
+		return fixed.Size
+	}
+
+	auto := group.GetScalePolicy().GetAutoScale()
+	size := max(auto.InitialSize, auto.MinZoneSize*int64(len(group.GetAllocationPolicy().GetZones())))
+
+	return min(size, auto.MaxSize)
+}
+
+// resizeGroup adds or removes the managed instances of the group to meet its target size.
+// New instances are spread over the zones of the group. Must be called with c.mu held.
+func (c *Cloud) resizeGroup(group *instancegroup.InstanceGroup) {
+	size := targetSize(group)
+	zones := group.GetAllocationPolicy().GetZones()
+	lst := c.groupInstances[group.Id]
+
+	for n := int64(len(lst)); n < size; n++ {
+		name := group.InstanceTemplate.GetName()
+		if len(name) == 0 {
+			name = group.Name + "-{instance.index}"
+		}
+		name = strings.ReplaceAll(name, "{instance.index}", strconv.FormatInt(n+1, 10))
+
+		mi := &instancegroup.ManagedInstance{
+			Id:              c.newID("cl1"),
+			InstanceId:      c.newID("fhm"),
+			Name:            name,
+			Fqdn:            name + ".ru-central1.internal",
+			ZoneId:          zones[n%int64(len(zones))].ZoneId,
+			Status:          instancegroup.ManagedInstance_RUNNING_ACTUAL,
+			StatusChangedAt: timestamppb.Now(),
+		}
+		for idx, spec := range group.InstanceTemplate.NetworkInterfaceSpecs {
+			mi.NetworkInterfaces = append(mi.NetworkInterfaces, c.managedInterface(idx, mi.ZoneId, spec))
+		}
+		lst = append(lst, mi)
+	}
+	if int64(len(lst)) > size {
+		lst = lst[:size]
+	}
+	c.groupInstances[group.Id] = lst
+
+	group.ManagedInstancesState = &instancegroup.ManagedInstancesState{
+		TargetSize:         size,
+		RunningActualCount: int64(len(lst)),
+	}
+}
+
+// managedInterface returns the interface of a managed instance in the subnet of its zone.
+// The internal address is left empty if the spec has no subnet in the zone. Must be called with c.mu held.
+func (c *Cloud) managedInterface(idx int, zone string, spec *instancegroup.NetworkInterfaceSpec) *instancegroup.NetworkInterface {
+	nic := &instancegroup.NetworkInterface{Index: strconv.Itoa(idx), PrimaryV4Address: &instancegroup.PrimaryAddress{}}
+	for _, id := range spec.SubnetIds {
+		subnet, ok := c.subnets[id]
+		if !ok || subnet.ZoneId != zone {
+			continue
+		}
+		nic.SubnetId = subnet.Id
+		nic.PrimaryV4Address.Address, _ = c.allocateAddress(subnet.Id, subnet.V4CidrBlocks)
+		break
+	}
+	if spec.GetPrimaryV4AddressSpec().GetOneToOneNatSpec() != nil {
+		public, _ := c.externalAddress("")
+		nic.PrimaryV4Address.OneToOneNat = &instancegroup.OneToOneNat{Address: public, IpVersion: instancegroup.IpVersion_IPV4}
+	}
+
+	return nic
+}
+
+func instanceGroupField(g *instancegroup.InstanceGroup) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return g.Id, true
+		case "name":
+			return g.Name, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := g.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
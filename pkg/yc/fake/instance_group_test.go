@@ -0,0 +1,76 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
+)
+
+func TestInstanceGroupCreate(t *testing.T) {
+	f, client := newTestCloud(t)
+	f.AddServiceAccount(&iam.ServiceAccount{FolderId: testFolderID, Name: "ig-sa"})
+	ctx := context.Background()
+
+	icfg := &yc.ComputeInstanceConfig{
+		Name:           "web",
+		FolderID:       testFolderID,
+		ImageFamily:    "ubuntu",
+		ImageFolderID:  testFolderID,
+		ServiceAccount: "ig-sa",
+	}
+	op, err := client.ComputeInstanceGroupCreate(ctx, &yc.InstanceGroupConfig{
+		Instance:     icfg,
+		Size:         2,
+		MaxExpansion: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = op.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The defaults are not written back to the config of the caller.
+	if len(icfg.Zone) > 0 || len(icfg.PlatformID) > 0 {
+		t.Errorf("config changed to zone %q, platform %q", icfg.Zone, icfg.PlatformID)
+	}
+
+	group, err := client.ComputeInstanceGroupResolve(ctx, testFolderID, "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zones := group.GetAllocationPolicy().GetZones()
+	if len(zones) != 1 || zones[0].ZoneId != yc.DefaultZone {
+		t.Errorf("zones %v, want %s", zones, yc.DefaultZone)
+	}
+	if platform := group.GetInstanceTemplate().GetPlatformId(); platform != yc.DefaultPlatformID {
+		t.Errorf("platform %q, want %s", platform, yc.DefaultPlatformID)
+	}
+
+	instances, err := client.ComputeInstanceGroupInstances(ctx, group.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 2 {
+		t.Errorf("%d instances, want 2", len(instances))
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type pendingOperation struct {
+	proto *operation.Operation
+	polls int
+	// complete applies the operation to the cloud state and returns its response.
+	// It is called with Cloud.mu held.
+	complete func() (proto.Message, error)
+}
+
+type operationService Cloud
+
+func (s *operationService) Get(_ context.Context, in *operation.GetOperationRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op, ok := c.operations[in.OperationId]
+	if !ok {
+		return nil, notFound("operation", in.OperationId)
+	}
+
+	if !op.proto.Done {
+		op.polls--
+		if op.polls <= 0 {
+			c.finish(op)
+		}
+	}
+
+	return proto.Clone(op.proto).(*operation.Operation), nil
+}
+
+func (s *operationService) Cancel(_ context.Context, in *operation.CancelOperationRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	op, ok := c.operations[in.OperationId]
+	if !ok {
+		return nil, notFound("operation", in.OperationId)
+	}
+
+	if !op.proto.Done {
+		op.proto.Done = true
+		op.proto.ModifiedAt = timestamppb.Now()
+		op.proto.Result = &operation.Operation_Error{
+			Error: status.New(codes.Canceled, "operation canceled").Proto(),
+		}
+	}
+
+	return proto.Clone(op.proto).(*operation.Operation), nil
+}
+
+// startOperation registers a new operation. The complete callback runs once the
+// operation has been polled Cloud.Polls times. Must be called with c.mu held.
+func (c *Cloud) startOperation(
+	description string,
+	metadata proto.Message,
+	complete func() (proto.Message, error),
+) (*operation.Operation, error) {
+	meta, err := anypb.New(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timestamppb.Now()
+	op := &pendingOperation{
+		proto: &operation.Operation{
+			Id:          c.newID("fop"),
+			Description: description,
+			CreatedAt:   now,
+			CreatedBy:   "fake",
+			ModifiedAt:  now,
+			Metadata:    meta,
+		},
+		polls:    c.Polls,
+		complete: complete,
+	}
+	c.operations[op.proto.Id] = op
+
+	if op.polls <= 0 {
+		c.finish(op)
+	}
+
+	return proto.Clone(op.proto).(*operation.Operation), nil
+}
+
+func (c *Cloud) finish(op *pendingOperation) {
+	op.proto.Done = true
+	op.proto.ModifiedAt = timestamppb.Now()
+
+	resp, err := op.complete()
+	if err == nil {
+		var a *anypb.Any
+		if a, err = anypb.New(resp); err == nil {
+			op.proto.Result = &operation.Operation_Response{Response: a}
+			return
+		}
+	}
+
+	op.proto.Result = &operation.Operation_Error{Error: status.Convert(err).Proto()}
+}
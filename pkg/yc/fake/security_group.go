@@ -0,0 +1,257 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"net"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type securityGroupService Cloud
+
+func (s *securityGroupService) Create(_ context.Context, in *vpc.CreateSecurityGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	if _, ok := c.networks[in.NetworkId]; !ok {
+		return nil, notFound("network", in.NetworkId)
+	}
+	for _, g := range c.securityGroups {
+		if len(in.Name) > 0 && g.FolderId == in.FolderId && g.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "security group with name %s already exists", in.Name)
+		}
+	}
+
+	rules, err := c.securityGroupRules(in.RuleSpecs)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &vpc.SecurityGroup{
+		Id:          c.newID("enp"),
+		FolderId:    in.FolderId,
+		CreatedAt:   timestamppb.Now(),
+		Name:        in.Name,
+		Description: in.Description,
+		Labels:      in.Labels,
+		NetworkId:   in.NetworkId,
+		Status:      vpc.SecurityGroup_ACTIVE,
+		Rules:       rules,
+	}
+	c.securityGroups[group.Id] = group
+
+	return c.startOperation(
+		"Create security group",
+		&vpc.CreateSecurityGroupMetadata{SecurityGroupId: group.Id},
+		func() (proto.Message, error) {
+			return proto.Clone(group), nil
+		},
+	)
+}
+
+func (s *securityGroupService) Delete(_ context.Context, in *vpc.DeleteSecurityGroupRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.securityGroups[in.SecurityGroupId]
+	if !ok {
+		return nil, notFound("security group", in.SecurityGroupId)
+	}
+	if group.DefaultForNetwork {
+		return nil, status.Errorf(codes.FailedPrecondition, "security group %s is the default one of network %s", group.Id, group.NetworkId)
+	}
+	for _, instance := range c.instances {
+		for _, nic := range instance.NetworkInterfaces {
+			for _, id := range nic.SecurityGroupIds {
+				if id == group.Id {
+					return nil, status.Errorf(codes.FailedPrecondition, "security group %s is used by instance %s", group.Id, instance.Id)
+				}
+			}
+		}
+	}
+
+	return c.startOperation(
+		"Delete security group",
+		&vpc.DeleteSecurityGroupMetadata{SecurityGroupId: group.Id},
+		func() (proto.Message, error) {
+			delete(c.securityGroups, group.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *securityGroupService) Get(_ context.Context, in *vpc.GetSecurityGroupRequest, _ ...grpc.CallOption) (*vpc.SecurityGroup, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.securityGroups[in.SecurityGroupId]
+	if !ok {
+		return nil, notFound("security group", in.SecurityGroupId)
+	}
+
+	return proto.Clone(group).(*vpc.SecurityGroup), nil
+}
+
+func (s *securityGroupService) List(_ context.Context, in *vpc.ListSecurityGroupsRequest, _ ...grpc.CallOption) (*vpc.ListSecurityGroupsResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*vpc.SecurityGroup
+	for _, id := range sortedKeys(c.securityGroups) {
+		group := c.securityGroups[id]
+		if group.FolderId == in.FolderId && matchFilter(conds, securityGroupField(group)) {
+			items = append(items, proto.Clone(group).(*vpc.SecurityGroup))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vpc.ListSecurityGroupsResponse{SecurityGroups: items, NextPageToken: next}, nil
+}
+
+func (s *securityGroupService) UpdateRules(_ context.Context, in *vpc.UpdateSecurityGroupRulesRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	group, ok := c.securityGroups[in.SecurityGroupId]
+	if !ok {
+		return nil, notFound("security group", in.SecurityGroupId)
+	}
+
+	deleted := make(map[string]bool)
+	for _, id := range in.DeletionRuleIds {
+		deleted[id] = true
+	}
+	var rules []*vpc.SecurityGroupRule
+	for _, rule := range group.Rules {
+		if deleted[rule.Id] {
+			delete(deleted, rule.Id)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	for id := range deleted {
+		return nil, notFound("security group rule", id)
+	}
+
+	added, err := c.securityGroupRules(in.AdditionRuleSpecs)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(added))
+	for _, rule := range added {
+		ids = append(ids, rule.Id)
+	}
+	rules = append(rules, added...)
+
+	return c.startOperation(
+		"Update security group rules",
+		&vpc.UpdateSecurityGroupMetadata{SecurityGroupId: group.Id, AddedRuleIds: ids},
+		func() (proto.Message, error) {
+			group.Rules = rules
+			return proto.Clone(group), nil
+		},
+	)
+}
+
+// securityGroupRules validates rule specs and converts them to rules with new IDs.
+// Must be called with c.mu held.
+func (c *Cloud) securityGroupRules(specs []*vpc.SecurityGroupRuleSpec) ([]*vpc.SecurityGroupRule, error) {
+	out := make([]*vpc.SecurityGroupRule, 0, len(specs))
+	for _, spec := range specs {
+		if spec.Direction == vpc.SecurityGroupRule_DIRECTION_UNSPECIFIED {
+			return nil, status.Error(codes.InvalidArgument, "rule direction is required")
+		}
+		if p := spec.Ports; p != nil && (p.FromPort > p.ToPort || p.ToPort > 65535) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid port range %d-%d", p.FromPort, p.ToPort)
+		}
+
+		rule := &vpc.SecurityGroupRule{
+			Id:             c.newID("enp"),
+			Description:    spec.Description,
+			Labels:         spec.Labels,
+			Direction:      spec.Direction,
+			Ports:          spec.Ports,
+			ProtocolName:   spec.GetProtocolName(),
+			ProtocolNumber: spec.GetProtocolNumber(),
+		}
+
+		switch target := spec.Target.(type) {
+		case *vpc.SecurityGroupRuleSpec_CidrBlocks:
+			for _, cidr := range target.CidrBlocks.GetV4CidrBlocks() {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					return nil, status.Errorf(codes.InvalidArgument, "invalid CIDR block %q", cidr)
+				}
+			}
+			rule.Target = &vpc.SecurityGroupRule_CidrBlocks{CidrBlocks: target.CidrBlocks}
+		case *vpc.SecurityGroupRuleSpec_SecurityGroupId:
+			rule.Target = &vpc.SecurityGroupRule_SecurityGroupId{SecurityGroupId: target.SecurityGroupId}
+		case *vpc.SecurityGroupRuleSpec_PredefinedTarget:
+			rule.Target = &vpc.SecurityGroupRule_PredefinedTarget{PredefinedTarget: target.PredefinedTarget}
+		default:
+			return nil, status.Error(codes.InvalidArgument, "rule target is required")
+		}
+
+		out = append(out, rule)
+	}
+
+	return out, nil
+}
+
+func securityGroupField(g *vpc.SecurityGroup) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return g.Id, true
+		case "name":
+			return g.Name, true
+		case "network_id", "networkId":
+			return g.NetworkId, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := g.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
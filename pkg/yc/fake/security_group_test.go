@@ -0,0 +1,156 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+)
+
+func TestSecurityGroupEnsurePreset(t *testing.T) {
+	_, client := newTestCloud(t)
+	ctx := context.Background()
+
+	network, err := client.VPCNetworkResolve(ctx, testFolderID, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := client.VPCSecurityGroupEnsurePreset(ctx, &yc.PresetSecurityGroupConfig{
+		FolderID:  testFolderID,
+		NetworkID: network.Id,
+		Preset:    "k8s-control-plane",
+		Cluster:   "alpha",
+		Prune:     false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	preset := yc.SecurityGroupPresets["k8s-control-plane"]
+	if len(group.Rules) != len(preset) {
+		t.Fatalf("got %d rules, want %d", len(group.Rules), len(preset))
+	}
+
+	// The rules read back equal the preset, so there is nothing to update.
+	op, err := client.VPCSecurityGroupAddRules(ctx, group, preset)
+	if err != nil || op != nil {
+		t.Fatalf("add the preset rules again: got operation %v, error %v", op, err)
+	}
+	op, err = client.VPCSecurityGroupReconcile(ctx, group, preset)
+	if err != nil || op != nil {
+		t.Fatalf("reconcile with the preset: got operation %v, error %v", op, err)
+	}
+
+	custom := yc.SecurityGroupRule{
+		Direction: vpc.SecurityGroupRule_INGRESS,
+		Protocol:  "tcp",
+		FromPort:  8080,
+		ToPort:    8080,
+		CIDRs:     []string{"10.0.0.0/8"},
+	}
+	op, err = client.VPCSecurityGroupAddRules(ctx, group, []yc.SecurityGroupRule{custom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = op.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ensured, err := client.VPCSecurityGroupEnsurePreset(ctx, &yc.PresetSecurityGroupConfig{
+		FolderID:  testFolderID,
+		NetworkID: network.Id,
+		Preset:    "k8s-control-plane",
+		Cluster:   "alpha",
+		Prune:     false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ensured.Id != group.Id || len(ensured.Rules) != len(preset)+1 {
+		t.Fatalf("ensure: got group %s with %d rules, want %s with %d", ensured.Id, len(ensured.Rules), group.Id, len(preset)+1)
+	}
+
+	pruned, err := client.VPCSecurityGroupEnsurePreset(ctx, &yc.PresetSecurityGroupConfig{
+		FolderID:  testFolderID,
+		NetworkID: network.Id,
+		Preset:    "k8s-control-plane",
+		Cluster:   "alpha",
+		Prune:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned.Rules) != len(preset) {
+		t.Fatalf("ensure with prune: got %d rules, want %d", len(pruned.Rules), len(preset))
+	}
+}
+
+func TestSecurityGroupPresetPerCluster(t *testing.T) {
+	_, client := newTestCloud(t)
+	ctx := context.Background()
+
+	network, err := client.VPCNetworkResolve(ctx, testFolderID, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensure := func(preset, cluster string) *vpc.SecurityGroup {
+		t.Helper()
+		group, err := client.VPCSecurityGroupEnsurePreset(ctx, &yc.PresetSecurityGroupConfig{
+			FolderID:  testFolderID,
+			NetworkID: network.Id,
+			Preset:    preset,
+			Cluster:   cluster,
+		})
+		if err != nil {
+			t.Fatalf("%s of %q: %v", preset, cluster, err)
+		}
+		return group
+	}
+
+	alpha, beta := ensure("intra-cluster", "alpha"), ensure("intra-cluster", "beta")
+	if alpha.Id == beta.Id {
+		t.Fatal("clusters alpha and beta share the intra-cluster group")
+	}
+	if alpha.Labels[common.LabelClusterNameKey] != "alpha" || alpha.Name != "ks-alpha-intra-cluster" {
+		t.Errorf("unexpected group %s with labels %v", alpha.Name, alpha.Labels)
+	}
+	if again := ensure("intra-cluster", "alpha"); again.Id != alpha.Id {
+		t.Errorf("second ensure of alpha: got group %s, want %s", again.Id, alpha.Id)
+	}
+
+	ssh := ensure("ssh-only", "alpha")
+	if other := ensure("ssh-only", "beta"); other.Id != ssh.Id {
+		t.Error("ssh-only has a group per cluster")
+	}
+	if _, ok := ssh.Labels[common.LabelClusterNameKey]; ok {
+		t.Errorf("ssh-only group has labels %v", ssh.Labels)
+	}
+
+	_, err = client.VPCSecurityGroupEnsurePreset(ctx, &yc.PresetSecurityGroupConfig{
+		FolderID:  testFolderID,
+		NetworkID: network.Id,
+		Preset:    "k8s-worker",
+	})
+	if err == nil {
+		t.Error("no error for k8s-worker without a cluster")
+	}
+}
@@ -0,0 +1,626 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"net"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type networkService Cloud
+
+func (s *networkService) Create(_ context.Context, in *vpc.CreateNetworkRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	for _, n := range c.networks {
+		if len(in.Name) > 0 && n.FolderId == in.FolderId && n.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "network with name %s already exists", in.Name)
+		}
+	}
+
+	network := &vpc.Network{
+		Id:          c.newID("enp"),
+		FolderId:    in.FolderId,
+		CreatedAt:   timestamppb.Now(),
+		Name:        in.Name,
+		Description: in.Description,
+		Labels:      in.Labels,
+	}
+	c.networks[network.Id] = network
+
+	return c.startOperation(
+		"Create network",
+		&vpc.CreateNetworkMetadata{NetworkId: network.Id},
+		func() (proto.Message, error) {
+			return proto.Clone(network), nil
+		},
+	)
+}
+
+func (s *networkService) Delete(_ context.Context, in *vpc.DeleteNetworkRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	network, ok := c.networks[in.NetworkId]
+	if !ok {
+		return nil, notFound("network", in.NetworkId)
+	}
+	for _, subnet := range c.subnets {
+		if subnet.NetworkId == network.Id {
+			return nil, status.Errorf(codes.FailedPrecondition, "network %s has subnet %s", network.Id, subnet.Id)
+		}
+	}
+
+	return c.startOperation(
+		"Delete network",
+		&vpc.DeleteNetworkMetadata{NetworkId: network.Id},
+		func() (proto.Message, error) {
+			delete(c.networks, network.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *networkService) Get(_ context.Context, in *vpc.GetNetworkRequest, _ ...grpc.CallOption) (*vpc.Network, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	network, ok := c.networks[in.NetworkId]
+	if !ok {
+		return nil, notFound("network", in.NetworkId)
+	}
+
+	return proto.Clone(network).(*vpc.Network), nil
+}
+
+func (s *networkService) List(_ context.Context, in *vpc.ListNetworksRequest, _ ...grpc.CallOption) (*vpc.ListNetworksResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*vpc.Network
+	for _, id := range sortedKeys(c.networks) {
+		network := c.networks[id]
+		if network.FolderId == in.FolderId && matchFilter(conds, networkField(network)) {
+			items = append(items, proto.Clone(network).(*vpc.Network))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vpc.ListNetworksResponse{Networks: items, NextPageToken: next}, nil
+}
+
+func networkField(n *vpc.Network) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return n.Id, true
+		case "name":
+			return n.Name, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := n.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
+
+type subnetService Cloud
+
+func (s *subnetService) Create(_ context.Context, in *vpc.CreateSubnetRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	if len(in.ZoneId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "zone_id is required")
+	}
+	if len(in.V4CidrBlocks) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "v4_cidr_blocks is required")
+	}
+	if _, ok := c.networks[in.NetworkId]; !ok {
+		return nil, notFound("network", in.NetworkId)
+	}
+
+	var blocks []*net.IPNet
+	for _, cidr := range in.V4CidrBlocks {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil || block.IP.To4() == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid IPv4 CIDR block %q", cidr)
+		}
+		blocks = append(blocks, block)
+	}
+	for _, subnet := range c.subnets {
+		if len(in.Name) > 0 && subnet.FolderId == in.FolderId && subnet.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "subnet with name %s already exists", in.Name)
+		}
+		if subnet.NetworkId != in.NetworkId {
+			continue
+		}
+		for _, cidr := range subnet.V4CidrBlocks {
+			_, other, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			for _, block := range blocks {
+				if block.Contains(other.IP) || other.Contains(block.IP) {
+					return nil, status.Errorf(codes.FailedPrecondition,
+						"CIDR block %s overlaps with subnet %s (%s)", block, subnet.Id, other)
+				}
+			}
+		}
+	}
+
+	subnet := &vpc.Subnet{
+		Id:           c.newID("e9b"),
+		FolderId:     in.FolderId,
+		CreatedAt:    timestamppb.Now(),
+		Name:         in.Name,
+		Description:  in.Description,
+		Labels:       in.Labels,
+		NetworkId:    in.NetworkId,
+		ZoneId:       in.ZoneId,
+		V4CidrBlocks: in.V4CidrBlocks,
+		RouteTableId: in.RouteTableId,
+	}
+	c.subnets[subnet.Id] = subnet
+
+	return c.startOperation(
+		"Create subnet",
+		&vpc.CreateSubnetMetadata{SubnetId: subnet.Id},
+		func() (proto.Message, error) {
+			return proto.Clone(subnet), nil
+		},
+	)
+}
+
+func (s *subnetService) Delete(_ context.Context, in *vpc.DeleteSubnetRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subnet, ok := c.subnets[in.SubnetId]
+	if !ok {
+		return nil, notFound("subnet", in.SubnetId)
+	}
+	for _, instance := range c.instances {
+		for _, nic := range instance.NetworkInterfaces {
+			if nic.SubnetId == subnet.Id {
+				return nil, status.Errorf(codes.FailedPrecondition, "subnet %s is used by instance %s", subnet.Id, instance.Id)
+			}
+		}
+	}
+
+	return c.startOperation(
+		"Delete subnet",
+		&vpc.DeleteSubnetMetadata{SubnetId: subnet.Id},
+		func() (proto.Message, error) {
+			delete(c.subnets, subnet.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *subnetService) Get(_ context.Context, in *vpc.GetSubnetRequest, _ ...grpc.CallOption) (*vpc.Subnet, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subnet, ok := c.subnets[in.SubnetId]
+	if !ok {
+		return nil, notFound("subnet", in.SubnetId)
+	}
+
+	return proto.Clone(subnet).(*vpc.Subnet), nil
+}
+
+func (s *subnetService) List(_ context.Context, in *vpc.ListSubnetsRequest, _ ...grpc.CallOption) (*vpc.ListSubnetsResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*vpc.Subnet
+	for _, id := range sortedKeys(c.subnets) {
+		subnet := c.subnets[id]
+		if subnet.FolderId == in.FolderId && matchFilter(conds, subnetField(subnet)) {
+			items = append(items, proto.Clone(subnet).(*vpc.Subnet))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vpc.ListSubnetsResponse{Subnets: items, NextPageToken: next}, nil
+}
+
+func (s *subnetService) Update(_ context.Context, in *vpc.UpdateSubnetRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	subnet, ok := c.subnets[in.SubnetId]
+	if !ok {
+		return nil, notFound("subnet", in.SubnetId)
+	}
+
+	paths := in.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+
+	updated := proto.Clone(subnet).(*vpc.Subnet)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			updated.Name = in.Name
+		case "description":
+			updated.Description = in.Description
+		case "labels":
+			updated.Labels = in.Labels
+		case "route_table_id":
+			if len(in.RouteTableId) > 0 {
+				table, ok := c.routeTables[in.RouteTableId]
+				if !ok {
+					return nil, notFound("route table", in.RouteTableId)
+				}
+				if table.NetworkId != subnet.NetworkId {
+					return nil, status.Errorf(codes.InvalidArgument,
+						"route table %s is not in network %s", table.Id, subnet.NetworkId)
+				}
+			}
+			updated.RouteTableId = in.RouteTableId
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask path %s", path)
+		}
+	}
+
+	return c.startOperation(
+		"Update subnet",
+		&vpc.UpdateSubnetMetadata{SubnetId: subnet.Id},
+		func() (proto.Message, error) {
+			proto.Reset(subnet)
+			proto.Merge(subnet, updated)
+			return proto.Clone(subnet), nil
+		},
+	)
+}
+
+func subnetField(s *vpc.Subnet) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return s.Id, true
+		case "name":
+			return s.Name, true
+		case "zone_id", "zoneId":
+			return s.ZoneId, true
+		case "network_id", "networkId":
+			return s.NetworkId, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := s.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
+
+type addressService Cloud
+
+func (s *addressService) Create(_ context.Context, in *vpc.CreateAddressRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(in.FolderId) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "folder_id is required")
+	}
+	spec := in.GetExternalIpv4AddressSpec()
+	if spec == nil {
+		return nil, status.Error(codes.InvalidArgument, "external_ipv4_address_spec is required")
+	}
+	for _, a := range c.vpcAddresses {
+		if len(in.Name) > 0 && a.FolderId == in.FolderId && a.Name == in.Name {
+			return nil, status.Errorf(codes.AlreadyExists, "address with name %s already exists", in.Name)
+		}
+	}
+
+	value := spec.Address
+	if len(value) == 0 {
+		var err error
+		if value, err = c.allocateAddress("", []string{externalPool}); err != nil {
+			return nil, err
+		}
+	}
+
+	address := &vpc.Address{
+		Id:          c.newID("e9b"),
+		FolderId:    in.FolderId,
+		CreatedAt:   timestamppb.Now(),
+		Name:        in.Name,
+		Description: in.Description,
+		Labels:      in.Labels,
+		Address: &vpc.Address_ExternalIpv4Address{ExternalIpv4Address: &vpc.ExternalIpv4Address{
+			Address: value,
+			ZoneId:  spec.ZoneId,
+		}},
+		Reserved:           true,
+		Type:               vpc.Address_EXTERNAL,
+		IpVersion:          vpc.Address_IPV4,
+		DeletionProtection: in.DeletionProtection,
+	}
+	c.vpcAddresses[address.Id] = address
+
+	return c.startOperation(
+		"Create address",
+		&vpc.CreateAddressMetadata{AddressId: address.Id},
+		func() (proto.Message, error) {
+			return proto.Clone(address), nil
+		},
+	)
+}
+
+func (s *addressService) Delete(_ context.Context, in *vpc.DeleteAddressRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	address, ok := c.vpcAddresses[in.AddressId]
+	if !ok {
+		return nil, notFound("address", in.AddressId)
+	}
+	if address.Used {
+		return nil, status.Errorf(codes.FailedPrecondition, "address %s is used", address.Id)
+	}
+	if address.DeletionProtection {
+		return nil, status.Errorf(codes.FailedPrecondition, "address %s is protected from deletion", address.Id)
+	}
+
+	return c.startOperation(
+		"Delete address",
+		&vpc.DeleteAddressMetadata{AddressId: address.Id},
+		func() (proto.Message, error) {
+			delete(c.vpcAddresses, address.Id)
+			return &emptypb.Empty{}, nil
+		},
+	)
+}
+
+func (s *addressService) Get(_ context.Context, in *vpc.GetAddressRequest, _ ...grpc.CallOption) (*vpc.Address, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	address, ok := c.vpcAddresses[in.AddressId]
+	if !ok {
+		return nil, notFound("address", in.AddressId)
+	}
+
+	return proto.Clone(address).(*vpc.Address), nil
+}
+
+func (s *addressService) GetByValue(_ context.Context, in *vpc.GetAddressByValueRequest, _ ...grpc.CallOption) (*vpc.Address, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	address := c.addressByValue(in.GetExternalIpv4Address())
+	if address == nil {
+		return nil, notFound("address", in.GetExternalIpv4Address())
+	}
+
+	return proto.Clone(address).(*vpc.Address), nil
+}
+
+func (s *addressService) List(_ context.Context, in *vpc.ListAddressesRequest, _ ...grpc.CallOption) (*vpc.ListAddressesResponse, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conds, err := parseFilter(in.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*vpc.Address
+	for _, id := range sortedKeys(c.vpcAddresses) {
+		address := c.vpcAddresses[id]
+		if address.FolderId == in.FolderId && matchFilter(conds, addressField(address)) {
+			items = append(items, proto.Clone(address).(*vpc.Address))
+		}
+	}
+
+	items, next, err := page(items, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return &vpc.ListAddressesResponse{Addresses: items, NextPageToken: next}, nil
+}
+
+func (s *addressService) Update(_ context.Context, in *vpc.UpdateAddressRequest, _ ...grpc.CallOption) (*operation.Operation, error) {
+	c := (*Cloud)(s)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	address, ok := c.vpcAddresses[in.AddressId]
+	if !ok {
+		return nil, notFound("address", in.AddressId)
+	}
+
+	paths := in.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask is required")
+	}
+
+	updated := proto.Clone(address).(*vpc.Address)
+	for _, path := range paths {
+		switch path {
+		case "name":
+			updated.Name = in.Name
+		case "description":
+			updated.Description = in.Description
+		case "labels":
+			updated.Labels = in.Labels
+		case "reserved":
+			if address.Reserved && !in.Reserved {
+				return nil, status.Errorf(codes.InvalidArgument, "address %s can't be made ephemeral", address.Id)
+			}
+			updated.Reserved = in.Reserved
+		case "deletion_protection":
+			updated.DeletionProtection = in.DeletionProtection
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "unsupported update mask path %s", path)
+		}
+	}
+
+	return c.startOperation(
+		"Update address",
+		&vpc.UpdateAddressMetadata{AddressId: address.Id},
+		func() (proto.Message, error) {
+			proto.Reset(address)
+			proto.Merge(address, updated)
+			return proto.Clone(address), nil
+		},
+	)
+}
+
+// externalPool is the CIDR public addresses are allocated from.
+const externalPool = "51.250.0.0/16"
+
+// externalAddress returns the given public address if it is reserved and free,
+// or allocates an ephemeral one. Must be called with c.mu held.
+func (c *Cloud) externalAddress(value string) (string, error) {
+	if len(value) == 0 {
+		return c.allocateAddress("", []string{externalPool})
+	}
+
+	address := c.addressByValue(value)
+	if address == nil {
+		return "", notFound("address", value)
+	}
+	if address.Used {
+		return "", status.Errorf(codes.FailedPrecondition, "address %s is used", value)
+	}
+
+	return value, nil
+}
+
+// bindExternalAddress marks the public address used by the instance, registering it as
+// an ephemeral address unless it is reserved. Must be called with c.mu held.
+func (c *Cloud) bindExternalAddress(instance *compute.Instance, value string) {
+	if address := c.addressByValue(value); address != nil {
+		address.Used = true
+		return
+	}
+
+	address := &vpc.Address{
+		Id:        c.newID("e9b"),
+		FolderId:  instance.FolderId,
+		CreatedAt: timestamppb.Now(),
+		Address: &vpc.Address_ExternalIpv4Address{ExternalIpv4Address: &vpc.ExternalIpv4Address{
+			Address: value,
+			ZoneId:  instance.ZoneId,
+		}},
+		Used:      true,
+		Type:      vpc.Address_EXTERNAL,
+		IpVersion: vpc.Address_IPV4,
+	}
+	c.vpcAddresses[address.Id] = address
+}
+
+// releaseExternalAddress frees a reserved public address and drops an ephemeral one.
+// Must be called with c.mu held.
+func (c *Cloud) releaseExternalAddress(value string) {
+	address := c.addressByValue(value)
+	switch {
+	case address == nil:
+	case address.Reserved:
+		address.Used = false
+	default:
+		delete(c.vpcAddresses, address.Id)
+	}
+}
+
+func (c *Cloud) addressByValue(value string) *vpc.Address {
+	if len(value) == 0 {
+		return nil
+	}
+	for _, a := range c.vpcAddresses {
+		if a.GetExternalIpv4Address().GetAddress() == value {
+			return a
+		}
+	}
+
+	return nil
+}
+
+func addressField(a *vpc.Address) fieldFunc {
+	return func(field string) (string, bool) {
+		switch field {
+		case "id":
+			return a.Id, true
+		case "name":
+			return a.Name, true
+		}
+		if key, ok := labelKey(field); ok {
+			v, ok := a.Labels[key]
+			return v, ok
+		}
+
+		return "", false
+	}
+}
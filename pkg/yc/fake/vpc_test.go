@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/yc"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+)
+
+func TestEnsureAutoNetwork(t *testing.T) {
+	f, client := newTestCloud(t)
+	ctx := context.Background()
+
+	network, subnets, err := client.EnsureAutoNetwork(ctx, testFolderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if network.Name != yc.AutoNetworkName || network.Labels[common.ManagedKey] != yc.KsToolKey {
+		t.Fatalf("unexpected network %s with labels %v", network.Name, network.Labels)
+	}
+
+	var cidrs []string
+	for _, s := range subnets {
+		if s.NetworkId != network.Id {
+			t.Errorf("subnet %s is in network %s, want %s", s.Name, s.NetworkId, network.Id)
+		}
+		cidrs = append(cidrs, s.V4CidrBlocks...)
+	}
+	// 10.128.0.0/24 is taken by the subnet of the default network.
+	want := []string{"10.128.1.0/24", "10.128.2.0/24", "10.128.3.0/24"}
+	if !slices.Equal(cidrs, want) {
+		t.Fatalf("got CIDRs %v, want %v", cidrs, want)
+	}
+
+	all, err := client.VPCSubnetList(ctx, testFolderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, subnets, err := client.EnsureAutoNetwork(ctx, testFolderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != network.Id || len(subnets) != len(yc.AutoNetworkZones) {
+		t.Errorf("second run: got network %s with %d subnets, want %s with %d",
+			again.Id, len(subnets), network.Id, len(yc.AutoNetworkZones))
+	}
+	networks, err := client.VPCNetworkList(ctx, testFolderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := client.VPCSubnetList(ctx, testFolderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 2 || len(after) != len(all) {
+		t.Errorf("second run: got %d networks and %d subnets, want 2 and %d", len(networks), len(after), len(all))
+	}
+	if f.Pending() != 0 {
+		t.Errorf("%d operations pending", f.Pending())
+	}
+}
+
+func TestEnsureAutoNetworkExhausted(t *testing.T) {
+	f, client := newTestCloud(t)
+	ctx := context.Background()
+
+	other := f.AddNetwork(&vpc.Network{FolderId: testFolderID, Name: "other"})
+	f.AddSubnet(&vpc.Subnet{
+		FolderId:     testFolderID,
+		NetworkId:    other.Id,
+		Name:         "other-b",
+		ZoneId:       "ru-central1-b",
+		V4CidrBlocks: []string{"10.128.0.0/16"},
+	})
+
+	if _, _, err := client.EnsureAutoNetwork(ctx, testFolderID); err == nil {
+		t.Fatal("no error with the pool taken")
+	}
+}
+
+func TestEnsureAutoNetworkNotManaged(t *testing.T) {
+	f, client := newTestCloud(t)
+	ctx := context.Background()
+
+	f.AddNetwork(&vpc.Network{FolderId: testFolderID, Name: yc.AutoNetworkName})
+
+	if _, _, err := client.EnsureAutoNetwork(ctx, testFolderID); err == nil {
+		t.Fatal("no error adopting a network not managed by ks")
+	}
+	lst, err := client.VPCSubnetList(ctx, testFolderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lst) != 1 {
+		t.Errorf("got %d subnets, want 1", len(lst))
+	}
+}
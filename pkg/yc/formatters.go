@@ -17,55 +17,595 @@ limitations under the License.
 package yc
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ks-tool/ks/pkg/common"
+	"github.com/ks-tool/ks/pkg/utils"
 
-	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/dns/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v3"
 )
 
-func FPrintComputeList(w io.Writer, lst []*compute.Instance) {
+type OutputFormat string
+
+const (
+	OutputTable      OutputFormat = ""
+	OutputWide       OutputFormat = "wide"
+	OutputJSON       OutputFormat = "json"
+	OutputYAML       OutputFormat = "yaml"
+	OutputName       OutputFormat = "name"
+	OutputID         OutputFormat = "id"
+	OutputJSONPath   OutputFormat = "jsonpath"
+	OutputGoTemplate OutputFormat = "go-template"
+)
+
+// OutputFormats is the list of output formats for flag help.
+const OutputFormats = "json|yaml|wide|name|id|jsonpath=<template>|go-template=<template>"
+
+var protoJSON = protojson.MarshalOptions{UseProtoNames: true}
+
+// Printer renders resources in one of the output formats.
+// Templates and sort keys are applied to every resource separately and work on its JSON form,
+// which uses proto field names, e.g. `{.network_interfaces[0].primary_v4_address.address}`.
+type Printer struct {
+	Format    OutputFormat
+	NoHeaders bool
+
+	jsonPath *utils.JSONPath
+	template *template.Template
+	sortBy   *utils.JSONPath
+}
+
+// Column is a table column of a resource.
+type Column[T proto.Message] struct {
+	Header string
+	// Wide columns are shown in the wide output format only.
+	Wide  bool
+	Value func(item T) any
+}
+
+// NewPrinter parses the output format, e.g. "yaml" or "jsonpath={.id}".
+func NewPrinter(output, sortBy string, noHeaders bool) (*Printer, error) {
+	format, tpl, _ := strings.Cut(output, "=")
+	p := &Printer{
+		Format:    OutputFormat(format),
+		NoHeaders: noHeaders,
+	}
+
+	var err error
+	switch p.Format {
+	case OutputTable, OutputWide, OutputJSON, OutputYAML, OutputName, OutputID:
+	case OutputJSONPath:
+		if p.jsonPath, err = utils.ParseJSONPath(tpl); err != nil {
+			return nil, err
+		}
+	case OutputGoTemplate:
+		if p.template, err = template.New("output").Funcs(template.FuncMap{
+			"join": strings.Join,
+		}).Parse(tpl); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown output format %q, allow: %s", output, OutputFormats)
+	}
+
+	if len(sortBy) > 0 {
+		if p.sortBy, err = utils.ParseJSONPath(sortBy); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// PrintList renders items, using columns for the table formats.
+func PrintList[T proto.Message](w io.Writer, p *Printer, items []T, columns []Column[T]) error {
+	if p == nil {
+		p = &Printer{}
+	}
+
+	items, err := sortItems(p.sortBy, items)
+	if err != nil {
+		return err
+	}
+
+	switch p.Format {
+	case OutputTable, OutputWide:
+		printTable(w, p, items, columns)
+		return nil
+	case OutputJSON, OutputYAML:
+		raw := make([]json.RawMessage, 0, len(items))
+		for _, item := range items {
+			b, err := protoJSON.Marshal(item)
+			if err != nil {
+				return err
+			}
+			raw = append(raw, b)
+		}
+
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return err
+		}
+
+		return p.write(w, b)
+	}
+
+	for _, item := range items {
+		if err = p.printLine(w, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PrintItem renders a single item. JSON and YAML print an object instead of a list.
+func PrintItem[T proto.Message](w io.Writer, p *Printer, item T, columns []Column[T]) error {
+	if p != nil && (p.Format == OutputJSON || p.Format == OutputYAML) {
+		b, err := protoJSON.Marshal(item)
+		if err != nil {
+			return err
+		}
+
+		return p.write(w, b)
+	}
+
+	return PrintList(w, p, []T{item}, columns)
+}
+
+func printTable[T proto.Message](w io.Writer, p *Printer, items []T, columns []Column[T]) {
 	tbl := table.NewWriter()
 	tbl.SetOutputMirror(w)
-	tbl.AppendHeader(table.Row{"ID", "Name", "IP", "Status", "Zone", "SubnetID", "Platform"})
 
-	for _, item := range lst {
-		name := item.Name
-		if len(name) == 0 {
-			name = item.Id
+	var header table.Row
+	for _, col := range columns {
+		if !col.Wide || p.Format == OutputWide {
+			header = append(header, col.Header)
 		}
+	}
+	if !p.NoHeaders {
+		tbl.AppendHeader(header)
+	}
 
-		tbl.AppendRow(table.Row{
-			item.Id,
-			name,
-			GetIPv4(item).External(),
-			item.Status.String(),
-			item.ZoneId,
-			item.NetworkInterfaces[0].SubnetId,
-			item.PlatformId})
+	for _, item := range items {
+		var row table.Row
+		for _, col := range columns {
+			if !col.Wide || p.Format == OutputWide {
+				row = append(row, col.Value(item))
+			}
+		}
+		tbl.AppendRow(row)
 	}
 
 	tbl.Render()
 }
 
-func FPrintClusterGet(w io.Writer, lst []*compute.Instance) {
+func (p *Printer) printLine(w io.Writer, item proto.Message) error {
+	var line string
+	switch p.Format {
+	case OutputName, OutputID:
+		line = stringField(item, string(p.Format))
+		if len(line) == 0 {
+			line = stringField(item, "id")
+		}
+	case OutputJSONPath, OutputGoTemplate:
+		v, err := toValue(item)
+		if err != nil {
+			return err
+		}
+
+		if p.jsonPath != nil {
+			line, err = p.jsonPath.Execute(v)
+		} else {
+			buf := new(bytes.Buffer)
+			err = p.template.Execute(buf, v)
+			line = buf.String()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
+
+// write prints a JSON document as indented JSON or as YAML keeping the order of the keys.
+func (p *Printer) write(w io.Writer, doc []byte) error {
+	if p.Format == OutputYAML {
+		var node yaml.Node
+		if err := yaml.Unmarshal(doc, &node); err != nil {
+			return err
+		}
+		blockStyle(&node)
+
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return err
+		}
+
+		return enc.Close()
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.Indent(buf, doc, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// blockStyle drops the flow style and quoting the JSON document was parsed with.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		blockStyle(n)
+	}
+}
+
+func sortItems[T proto.Message](key *utils.JSONPath, items []T) ([]T, error) {
+	if key == nil {
+		return items, nil
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		v, err := toValue(item)
+		if err != nil {
+			return nil, err
+		}
+		if keys[i], err = key.Execute(v); err != nil {
+			return nil, err
+		}
+	}
+
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ka, kb := keys[idx[a]], keys[idx[b]]
+		na, errA := strconv.ParseFloat(ka, 64)
+		nb, errB := strconv.ParseFloat(kb, 64)
+		if errA == nil && errB == nil {
+			return na < nb
+		}
+
+		return ka < kb
+	})
+
+	out := make([]T, len(items))
+	for i, j := range idx {
+		out[i] = items[j]
+	}
+
+	return out, nil
+}
+
+// toValue converts a message to its JSON form decoded into maps and slices.
+func toValue(item proto.Message) (any, error) {
+	b, err := protoJSON.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var v any
+	err = json.Unmarshal(b, &v)
+	return v, err
+}
+
+func stringField(item proto.Message, name string) string {
+	m := item.ProtoReflect()
+	f := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if f == nil {
+		return ""
+	}
+
+	return m.Get(f).String()
+}
+
+func labelsString(labels map[string]string) string {
+	out := make([]string, 0, len(labels))
+	for k, v := range labels {
+		out = append(out, k+"="+v)
+	}
+	sort.Strings(out)
+
+	return strings.Join(out, ",")
+}
+
+var ComputeColumns = []Column[*compute.Instance]{
+	{Header: "ID", Value: func(i *compute.Instance) any { return i.Id }},
+	{Header: "Name", Value: func(i *compute.Instance) any { return instanceName(i) }},
+	{Header: "IP", Value: func(i *compute.Instance) any { return GetIPv4(i).External() }},
+	{Header: "Internal IP", Wide: true, Value: func(i *compute.Instance) any { return GetIPv4(i).Internal() }},
+	{Header: "Status", Value: func(i *compute.Instance) any { return i.Status.String() }},
+	{Header: "Zone", Value: func(i *compute.Instance) any { return i.ZoneId }},
+	{Header: "SubnetID", Value: func(i *compute.Instance) any { return firstSubnetID(i) }},
+	{Header: "Platform", Value: func(i *compute.Instance) any { return i.PlatformId }},
+	{Header: "Cores", Wide: true, Value: func(i *compute.Instance) any { return i.GetResources().GetCores() }},
+	{Header: "Memory", Wide: true, Value: func(i *compute.Instance) any {
+		return fmt.Sprintf("%dG", i.GetResources().GetMemory()/utils.Gib)
+	}},
+	{Header: "Labels", Wide: true, Value: func(i *compute.Instance) any { return labelsString(i.Labels) }},
+	{Header: "Created", Wide: true, Value: func(i *compute.Instance) any { return formatTime(i.CreatedAt) }},
+}
+
+func FPrintComputeList(w io.Writer, p *Printer, lst []*compute.Instance) error {
+	return PrintList(w, p, lst, ComputeColumns)
+}
+
+func FPrintClusterGet(w io.Writer, p *Printer, lst []*compute.Instance) error {
+	columns := append([]Column[*compute.Instance]{}, ComputeColumns...)
+	columns[0].Header = "ComputeID"
+
+	return PrintList(w, p, lst, columns)
+}
+
+var DiskColumns = []Column[*compute.Disk]{
+	{Header: "ID", Value: func(d *compute.Disk) any { return d.Id }},
+	{Header: "Name", Value: func(d *compute.Disk) any { return d.Name }},
+	{Header: "Size", Value: func(d *compute.Disk) any { return fmt.Sprintf("%dG", d.Size/utils.Gib) }},
+	{Header: "Type", Value: func(d *compute.Disk) any { return d.TypeId }},
+	{Header: "Zone", Value: func(d *compute.Disk) any { return d.ZoneId }},
+	{Header: "Status", Value: func(d *compute.Disk) any { return d.Status.String() }},
+	{Header: "Instances", Value: func(d *compute.Disk) any { return strings.Join(d.InstanceIds, ",") }},
+	{Header: "Source", Wide: true, Value: func(d *compute.Disk) any { return d.GetSourceSnapshotId() + d.GetSourceImageId() }},
+	{Header: "Labels", Wide: true, Value: func(d *compute.Disk) any { return labelsString(d.Labels) }},
+	{Header: "Created", Wide: true, Value: func(d *compute.Disk) any { return formatTime(d.CreatedAt) }},
+}
+
+var SnapshotColumns = []Column[*compute.Snapshot]{
+	{Header: "ID", Value: func(s *compute.Snapshot) any { return s.Id }},
+	{Header: "Name", Value: func(s *compute.Snapshot) any { return s.Name }},
+	{Header: "Disk Size", Value: func(s *compute.Snapshot) any { return fmt.Sprintf("%dG", s.DiskSize/utils.Gib) }},
+	{Header: "Source Disk", Value: func(s *compute.Snapshot) any { return s.SourceDiskId }},
+	{Header: "Status", Value: func(s *compute.Snapshot) any { return s.Status.String() }},
+	{Header: "Storage Size", Wide: true, Value: func(s *compute.Snapshot) any {
+		return fmt.Sprintf("%dM", s.StorageSize/(1<<20))
+	}},
+	{Header: "Labels", Wide: true, Value: func(s *compute.Snapshot) any { return labelsString(s.Labels) }},
+	{Header: "Created", Wide: true, Value: func(s *compute.Snapshot) any { return formatTime(s.CreatedAt) }},
+}
+
+var ImageColumns = []Column[*compute.Image]{
+	{Header: "ID", Value: func(i *compute.Image) any { return i.Id }},
+	{Header: "Name", Value: func(i *compute.Image) any { return i.Name }},
+	{Header: "Family", Value: func(i *compute.Image) any { return i.Family }},
+	{Header: "Min Disk", Value: func(i *compute.Image) any { return fmt.Sprintf("%dG", i.MinDiskSize/utils.Gib) }},
+	{Header: "Status", Value: func(i *compute.Image) any { return i.Status.String() }},
+	{Header: "Created", Value: func(i *compute.Image) any { return formatTime(i.CreatedAt) }},
+	{Header: "Labels", Wide: true, Value: func(i *compute.Image) any { return labelsString(i.Labels) }},
+}
+
+var AddressColumns = []Column[*vpc.Address]{
+	{Header: "ID", Value: func(a *vpc.Address) any { return a.Id }},
+	{Header: "Name", Value: func(a *vpc.Address) any { return a.Name }},
+	{Header: "Address", Value: func(a *vpc.Address) any { return AddressIP(a) }},
+	{Header: "Zone", Value: func(a *vpc.Address) any { return a.GetExternalIpv4Address().GetZoneId() }},
+	{Header: "Reserved", Value: func(a *vpc.Address) any { return a.Reserved }},
+	{Header: "Used", Value: func(a *vpc.Address) any { return a.Used }},
+	{Header: "Labels", Wide: true, Value: func(a *vpc.Address) any { return labelsString(a.Labels) }},
+	{Header: "Created", Wide: true, Value: func(a *vpc.Address) any { return formatTime(a.CreatedAt) }},
+}
+
+var NetworkColumns = []Column[*vpc.Network]{
+	{Header: "ID", Value: func(n *vpc.Network) any { return n.Id }},
+	{Header: "Name", Value: func(n *vpc.Network) any { return n.Name }},
+	{Header: "Description", Wide: true, Value: func(n *vpc.Network) any { return n.Description }},
+	{Header: "Labels", Wide: true, Value: func(n *vpc.Network) any { return labelsString(n.Labels) }},
+	{Header: "Created", Value: func(n *vpc.Network) any { return formatTime(n.CreatedAt) }},
+}
+
+var SubnetColumns = []Column[*vpc.Subnet]{
+	{Header: "ID", Value: func(s *vpc.Subnet) any { return s.Id }},
+	{Header: "Name", Value: func(s *vpc.Subnet) any { return s.Name }},
+	{Header: "Network", Value: func(s *vpc.Subnet) any { return s.NetworkId }},
+	{Header: "Zone", Value: func(s *vpc.Subnet) any { return s.ZoneId }},
+	{Header: "CIDR", Value: func(s *vpc.Subnet) any { return strings.Join(s.V4CidrBlocks, ",") }},
+	{Header: "Route Table", Wide: true, Value: func(s *vpc.Subnet) any { return s.RouteTableId }},
+	{Header: "Labels", Wide: true, Value: func(s *vpc.Subnet) any { return labelsString(s.Labels) }},
+	{Header: "Created", Wide: true, Value: func(s *vpc.Subnet) any { return formatTime(s.CreatedAt) }},
+}
+
+var SecurityGroupColumns = []Column[*vpc.SecurityGroup]{
+	{Header: "ID", Value: func(g *vpc.SecurityGroup) any { return g.Id }},
+	{Header: "Name", Value: func(g *vpc.SecurityGroup) any { return g.Name }},
+	{Header: "Network", Value: func(g *vpc.SecurityGroup) any { return g.NetworkId }},
+	{Header: "Preset", Value: func(g *vpc.SecurityGroup) any { return g.Labels[common.LabelSecurityGroupPreset] }},
+	{Header: "Rules", Value: func(g *vpc.SecurityGroup) any { return len(g.Rules) }},
+	{Header: "Default", Wide: true, Value: func(g *vpc.SecurityGroup) any { return g.DefaultForNetwork }},
+	{Header: "Labels", Wide: true, Value: func(g *vpc.SecurityGroup) any { return labelsString(g.Labels) }},
+	{Header: "Created", Wide: true, Value: func(g *vpc.SecurityGroup) any { return formatTime(g.CreatedAt) }},
+}
+
+var SecurityGroupRuleColumns = []Column[*vpc.SecurityGroupRule]{
+	{Header: "ID", Value: func(r *vpc.SecurityGroupRule) any { return r.Id }},
+	{Header: "Rule", Value: func(r *vpc.SecurityGroupRule) any { return RuleFromProto(r).String() }},
+	{Header: "Description", Value: func(r *vpc.SecurityGroupRule) any { return r.Description }},
+}
+
+var DNSZoneColumns = []Column[*dns.DnsZone]{
+	{Header: "ID", Value: func(z *dns.DnsZone) any { return z.Id }},
+	{Header: "Name", Value: func(z *dns.DnsZone) any { return z.Name }},
+	{Header: "Zone", Value: func(z *dns.DnsZone) any { return z.Zone }},
+	{Header: "Visibility", Value: func(z *dns.DnsZone) any {
+		if z.PublicVisibility != nil {
+			return "public"
+		}
+		return "private"
+	}},
+	{Header: "Networks", Wide: true, Value: func(z *dns.DnsZone) any {
+		return strings.Join(z.GetPrivateVisibility().GetNetworkIds(), ",")
+	}},
+	{Header: "Labels", Wide: true, Value: func(z *dns.DnsZone) any { return labelsString(z.Labels) }},
+	{Header: "Created", Wide: true, Value: func(z *dns.DnsZone) any { return formatTime(z.CreatedAt) }},
+}
+
+var DNSRecordColumns = []Column[*dns.RecordSet]{
+	{Header: "Name", Value: func(rs *dns.RecordSet) any { return rs.Name }},
+	{Header: "Type", Value: func(rs *dns.RecordSet) any { return rs.Type }},
+	{Header: "TTL", Value: func(rs *dns.RecordSet) any { return rs.Ttl }},
+	{Header: "Data", Value: func(rs *dns.RecordSet) any { return strings.Join(rs.Data, ",") }},
+}
+
+var InstanceGroupColumns = []Column[*instancegroup.InstanceGroup]{
+	{Header: "ID", Value: func(g *instancegroup.InstanceGroup) any { return g.Id }},
+	{Header: "Name", Value: func(g *instancegroup.InstanceGroup) any { return g.Name }},
+	{Header: "Status", Value: func(g *instancegroup.InstanceGroup) any { return g.Status.String() }},
+	{Header: "Scale", Value: func(g *instancegroup.InstanceGroup) any { return scalePolicyString(g.ScalePolicy) }},
+	{Header: "Target", Value: func(g *instancegroup.InstanceGroup) any { return g.GetManagedInstancesState().GetTargetSize() }},
+	{Header: "Running", Value: func(g *instancegroup.InstanceGroup) any {
+		return g.GetManagedInstancesState().GetRunningActualCount()
+	}},
+	{Header: "Zones", Value: func(g *instancegroup.InstanceGroup) any {
+		var zones []string
+		for _, z := range g.GetAllocationPolicy().GetZones() {
+			zones = append(zones, z.ZoneId)
+		}
+		return strings.Join(zones, ",")
+	}},
+	{Header: "Labels", Wide: true, Value: func(g *instancegroup.InstanceGroup) any { return labelsString(g.Labels) }},
+	{Header: "Created", Wide: true, Value: func(g *instancegroup.InstanceGroup) any { return formatTime(g.CreatedAt) }},
+}
+
+var ManagedInstanceColumns = []Column[*instancegroup.ManagedInstance]{
+	{Header: "ID", Value: func(i *instancegroup.ManagedInstance) any { return i.InstanceId }},
+	{Header: "Name", Value: func(i *instancegroup.ManagedInstance) any { return i.Name }},
+	{Header: "Zone", Value: func(i *instancegroup.ManagedInstance) any { return i.ZoneId }},
+	{Header: "Status", Value: func(i *instancegroup.ManagedInstance) any { return i.Status.String() }},
+	{Header: "Internal IP", Value: func(i *instancegroup.ManagedInstance) any {
+		if len(i.NetworkInterfaces) == 0 {
+			return ""
+		}
+		return i.NetworkInterfaces[0].GetPrimaryV4Address().GetAddress()
+	}},
+	{Header: "External IP", Value: func(i *instancegroup.ManagedInstance) any {
+		if len(i.NetworkInterfaces) == 0 {
+			return ""
+		}
+		return i.NetworkInterfaces[0].GetPrimaryV4Address().GetOneToOneNat().GetAddress()
+	}},
+	{Header: "FQDN", Wide: true, Value: func(i *instancegroup.ManagedInstance) any { return i.Fqdn }},
+	{Header: "Message", Wide: true, Value: func(i *instancegroup.ManagedInstance) any { return i.StatusMessage }},
+}
+
+// scalePolicyString returns "fixed <size>" or "auto <min zone size>-<max size>".
+func scalePolicyString(p *instancegroup.ScalePolicy) string {
+	if auto := p.GetAutoScale(); auto != nil {
+		return fmt.Sprintf("auto %d-%d", auto.MinZoneSize, auto.MaxSize)
+	}
+
+	return fmt.Sprintf("fixed %d", p.GetFixedScale().GetSize())
+}
+
+func instanceName(i *compute.Instance) string {
+	if len(i.Name) == 0 {
+		return i.Id
+	}
+
+	return i.Name
+}
+
+func firstSubnetID(i *compute.Instance) string {
+	if len(i.NetworkInterfaces) == 0 {
+		return ""
+	}
+
+	return i.NetworkInterfaces[0].SubnetId
+}
+
+func formatTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+
+	return ts.AsTime().Local().Format(time.DateTime)
+}
+
+// FPrintBulkResults prints the summary of a bulk run.
+func FPrintBulkResults(w io.Writer, results []BulkResult) {
 	tbl := table.NewWriter()
 	tbl.SetOutputMirror(w)
-	tbl.AppendHeader(table.Row{"ComputeID", "Name", "IP", "Status", "Zone", "SubnetID", "Platform"})
+	tbl.AppendHeader(table.Row{"ID", "Name", "Result", "Operation", "Duration", "Error"})
+
+	for _, res := range results {
+		result := "OK"
+		switch {
+		case res.Err != nil:
+			result = "FAILED"
+		case res.Response == nil:
+			result = "ACCEPTED"
+		}
 
-	for _, item := range lst {
-		name := item.Name
-		if len(name) == 0 {
-			name = item.Id
+		var msg string
+		if res.Err != nil {
+			msg = res.Err.Error()
 		}
 
 		tbl.AppendRow(table.Row{
-			item.Id,
-			name,
-			item.NetworkInterfaces[0].PrimaryV4Address.OneToOneNat.Address,
-			item.Status.String(),
-			item.ZoneId,
-			item.NetworkInterfaces[0].SubnetId,
-			item.PlatformId})
+			res.ID,
+			res.Name,
+			result,
+			res.OperationID,
+			res.Duration.Round(time.Second / 10).String(),
+			msg,
+		})
+	}
+
+	tbl.Render()
+}
+
+// FPrintInstanceChanges prints the changes of an instance update.
+func FPrintInstanceChanges(w io.Writer, changes []InstanceChange) {
+	tbl := table.NewWriter()
+	tbl.SetOutputMirror(w)
+	tbl.AppendHeader(table.Row{"Field", "Before", "After", "Restart"})
+
+	for _, ch := range changes {
+		var restart string
+		if ch.Restart {
+			restart = "yes"
+		}
+		tbl.AppendRow(table.Row{ch.Field, ch.Before, ch.After, restart})
+	}
+
+	tbl.Render()
+}
+
+// FPrintDNSRecordChanges prints the record sets changed by a DNS sync.
+func FPrintDNSRecordChanges(w io.Writer, changes []DNSRecordChange) {
+	tbl := table.NewWriter()
+	tbl.SetOutputMirror(w)
+	tbl.AppendHeader(table.Row{"Zone", "Name", "Type", "Data", "Action"})
+
+	for _, ch := range changes {
+		action := "updated"
+		if ch.Deleted {
+			action = "deleted"
+		}
+		tbl.AppendRow(table.Row{ch.Zone, ch.Name, ch.Type, strings.Join(ch.Data, ","), action})
 	}
 
 	tbl.Render()
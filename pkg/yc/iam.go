@@ -18,18 +18,28 @@ package yc
 
 import (
 	"context"
+	"fmt"
 
-	"github.com/yandex-cloud/go-sdk/sdkresolvers"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/iam/v1"
 )
 
-func (c *Client) IAMServiceAccountGetIdByName(ctx context.Context, name string) (string, error) {
+func (c *Client) IAMServiceAccountGetIdByName(ctx context.Context, folderID, name string) (string, error) {
 	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
-	sa := sdkresolvers.ServiceAccountResolver(name)
-	if err := c.sdk.Resolve(cctx, sa); err != nil {
+	resp, err := c.backend.ServiceAccount().List(cctx, &iam.ListServiceAccountsRequest{
+		FolderId: folderID,
+		Filter:   Filter{Field: "name", Operator: OperatorEq, Value: name}.String(),
+	})
+	if err != nil {
 		return "", err
 	}
 
-	return sa.ID(), sa.Err()
+	for _, sa := range resp.ServiceAccounts {
+		if sa.Name == name {
+			return sa.Id, nil
+		}
+	}
+
+	return "", fmt.Errorf("service account %q not found", name)
 }
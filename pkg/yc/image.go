@@ -0,0 +1,144 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+)
+
+// ImageConfig describes an image to create from a disk.
+type ImageConfig struct {
+	FolderID    string
+	Name        string
+	Family      string
+	Description string
+	DiskID      string
+	Labels      map[string]string
+}
+
+// BakeRecipe is what a baked image is made of. Images with the same recipe hash are the same.
+type BakeRecipe struct {
+	SourceImageID string
+	DiskSizeGib   int64
+	// Scripts are run in order, their names don't change the recipe.
+	Scripts []BakeScript
+}
+
+type BakeScript struct {
+	Name    string
+	Content []byte
+}
+
+// Hash returns a hex digest of the recipe which fits a label value.
+func (r BakeRecipe) Hash() string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "source=%s\ndisk-size=%d\n", r.SourceImageID, r.DiskSizeGib)
+	for _, s := range r.Scripts {
+		_, _ = fmt.Fprintf(h, "script=%d\n", len(s.Content))
+		_, _ = h.Write(s.Content)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+func (c *Client) ComputeImageCreate(ctx context.Context, cfg *ImageConfig) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Image().Create(cctx, &compute.CreateImageRequest{
+		FolderId:    cfg.FolderID,
+		Name:        cfg.Name,
+		Description: cfg.Description,
+		Labels:      cfg.Labels,
+		Family:      cfg.Family,
+		Source:      &compute.CreateImageRequest_DiskId{DiskId: cfg.DiskID},
+	}))
+}
+
+// ComputeImageLatestByFamily returns the newest image of the family in the folder.
+// The folder defaults to DefaultImageFolderID, the public images of Yandex Cloud.
+func (c *Client) ComputeImageLatestByFamily(ctx context.Context, folderID, family string) (*compute.Image, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	if len(folderID) == 0 {
+		folderID = DefaultImageFolderID
+	}
+
+	image, err := c.backend.Image().GetLatestByFamily(cctx, &compute.GetImageLatestByFamilyRequest{
+		FolderId: folderID,
+		Family:   family,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("image family %q in folder %s: %w", family, folderID, err)
+	}
+
+	return image, nil
+}
+
+// ComputeImageList returns the images of the folder, only of the family if it is not empty.
+func (c *Client) ComputeImageList(ctx context.Context, folderID, family string) ([]*compute.Image, error) {
+	if len(folderID) == 0 {
+		folderID = DefaultImageFolderID
+	}
+
+	req := &compute.ListImagesRequest{FolderId: folderID, PageSize: listPageSize}
+	if len(family) > 0 {
+		req.Filter = Filter{Field: "family", Operator: OperatorEq, Value: family}.String()
+	}
+
+	var out []*compute.Image
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.Image().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Images...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// bootImageID returns the image ID of the config, resolving the image family unless an ID is given.
+func (c *Client) bootImageID(ctx context.Context, cfg *ComputeInstanceConfig) (string, error) {
+	if len(cfg.DiskID) > 0 {
+		return cfg.DiskID, nil
+	}
+
+	family := cfg.ImageFamily
+	if len(family) == 0 {
+		family = DefaultImageFamily
+	}
+
+	image, err := c.ComputeImageLatestByFamily(ctx, cfg.ImageFolderID, family)
+	if err != nil {
+		return "", err
+	}
+
+	return image.Id, nil
+}
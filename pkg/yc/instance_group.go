@@ -0,0 +1,387 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1/instancegroup"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	autoScaleMeasurementDuration   = time.Minute
+	autoScaleWarmupDuration        = 2 * time.Minute
+	autoScaleStabilizationDuration = 5 * time.Minute
+)
+
+// InstanceGroupConfig is an instance group whose instances are created from Instance.
+type InstanceGroupConfig struct {
+	Instance *ComputeInstanceConfig
+	// Zones the instances are spread over, the zone of Instance if empty.
+	Zones []string
+	// Size is the number of instances of a fixed scale group. It is ignored with AutoScale.
+	Size      int64
+	AutoScale *AutoScale
+	// MaxUnavailable and MaxExpansion are the number of instances that may be stopped
+	// or created above the target size while the group is updated. They can't both be 0.
+	MaxUnavailable int64
+	MaxExpansion   int64
+	// ServiceAccount (name) manages the instances of the group, the one of Instance if empty.
+	ServiceAccount string
+}
+
+// AutoScale scales the group between MinZoneSize in every zone and MaxSize in total by the CPU utilization.
+type AutoScale struct {
+	MinZoneSize int64
+	MaxSize     int64
+	InitialSize int64
+	// CPUTarget is the average CPU utilization in percent the group is scaled to.
+	CPUTarget float64
+}
+
+// InstanceGroupScalePolicy returns a fixed scale policy of the size, or an auto scale one if auto is not nil.
+func InstanceGroupScalePolicy(size int64, auto *AutoScale) *instancegroup.ScalePolicy {
+	if auto == nil {
+		return &instancegroup.ScalePolicy{
+			ScaleType: &instancegroup.ScalePolicy_FixedScale_{
+				FixedScale: &instancegroup.ScalePolicy_FixedScale{Size: size},
+			},
+		}
+	}
+
+	return &instancegroup.ScalePolicy{
+		ScaleType: &instancegroup.ScalePolicy_AutoScale_{
+			AutoScale: &instancegroup.ScalePolicy_AutoScale{
+				MinZoneSize:           auto.MinZoneSize,
+				MaxSize:               auto.MaxSize,
+				InitialSize:           auto.InitialSize,
+				MeasurementDuration:   durationpb.New(autoScaleMeasurementDuration),
+				WarmupDuration:        durationpb.New(autoScaleWarmupDuration),
+				StabilizationDuration: durationpb.New(autoScaleStabilizationDuration),
+				CpuUtilizationRule:    &instancegroup.ScalePolicy_CpuUtilizationRule{UtilizationTarget: auto.CPUTarget},
+			},
+		},
+	}
+}
+
+// instanceTemplate builds the template of the group instances the way ComputeInstanceCreate builds an instance.
+// Interfaces without a subnet get the preferred subnet of every zone. Instances can't have static
+// addresses or existing disks, as the template is shared by all of them.
+func (c *Client) instanceTemplate(
+	ctx context.Context,
+	cfg *ComputeInstanceConfig,
+	zones []string,
+) (*instancegroup.InstanceTemplate, error) {
+	r := cfg.resourcesSpec()
+	diskSpec, err := c.bootDiskSpec(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl := &instancegroup.InstanceTemplate{
+		Name:       cfg.Name + "-{instance.index}",
+		Labels:     cfg.Labels,
+		PlatformId: cfg.PlatformID,
+		ResourcesSpec: &instancegroup.ResourcesSpec{
+			Memory:       r.Memory,
+			Cores:        r.Cores,
+			CoreFraction: r.CoreFraction,
+			Gpus:         r.Gpus,
+		},
+		Metadata: cfg.Metadata,
+		MetadataOptions: &instancegroup.MetadataOptions{
+			GceHttpEndpoint: instancegroup.MetadataOption_ENABLED,
+			GceHttpToken:    instancegroup.MetadataOption_ENABLED,
+		},
+		BootDiskSpec: &instancegroup.AttachedDiskSpec{
+			Mode: instancegroup.AttachedDiskSpec_READ_WRITE,
+			DiskSpec: &instancegroup.AttachedDiskSpec_DiskSpec{
+				TypeId:      diskSpec.TypeId,
+				Size:        diskSpec.Size,
+				SourceOneof: &instancegroup.AttachedDiskSpec_DiskSpec_ImageId{ImageId: diskSpec.GetImageId()},
+			},
+		},
+		SchedulingPolicy: &instancegroup.SchedulingPolicy{Preemptible: cfg.Preemptible},
+	}
+
+	disks, err := cfg.secondaryDisks()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range disks {
+		if len(d.DiskID) > 0 {
+			return nil, fmt.Errorf("secondary disk %q: instance group can't attach an existing disk", d.DeviceName)
+		}
+		spec := d.Spec("").GetDiskSpec()
+		disk := &instancegroup.AttachedDiskSpec_DiskSpec{TypeId: spec.TypeId, Size: spec.Size}
+		if len(d.SnapshotID) > 0 {
+			disk.SourceOneof = &instancegroup.AttachedDiskSpec_DiskSpec_SnapshotId{SnapshotId: d.SnapshotID}
+		}
+		tpl.SecondaryDiskSpecs = append(tpl.SecondaryDiskSpecs, &instancegroup.AttachedDiskSpec{
+			Mode:       instancegroup.AttachedDiskSpec_READ_WRITE,
+			DeviceName: d.DeviceName,
+			DiskSpec:   disk,
+		})
+	}
+
+	nics, err := cfg.networkInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	pref, err := ParseSubnetPreference(cfg.SubnetLabels, cfg.SubnetCIDR)
+	if err != nil {
+		return nil, err
+	}
+	for _, nic := range nics {
+		if len(nic.IPv4) > 0 || len(nic.NATAddress) > 0 || len(nic.IPv6Address) > 0 {
+			return nil, errors.New("instance group can't have static addresses")
+		}
+
+		subnetIDs := []string{nic.SubnetID}
+		if len(nic.SubnetID) == 0 {
+			subnetIDs = subnetIDs[:0]
+			for _, zone := range zones {
+				zoneCfg := *cfg
+				zoneCfg.Zone = zone
+				id, err := c.subnetInZone(ctx, &zoneCfg, pref)
+				if err != nil {
+					return nil, fmt.Errorf("zone %s: %w", zone, err)
+				}
+				subnetIDs = append(subnetIDs, id)
+			}
+		}
+
+		networkID, err := c.subnetsNetwork(ctx, subnetIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		spec := &instancegroup.NetworkInterfaceSpec{
+			NetworkId:            networkID,
+			SubnetIds:            subnetIDs,
+			PrimaryV4AddressSpec: &instancegroup.PrimaryAddressSpec{},
+			SecurityGroupIds:     nic.SecurityGroupIDs,
+		}
+		if nic.NAT {
+			spec.PrimaryV4AddressSpec.OneToOneNatSpec = &instancegroup.OneToOneNatSpec{IpVersion: instancegroup.IpVersion_IPV4}
+		}
+		if nic.IPv6 {
+			spec.PrimaryV6AddressSpec = &instancegroup.PrimaryAddressSpec{}
+		}
+		if len(spec.SecurityGroupIds) == 0 && (len(cfg.SecurityGroups) > 0 || len(cfg.SecurityGroupPresets) > 0) {
+			if spec.SecurityGroupIds, err = c.securityGroupIDs(ctx, cfg, subnetIDs[0]); err != nil {
+				return nil, err
+			}
+		}
+		tpl.NetworkInterfaceSpecs = append(tpl.NetworkInterfaceSpecs, spec)
+	}
+
+	if len(cfg.ServiceAccount) > 0 {
+		tpl.ServiceAccountId, err = c.IAMServiceAccountGetIdByName(ctx, cfg.FolderID, cfg.ServiceAccount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tpl, nil
+}
+
+// subnetsNetwork returns the network of the subnets, which must all be in the same one.
+func (c *Client) subnetsNetwork(ctx context.Context, subnetIDs []string) (string, error) {
+	var networkID string
+	for _, id := range subnetIDs {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		subnet, err := c.backend.Subnet().Get(cctx, &vpc.GetSubnetRequest{SubnetId: id})
+		cancel()
+		if err != nil {
+			return "", err
+		}
+
+		if len(networkID) > 0 && subnet.NetworkId != networkID {
+			return "", fmt.Errorf("subnets %v are in different networks", subnetIDs)
+		}
+		networkID = subnet.NetworkId
+	}
+
+	return networkID, nil
+}
+
+// ComputeInstanceGroupCreate creates the instance group. The instances get the labels of cfg.Instance too.
+func (c *Client) ComputeInstanceGroupCreate(ctx context.Context, cfg *InstanceGroupConfig) (*operation.Operation, error) {
+	// The defaults are applied to a copy, the config of the caller is left as is.
+	icfg := *cfg.Instance
+	icfg.setDefaults()
+
+	zones := cfg.Zones
+	if len(zones) == 0 {
+		zones = []string{icfg.Zone}
+	}
+	if cfg.MaxUnavailable == 0 && cfg.MaxExpansion == 0 {
+		return nil, errors.New("max unavailable and max expansion can't both be 0")
+	}
+
+	sa := cfg.ServiceAccount
+	if len(sa) == 0 {
+		sa = icfg.ServiceAccount
+	}
+	if len(sa) == 0 {
+		return nil, errors.New("instance group requires a service account to manage its instances")
+	}
+	saID, err := c.IAMServiceAccountGetIdByName(ctx, icfg.FolderID, sa)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := c.instanceTemplate(ctx, &icfg, zones)
+	if err != nil {
+		return nil, err
+	}
+
+	allocation := &instancegroup.AllocationPolicy{}
+	for _, zone := range zones {
+		allocation.Zones = append(allocation.Zones, &instancegroup.AllocationPolicy_Zone{ZoneId: zone})
+	}
+
+	request := &instancegroup.CreateInstanceGroupRequest{
+		FolderId:         icfg.FolderID,
+		Name:             icfg.Name,
+		Labels:           icfg.Labels,
+		InstanceTemplate: tpl,
+		ScalePolicy:      InstanceGroupScalePolicy(cfg.Size, cfg.AutoScale),
+		DeployPolicy: &instancegroup.DeployPolicy{
+			MaxUnavailable: cfg.MaxUnavailable,
+			MaxExpansion:   cfg.MaxExpansion,
+		},
+		AllocationPolicy: allocation,
+		ServiceAccountId: saID,
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.InstanceGroup().Create(cctx, request))
+}
+
+// ComputeInstanceGroupDelete deletes the instance group with its instances.
+func (c *Client) ComputeInstanceGroupDelete(ctx context.Context, id string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	op := &instancegroup.DeleteInstanceGroupRequest{InstanceGroupId: id}
+	return c.wrapOperation(c.backend.InstanceGroup().Delete(cctx, op))
+}
+
+// ComputeInstanceGroupGet returns the instance group including its instance template.
+func (c *Client) ComputeInstanceGroupGet(ctx context.Context, id string) (*instancegroup.InstanceGroup, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.backend.InstanceGroup().Get(cctx, &instancegroup.GetInstanceGroupRequest{
+		InstanceGroupId: id,
+		View:            instancegroup.InstanceGroupView_FULL,
+	})
+}
+
+// ComputeInstanceGroupList returns all instance groups of the folder which have the labels.
+func (c *Client) ComputeInstanceGroupList(
+	ctx context.Context,
+	folderID string,
+	lbl map[string]string,
+) ([]*instancegroup.InstanceGroup, error) {
+	filter, err := joinFilters(LabelFilters(lbl))
+	if err != nil {
+		return nil, err
+	}
+	req := &instancegroup.ListInstanceGroupsRequest{
+		FolderId: folderID,
+		PageSize: listPageSize,
+		Filter:   filter,
+	}
+
+	var out []*instancegroup.InstanceGroup
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.InstanceGroup().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.InstanceGroups...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// ComputeInstanceGroupResolve finds the instance group of the folder referenced by name or ID.
+func (c *Client) ComputeInstanceGroupResolve(ctx context.Context, folderID, ref string) (*instancegroup.InstanceGroup, error) {
+	lst, err := c.ComputeInstanceGroupList(ctx, folderID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOne("instance group", folderID, ref, lst, func(g *instancegroup.InstanceGroup) (string, string) {
+		return g.Id, g.Name
+	})
+}
+
+// ComputeInstanceGroupScale replaces the scale policy of the instance group.
+func (c *Client) ComputeInstanceGroupScale(
+	ctx context.Context,
+	id string,
+	policy *instancegroup.ScalePolicy,
+) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.InstanceGroup().Update(cctx, &instancegroup.UpdateInstanceGroupRequest{
+		InstanceGroupId: id,
+		UpdateMask:      &fieldmaskpb.FieldMask{Paths: []string{"scale_policy"}},
+		ScalePolicy:     policy,
+	}))
+}
+
+// ComputeInstanceGroupInstances returns the managed instances of the instance group.
+func (c *Client) ComputeInstanceGroupInstances(ctx context.Context, id string) ([]*instancegroup.ManagedInstance, error) {
+	req := &instancegroup.ListInstanceGroupInstancesRequest{InstanceGroupId: id, PageSize: listPageSize}
+
+	var out []*instancegroup.ManagedInstance
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.InstanceGroup().ListInstances(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Instances...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
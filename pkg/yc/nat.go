@@ -0,0 +1,234 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ks-tool/ks/pkg/common"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const (
+	// NATGatewayName is the name of the NAT gateway of the folder created by ks.
+	NATGatewayName = "ks-nat"
+	// DefaultRoute is the destination of the route to the NAT gateway.
+	DefaultRoute = "0.0.0.0/0"
+)
+
+// NATRouteTableName returns the name of the route table to the NAT gateway in the network.
+func NATRouteTableName(networkID string) string {
+	return NATGatewayName + "-" + networkID
+}
+
+// NATGateway is a subnet routed to the internet through a NAT gateway.
+type NATGateway struct {
+	Gateway    *vpc.Gateway
+	RouteTable *vpc.RouteTable
+	Subnet     *vpc.Subnet
+}
+
+// VPCGatewayList returns all gateways of the folder.
+func (c *Client) VPCGatewayList(ctx context.Context, folderID string) ([]*vpc.Gateway, error) {
+	req := &vpc.ListGatewaysRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*vpc.Gateway
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.Gateway().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Gateways...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// VPCRouteTableList returns all route tables of the folder.
+func (c *Client) VPCRouteTableList(ctx context.Context, folderID string) ([]*vpc.RouteTable, error) {
+	req := &vpc.ListRouteTablesRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*vpc.RouteTable
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.RouteTable().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.RouteTables...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// VPCNATGatewayEnsure routes the egress traffic of the subnet through the NAT gateway of the folder.
+// The gateway NATGatewayName and the route table NATRouteTableName of the network are created if needed.
+// A route table the subnet already has gets the default route added, unless it routes it elsewhere.
+func (c *Client) VPCNATGatewayEnsure(ctx context.Context, folderID, subnetID string) (*NATGateway, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	subnet, err := c.backend.Subnet().Get(cctx, &vpc.GetSubnetRequest{SubnetId: subnetID})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	gateway, err := c.ensureNATGateway(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var table *vpc.RouteTable
+	if len(subnet.RouteTableId) > 0 {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		table, err = c.backend.RouteTable().Get(cctx, &vpc.GetRouteTableRequest{RouteTableId: subnet.RouteTableId})
+		cancel()
+	} else {
+		table, err = c.natRouteTable(ctx, folderID, subnet.NetworkId)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if table == nil {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		op, err := c.wrapOperation(c.backend.RouteTable().Create(cctx, &vpc.CreateRouteTableRequest{
+			FolderId:     folderID,
+			Name:         NATRouteTableName(subnet.NetworkId),
+			Description:  "Created by ks nat-gateway ensure",
+			Labels:       map[string]string{common.ManagedKey: KsToolKey},
+			NetworkId:    subnet.NetworkId,
+			StaticRoutes: []*vpc.StaticRoute{natRoute(gateway.Id)},
+		}))
+		cancel()
+		if table, err = operationResult[*vpc.RouteTable](ctx, op, err); err != nil {
+			return nil, fmt.Errorf("create route table: %w", err)
+		}
+	} else if table, err = c.ensureNATRoute(ctx, table, gateway.Id); err != nil {
+		return nil, err
+	}
+
+	if subnet.RouteTableId != table.Id {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		op, err := c.wrapOperation(c.backend.Subnet().Update(cctx, &vpc.UpdateSubnetRequest{
+			SubnetId:     subnet.Id,
+			UpdateMask:   &fieldmaskpb.FieldMask{Paths: []string{"route_table_id"}},
+			RouteTableId: table.Id,
+		}))
+		cancel()
+		if subnet, err = operationResult[*vpc.Subnet](ctx, op, err); err != nil {
+			return nil, fmt.Errorf("set route table of subnet %s: %w", subnetID, err)
+		}
+	}
+
+	return &NATGateway{Gateway: gateway, RouteTable: table, Subnet: subnet}, nil
+}
+
+// ensureNATGateway returns the gateway NATGatewayName managed by ks, creating it if needed.
+func (c *Client) ensureNATGateway(ctx context.Context, folderID string) (*vpc.Gateway, error) {
+	lst, err := c.VPCGatewayList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, gateway := range lst {
+		if gateway.Name == NATGatewayName {
+			return gateway, nil
+		}
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	op, err := c.wrapOperation(c.backend.Gateway().Create(cctx, &vpc.CreateGatewayRequest{
+		FolderId:    folderID,
+		Name:        NATGatewayName,
+		Description: "Created by ks nat-gateway ensure",
+		Labels:      map[string]string{common.ManagedKey: KsToolKey},
+		Gateway: &vpc.CreateGatewayRequest_SharedEgressGatewaySpec{
+			SharedEgressGatewaySpec: &vpc.SharedEgressGatewaySpec{},
+		},
+	}))
+	cancel()
+	gateway, err := operationResult[*vpc.Gateway](ctx, op, err)
+	if err != nil {
+		return nil, fmt.Errorf("create NAT gateway: %w", err)
+	}
+
+	return gateway, nil
+}
+
+// natRouteTable returns the route table NATRouteTableName of the network, or nil if there is none.
+func (c *Client) natRouteTable(ctx context.Context, folderID, networkID string) (*vpc.RouteTable, error) {
+	lst, err := c.VPCRouteTableList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range lst {
+		if table.NetworkId == networkID && table.Name == NATRouteTableName(networkID) {
+			return table, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ensureNATRoute adds the default route through the gateway to the table if it has no default route.
+func (c *Client) ensureNATRoute(ctx context.Context, table *vpc.RouteTable, gatewayID string) (*vpc.RouteTable, error) {
+	for _, route := range table.StaticRoutes {
+		if route.GetDestinationPrefix() != DefaultRoute {
+			continue
+		}
+		if route.GetGatewayId() == gatewayID {
+			return table, nil
+		}
+
+		next := route.GetGatewayId()
+		if len(next) == 0 {
+			next = route.GetNextHopAddress()
+		}
+		return nil, fmt.Errorf("route table %s already routes %s through %s", table.Name, DefaultRoute, next)
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	op, err := c.wrapOperation(c.backend.RouteTable().Update(cctx, &vpc.UpdateRouteTableRequest{
+		RouteTableId: table.Id,
+		UpdateMask:   &fieldmaskpb.FieldMask{Paths: []string{"static_routes"}},
+		StaticRoutes: append(table.StaticRoutes, natRoute(gatewayID)),
+	}))
+	cancel()
+	if table, err = operationResult[*vpc.RouteTable](ctx, op, err); err != nil {
+		return nil, fmt.Errorf("add default route: %w", err)
+	}
+
+	return table, nil
+}
+
+func natRoute(gatewayID string) *vpc.StaticRoute {
+	return &vpc.StaticRoute{
+		Destination: &vpc.StaticRoute_DestinationPrefix{DestinationPrefix: DefaultRoute},
+		NextHop:     &vpc.StaticRoute_GatewayId{GatewayId: gatewayID},
+	}
+}
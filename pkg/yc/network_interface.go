@@ -0,0 +1,118 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+)
+
+// NetworkInterface is a network interface of an instance to create.
+type NetworkInterface struct {
+	// SubnetID defaults to the first subnet in the zone of the instance.
+	SubnetID string
+	// IPv4 is the internal address, assigned from the subnet if empty.
+	IPv4 string
+	// NAT gives the interface a public address, NATAddress if it is not empty.
+	NAT        bool
+	NATAddress string
+	IPv6       bool
+	// IPv6Address is the internal IPv6 address, assigned from the subnet if empty.
+	IPv6Address      string
+	SecurityGroupIDs []string
+}
+
+// ParseNetworkInterface parses a comma separated list of key=value pairs, e.g.
+// `subnet=e9b...,nat=true,ipv4=10.0.0.5,security-group-ids=enp...,enp...,ipv6=true`.
+// Keys: subnet, ipv4, nat, nat-address, ipv6, ipv6-address and security-group-ids.
+// An item without "=" adds a value to the previous key, which must take a list.
+func ParseNetworkInterface(spec string) (NetworkInterface, error) {
+	var n NetworkInterface
+
+	var last string
+	for _, kv := range strings.Split(spec, ",") {
+		kv = strings.TrimSpace(kv)
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			if last != "security-group-ids" || len(kv) == 0 {
+				return n, fmt.Errorf("network interface %q: key=value expected, got %q", spec, kv)
+			}
+			key, value = last, kv
+		}
+		last = key
+
+		var err error
+		switch key {
+		case "subnet":
+			n.SubnetID = value
+		case "ipv4":
+			n.IPv4 = value
+			if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+				err = fmt.Errorf("IPv4 address expected")
+			}
+		case "nat":
+			n.NAT, err = strconv.ParseBool(value)
+		case "nat-address":
+			n.NAT, n.NATAddress = true, value
+			if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+				err = fmt.Errorf("IPv4 address expected")
+			}
+		case "ipv6":
+			n.IPv6, err = strconv.ParseBool(value)
+		case "ipv6-address":
+			n.IPv6, n.IPv6Address = true, value
+			if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+				err = fmt.Errorf("IPv6 address expected")
+			}
+		case "security-group-ids":
+			if len(value) > 0 {
+				n.SecurityGroupIDs = append(n.SecurityGroupIDs, value)
+			}
+		default:
+			err = fmt.Errorf("unknown key, allow: subnet, ipv4, nat, nat-address, ipv6, ipv6-address, security-group-ids")
+		}
+		if err != nil {
+			return n, fmt.Errorf("network interface %q: %s: %w", spec, key, err)
+		}
+	}
+
+	return n, nil
+}
+
+// Spec returns the network interface spec. The subnet must be set.
+func (n NetworkInterface) Spec() *compute.NetworkInterfaceSpec {
+	spec := &compute.NetworkInterfaceSpec{
+		SubnetId:             n.SubnetID,
+		PrimaryV4AddressSpec: &compute.PrimaryAddressSpec{Address: n.IPv4},
+		SecurityGroupIds:     n.SecurityGroupIDs,
+	}
+	if n.NAT {
+		spec.PrimaryV4AddressSpec.OneToOneNatSpec = &compute.OneToOneNatSpec{
+			IpVersion: compute.IpVersion_IPV4,
+			Address:   n.NATAddress,
+		}
+	}
+	if n.IPv6 {
+		spec.PrimaryV6AddressSpec = &compute.PrimaryAddressSpec{Address: n.IPv6Address}
+	}
+
+	return spec
+}
@@ -0,0 +1,216 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ks-tool/ks/pkg/remote"
+
+	"github.com/jedib0t/go-pretty/v6/progress"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// ProgressView is a BulkProgress showing a live tracker per task when f is a terminal
+// and logging finished tasks otherwise.
+type ProgressView struct {
+	liveView
+
+	action string
+}
+
+var _ BulkProgress = (*ProgressView)(nil)
+
+// NewProgressView starts rendering; call Stop when the run is over.
+func NewProgressView(f *os.File, action string) *ProgressView {
+	v := &ProgressView{action: action}
+	if v.start(f) {
+		v.pw.Style().Visibility.Value = false
+		v.pw.Style().Visibility.Percentage = false
+	}
+
+	return v
+}
+
+func (v *ProgressView) Start(task BulkTask) {
+	if v.pw == nil {
+		log.Debugf("%s %s (%s) ...", v.action, task.Name, task.ID)
+		return
+	}
+
+	v.track(task.ID, &progress.Tracker{Message: fmt.Sprintf("%s %s", v.action, task.Name)})
+}
+
+func (v *ProgressView) Done(res BulkResult) {
+	if v.pw == nil {
+		if res.Err != nil {
+			log.Errorf("%s %s (%s) failed: %s", v.action, res.Name, res.ID, res.Err)
+		} else {
+			log.Infof("%s %s (%s) done", v.action, res.Name, res.ID)
+		}
+		return
+	}
+
+	v.finish(res.ID, res.Err)
+}
+
+// CopyView shows a live byte counter per copied file when f is a terminal
+// and logs copied files otherwise.
+type CopyView struct {
+	liveView
+}
+
+// NewCopyView starts rendering; call Stop when the copy is over.
+func NewCopyView(f *os.File) *CopyView {
+	v := &CopyView{}
+	v.start(f)
+
+	return v
+}
+
+// For returns the progress of copying files from or to the named instance.
+func (v *CopyView) For(name string) remote.CopyProgress {
+	return copyProgress{view: v, prefix: name + ":"}
+}
+
+type copyProgress struct {
+	view   *CopyView
+	prefix string
+}
+
+func (p copyProgress) Start(name string, size int64) {
+	if p.view.pw == nil {
+		return
+	}
+
+	p.view.track(p.prefix+name, &progress.Tracker{
+		Message: p.prefix + name,
+		Total:   size,
+		Units:   progress.UnitsBytes,
+	})
+}
+
+func (p copyProgress) Add(name string, n int64) {
+	if t := p.view.tracker(p.prefix + name); t != nil {
+		t.Increment(n)
+	}
+}
+
+func (p copyProgress) Done(name string, err error) {
+	if p.view.pw == nil {
+		if err != nil {
+			log.Errorf("Copying %s%s failed: %s", p.prefix, name, err)
+		} else {
+			log.Infof("Copied %s%s", p.prefix, name)
+		}
+		return
+	}
+
+	p.view.finish(p.prefix+name, err)
+}
+
+const liveViewUpdateFrequency = 100 * time.Millisecond
+
+// liveView renders progress trackers while the output is a terminal.
+// Without a terminal pw is nil.
+type liveView struct {
+	mu       sync.Mutex
+	pw       progress.Writer
+	trackers map[string]*progress.Tracker
+	done     chan struct{}
+}
+
+func (v *liveView) start(f *os.File) bool {
+	if !term.IsTerminal(int(f.Fd())) {
+		return false
+	}
+
+	v.render(f)
+	return true
+}
+
+// render starts the render goroutine writing to w.
+func (v *liveView) render(w io.Writer) {
+	v.pw = progress.NewWriter()
+	v.pw.SetOutputWriter(w)
+	v.pw.SetUpdateFrequency(liveViewUpdateFrequency)
+	v.pw.SetStyle(progress.StyleDefault)
+	v.trackers = make(map[string]*progress.Tracker)
+	v.done = make(chan struct{})
+
+	go func() {
+		v.pw.Render()
+		close(v.done)
+	}()
+}
+
+func (v *liveView) track(key string, t *progress.Tracker) {
+	v.mu.Lock()
+	v.trackers[key] = t
+	v.mu.Unlock()
+
+	v.pw.AppendTracker(t)
+}
+
+func (v *liveView) tracker(key string) *progress.Tracker {
+	if v.pw == nil {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.trackers[key]
+}
+
+func (v *liveView) finish(key string, err error) {
+	t := v.tracker(key)
+	if t == nil {
+		return
+	}
+
+	if err != nil {
+		t.MarkAsErrored()
+	} else {
+		t.MarkAsDone()
+	}
+}
+
+// Stop renders the final state and stops the live view.
+// A stop before the render goroutine has started is lost by the progress writer, so it is repeated
+// every update until the render goroutine is done.
+func (v *liveView) Stop() {
+	if v.pw == nil {
+		return
+	}
+
+	ticker := time.NewTicker(liveViewUpdateFrequency)
+	defer ticker.Stop()
+	for {
+		v.pw.Stop()
+		select {
+		case <-v.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
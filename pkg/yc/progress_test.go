@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/progress"
+)
+
+// lockedBuffer is written by the render goroutine and read by the test.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+// stopWithin fails the test if Stop doesn't return in time.
+func stopWithin(t *testing.T, v *liveView, d time.Duration) {
+	t.Helper()
+
+	stopped := make(chan struct{})
+	go func() {
+		v.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(d):
+		t.Fatalf("Stop did not return within %s", d)
+	}
+}
+
+func TestLiveViewStop(t *testing.T) {
+	t.Run("no trackers", func(t *testing.T) {
+		var v liveView
+		v.render(&lockedBuffer{})
+		stopWithin(t, &v, 2*time.Second)
+	})
+
+	t.Run("not a terminal", func(t *testing.T) {
+		var v liveView
+		stopWithin(t, &v, time.Second)
+	})
+
+	t.Run("final state is rendered", func(t *testing.T) {
+		var (
+			v   liveView
+			out lockedBuffer
+		)
+		v.render(&out)
+		v.track("a", &progress.Tracker{Message: "Deleting web-1"})
+		v.track("b", &progress.Tracker{Message: "Deleting web-2"})
+		v.finish("a", nil)
+		v.finish("b", errors.New("boom"))
+		stopWithin(t, &v, 2*time.Second)
+
+		for _, want := range []string{"Deleting web-1", "Deleting web-2"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("output has no %q:\n%s", want, out.String())
+			}
+		}
+	})
+}
@@ -0,0 +1,454 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ks-tool/ks/pkg/common"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+)
+
+// SelfSecurityGroup is the predefined rule target matching the instances of the group itself.
+const SelfSecurityGroup = "self_security_group"
+
+// SecurityGroupRule is a rule of a security group. Zero ports mean any port.
+type SecurityGroupRule struct {
+	Description string
+	Direction   vpc.SecurityGroupRule_Direction
+	// Protocol is tcp, udp, icmp or any.
+	Protocol string
+	FromPort int64
+	ToPort   int64
+	// The target of the rule is one of CIDRs, SecurityGroupID and Self.
+	CIDRs           []string
+	SecurityGroupID string
+	Self            bool
+}
+
+var (
+	anywhere  = []string{"0.0.0.0/0"}
+	egressAll = SecurityGroupRule{
+		Description: "any outgoing traffic",
+		Direction:   vpc.SecurityGroupRule_EGRESS,
+		Protocol:    "any",
+		CIDRs:       anywhere,
+	}
+)
+
+// SecurityGroupPresets are the built-in rule sets by name.
+// Presets with self rules get a group per cluster, so self targets match the nodes of the cluster only.
+var SecurityGroupPresets = map[string][]SecurityGroupRule{
+	"ssh-only": {
+		{Description: "SSH", Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 22, ToPort: 22, CIDRs: anywhere},
+		egressAll,
+	},
+	"k8s-control-plane": {
+		{Description: "Kubernetes API", Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 6443, ToPort: 6443, CIDRs: anywhere},
+		{Description: "etcd", Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 2379, ToPort: 2380, Self: true},
+		{Description: "kubelet", Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 10250, ToPort: 10250, Self: true},
+		egressAll,
+	},
+	"k8s-worker": {
+		{Description: "kubelet", Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 10250, ToPort: 10250, Self: true},
+		{Description: "NodePort services", Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 30000, ToPort: 32767, CIDRs: anywhere},
+		egressAll,
+	},
+	"intra-cluster": {
+		{Description: "any traffic between cluster nodes", Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "any", Self: true},
+		egressAll,
+	},
+}
+
+// ClusterSecurityGroupPresets are attached to the nodes of a Kubernetes cluster by default.
+var ClusterSecurityGroupPresets = []string{"ssh-only", "k8s-control-plane", "intra-cluster"}
+
+// SecurityGroupPresetNames returns the sorted names of the presets for flag help.
+func SecurityGroupPresetNames() string {
+	names := make([]string, 0, len(SecurityGroupPresets))
+	for name := range SecurityGroupPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// ParsePortRange parses "22" or "2379-2380". An empty string or "any" means any port.
+func ParsePortRange(s string) (int64, int64, error) {
+	if len(s) == 0 || s == "any" {
+		return 0, 0, nil
+	}
+
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		to = from
+	}
+	fromPort, err1 := strconv.ParseInt(from, 10, 64)
+	toPort, err2 := strconv.ParseInt(to, 10, 64)
+	if err1 != nil || err2 != nil || fromPort < 1 || toPort > 65535 || fromPort > toPort {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+
+	return fromPort, toPort, nil
+}
+
+// Spec returns the rule spec.
+func (r SecurityGroupRule) Spec() *vpc.SecurityGroupRuleSpec {
+	spec := &vpc.SecurityGroupRuleSpec{
+		Description: r.Description,
+		Direction:   r.Direction,
+		Protocol:    &vpc.SecurityGroupRuleSpec_ProtocolName{ProtocolName: protocolName(r.Protocol)},
+	}
+	if r.FromPort > 0 {
+		spec.Ports = &vpc.PortRange{FromPort: r.FromPort, ToPort: r.ToPort}
+	}
+
+	switch {
+	case r.Self:
+		spec.Target = &vpc.SecurityGroupRuleSpec_PredefinedTarget{PredefinedTarget: SelfSecurityGroup}
+	case len(r.SecurityGroupID) > 0:
+		spec.Target = &vpc.SecurityGroupRuleSpec_SecurityGroupId{SecurityGroupId: r.SecurityGroupID}
+	default:
+		spec.Target = &vpc.SecurityGroupRuleSpec_CidrBlocks{CidrBlocks: &vpc.CidrBlocks{V4CidrBlocks: r.CIDRs}}
+	}
+
+	return spec
+}
+
+// String describes what the rule allows, e.g. "ingress TCP 2379-2380 from self".
+func (r SecurityGroupRule) String() string {
+	var ports string
+	if r.FromPort > 0 {
+		ports = " " + strconv.FormatInt(r.FromPort, 10)
+		if r.ToPort != r.FromPort {
+			ports += "-" + strconv.FormatInt(r.ToPort, 10)
+		}
+	}
+
+	dir, prep := "ingress", "from"
+	if r.Direction == vpc.SecurityGroupRule_EGRESS {
+		dir, prep = "egress", "to"
+	}
+
+	target := strings.Join(r.CIDRs, ",")
+	switch {
+	case r.Self:
+		target = "self"
+	case len(r.SecurityGroupID) > 0:
+		target = r.SecurityGroupID
+	}
+
+	return fmt.Sprintf("%s %s%s %s %s", dir, protocolName(r.Protocol), ports, prep, target)
+}
+
+// key identifies what the rule allows, ignoring the description.
+func (r SecurityGroupRule) key() string {
+	cidrs := append([]string(nil), r.CIDRs...)
+	sort.Strings(cidrs)
+	r.CIDRs = cidrs
+	r.Description = ""
+
+	return r.String()
+}
+
+// RuleFromProto converts a rule of a security group.
+func RuleFromProto(r *vpc.SecurityGroupRule) SecurityGroupRule {
+	out := SecurityGroupRule{
+		Description:     r.Description,
+		Direction:       r.Direction,
+		Protocol:        strings.ToLower(r.ProtocolName),
+		CIDRs:           r.GetCidrBlocks().GetV4CidrBlocks(),
+		SecurityGroupID: r.GetSecurityGroupId(),
+		Self:            r.GetPredefinedTarget() == SelfSecurityGroup,
+	}
+	if len(out.Protocol) == 0 && r.ProtocolNumber > 0 {
+		out.Protocol = strconv.FormatInt(r.ProtocolNumber, 10)
+	}
+	// The API may return the full range for a rule without ports.
+	if p := r.Ports; p != nil && !(p.FromPort <= 1 && p.ToPort == 65535) {
+		out.FromPort, out.ToPort = p.FromPort, p.ToPort
+	}
+
+	return out
+}
+
+func protocolName(p string) string {
+	if len(p) == 0 {
+		return "ANY"
+	}
+
+	return strings.ToUpper(p)
+}
+
+// SecurityGroupConfig describes a security group to create.
+type SecurityGroupConfig struct {
+	FolderID    string
+	NetworkID   string
+	Name        string
+	Description string
+	Labels      map[string]string
+	Rules       []SecurityGroupRule
+}
+
+func (c *Client) VPCSecurityGroupCreate(ctx context.Context, cfg *SecurityGroupConfig) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	specs := make([]*vpc.SecurityGroupRuleSpec, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		specs = append(specs, r.Spec())
+	}
+
+	return c.wrapOperation(c.backend.SecurityGroup().Create(cctx, &vpc.CreateSecurityGroupRequest{
+		FolderId:    cfg.FolderID,
+		NetworkId:   cfg.NetworkID,
+		Name:        cfg.Name,
+		Description: cfg.Description,
+		Labels:      cfg.Labels,
+		RuleSpecs:   specs,
+	}))
+}
+
+// VPCSecurityGroupDelete deletes the security group. Groups used by instances can't be deleted.
+func (c *Client) VPCSecurityGroupDelete(ctx context.Context, id string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.SecurityGroup().Delete(cctx, &vpc.DeleteSecurityGroupRequest{SecurityGroupId: id}))
+}
+
+// VPCSecurityGroupList returns all security groups of the folder.
+func (c *Client) VPCSecurityGroupList(ctx context.Context, folderID string) ([]*vpc.SecurityGroup, error) {
+	req := &vpc.ListSecurityGroupsRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*vpc.SecurityGroup
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.SecurityGroup().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.SecurityGroups...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// VPCSecurityGroupResolve finds the security group of the folder referenced by name or ID.
+func (c *Client) VPCSecurityGroupResolve(ctx context.Context, folderID, ref string) (*vpc.SecurityGroup, error) {
+	lst, err := c.VPCSecurityGroupList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOne("security group", folderID, ref, lst, func(g *vpc.SecurityGroup) (string, string) { return g.Id, g.Name })
+}
+
+// VPCSecurityGroupAddRules adds the rules the group has no equal rule for.
+// The operation is nil if the group has all of them.
+func (c *Client) VPCSecurityGroupAddRules(
+	ctx context.Context,
+	group *vpc.SecurityGroup,
+	rules []SecurityGroupRule,
+) (*operation.Operation, error) {
+	return c.updateSecurityGroupRules(ctx, group, rules, false)
+}
+
+// VPCSecurityGroupReconcile makes the rules of the group equal to rules, adding the missing ones
+// and deleting the others. The operation is nil if the group is in sync.
+func (c *Client) VPCSecurityGroupReconcile(
+	ctx context.Context,
+	group *vpc.SecurityGroup,
+	rules []SecurityGroupRule,
+) (*operation.Operation, error) {
+	return c.updateSecurityGroupRules(ctx, group, rules, true)
+}
+
+func (c *Client) updateSecurityGroupRules(
+	ctx context.Context,
+	group *vpc.SecurityGroup,
+	rules []SecurityGroupRule,
+	prune bool,
+) (*operation.Operation, error) {
+	want := make(map[string]bool)
+	for _, r := range rules {
+		want[r.key()] = true
+	}
+
+	req := &vpc.UpdateSecurityGroupRulesRequest{SecurityGroupId: group.Id}
+	have := make(map[string]bool)
+	for _, r := range group.Rules {
+		key := RuleFromProto(r).key()
+		if prune && (!want[key] || have[key]) {
+			req.DeletionRuleIds = append(req.DeletionRuleIds, r.Id)
+		}
+		have[key] = true
+	}
+	for _, r := range rules {
+		if key := r.key(); !have[key] {
+			req.AdditionRuleSpecs = append(req.AdditionRuleSpecs, r.Spec())
+			have[key] = true
+		}
+	}
+	if len(req.DeletionRuleIds) == 0 && len(req.AdditionRuleSpecs) == 0 {
+		return nil, nil
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.SecurityGroup().UpdateRules(cctx, req))
+}
+
+// PresetPerCluster reports whether the preset has self rules and so gets a group per cluster.
+func PresetPerCluster(preset string) bool {
+	for _, r := range SecurityGroupPresets[preset] {
+		if r.Self {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PresetSecurityGroupName returns the name of the group of the preset in the network,
+// or of the cluster for a preset per cluster.
+func PresetSecurityGroupName(preset, networkID, cluster string) string {
+	if len(cluster) > 0 {
+		return "ks-" + cluster + "-" + preset
+	}
+
+	return "ks-" + preset + "-" + networkID
+}
+
+// PresetSecurityGroupConfig describes the group of a preset to ensure.
+type PresetSecurityGroupConfig struct {
+	FolderID  string
+	NetworkID string
+	Preset    string
+	// Cluster is required for a preset per cluster and ignored otherwise.
+	Cluster string
+	// Prune deletes the rules of an existing group which are not in the preset.
+	Prune bool
+}
+
+// VPCSecurityGroupEnsurePreset returns the group of the preset in the network, creating it if needed.
+// The missing rules of the preset are added to an existing group. With Prune the other rules,
+// e.g. added with security-group add-rule, are deleted.
+func (c *Client) VPCSecurityGroupEnsurePreset(ctx context.Context, cfg *PresetSecurityGroupConfig) (*vpc.SecurityGroup, error) {
+	rules, ok := SecurityGroupPresets[cfg.Preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown security group preset %q, allow: %s", cfg.Preset, SecurityGroupPresetNames())
+	}
+
+	labels := map[string]string{
+		common.ManagedKey:               KsToolKey,
+		common.LabelSecurityGroupPreset: cfg.Preset,
+	}
+	var cluster string
+	if PresetPerCluster(cfg.Preset) {
+		if cluster = cfg.Cluster; len(cluster) == 0 {
+			return nil, fmt.Errorf("security group preset %s is per cluster, the cluster is required", cfg.Preset)
+		}
+		labels[common.LabelClusterNameKey] = cluster
+	}
+
+	lst, err := c.VPCSecurityGroupList(ctx, cfg.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range lst {
+		if group.NetworkId != cfg.NetworkID ||
+			group.Labels[common.LabelSecurityGroupPreset] != cfg.Preset ||
+			group.Labels[common.LabelClusterNameKey] != cluster {
+			continue
+		}
+
+		op, err := c.updateSecurityGroupRules(ctx, group, rules, cfg.Prune)
+		if err != nil || op == nil {
+			return group, err
+		}
+
+		return operationResult[*vpc.SecurityGroup](ctx, op, nil)
+	}
+
+	op, err := c.VPCSecurityGroupCreate(ctx, &SecurityGroupConfig{
+		FolderID:    cfg.FolderID,
+		NetworkID:   cfg.NetworkID,
+		Name:        PresetSecurityGroupName(cfg.Preset, cfg.NetworkID, cluster),
+		Description: "ks preset " + cfg.Preset,
+		Labels:      labels,
+		Rules:       rules,
+	})
+
+	return operationResult[*vpc.SecurityGroup](ctx, op, err)
+}
+
+// securityGroupIDs resolves the security groups and presets of the instance in the network of the subnet.
+// The groups of presets per cluster are those of the cluster label of the instance, or of its name.
+func (c *Client) securityGroupIDs(ctx context.Context, cfg *ComputeInstanceConfig, subnetID string) ([]string, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	subnet, err := c.backend.Subnet().Get(cctx, &vpc.GetSubnetRequest{SubnetId: subnetID})
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, ref := range cfg.SecurityGroups {
+		group, err := c.VPCSecurityGroupResolve(ctx, cfg.FolderID, ref)
+		if err != nil {
+			return nil, err
+		}
+		if group.NetworkId != subnet.NetworkId {
+			return nil, fmt.Errorf("security group %s is not in network %s of subnet %s", ref, subnet.NetworkId, subnet.Id)
+		}
+		ids = append(ids, group.Id)
+	}
+
+	// A standalone instance or instance group is a cluster of its own.
+	cluster := cfg.Labels[common.LabelClusterNameKey]
+	if len(cluster) == 0 {
+		cluster = cfg.Name
+	}
+	for _, preset := range cfg.SecurityGroupPresets {
+		group, err := c.VPCSecurityGroupEnsurePreset(ctx, &PresetSecurityGroupConfig{
+			FolderID:  cfg.FolderID,
+			NetworkID: subnet.NetworkId,
+			Preset:    preset,
+			Cluster:   cluster,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("security group preset %s: %w", preset, err)
+		}
+		ids = append(ids, group.Id)
+	}
+
+	return ids, nil
+}
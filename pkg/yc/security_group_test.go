@@ -0,0 +1,115 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+)
+
+// apiRule returns the rule as the API returns it for the spec.
+func apiRule(spec *vpc.SecurityGroupRuleSpec) *vpc.SecurityGroupRule {
+	r := &vpc.SecurityGroupRule{
+		Id:           "rule",
+		Description:  spec.Description,
+		Direction:    spec.Direction,
+		Ports:        spec.Ports,
+		ProtocolName: spec.GetProtocolName(),
+	}
+	switch t := spec.Target.(type) {
+	case *vpc.SecurityGroupRuleSpec_CidrBlocks:
+		r.Target = &vpc.SecurityGroupRule_CidrBlocks{CidrBlocks: t.CidrBlocks}
+	case *vpc.SecurityGroupRuleSpec_SecurityGroupId:
+		r.Target = &vpc.SecurityGroupRule_SecurityGroupId{SecurityGroupId: t.SecurityGroupId}
+	case *vpc.SecurityGroupRuleSpec_PredefinedTarget:
+		r.Target = &vpc.SecurityGroupRule_PredefinedTarget{PredefinedTarget: t.PredefinedTarget}
+	}
+
+	return r
+}
+
+func TestRuleFromProtoKey(t *testing.T) {
+	for name, rules := range SecurityGroupPresets {
+		for _, rule := range rules {
+			if got := RuleFromProto(apiRule(rule.Spec())).key(); got != rule.key() {
+				t.Errorf("preset %s: got key %q, want %q", name, got, rule.key())
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		rule SecurityGroupRule
+		api  *vpc.SecurityGroupRule
+	}{
+		{
+			name: "any port as full range",
+			rule: SecurityGroupRule{Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "any", Self: true},
+			api: &vpc.SecurityGroupRule{
+				Direction:    vpc.SecurityGroupRule_INGRESS,
+				ProtocolName: "ANY",
+				Ports:        &vpc.PortRange{FromPort: 0, ToPort: 65535},
+				Target:       &vpc.SecurityGroupRule_PredefinedTarget{PredefinedTarget: SelfSecurityGroup},
+			},
+		},
+		{
+			name: "any port as 1-65535",
+			rule: SecurityGroupRule{Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", CIDRs: anywhere},
+			api: &vpc.SecurityGroupRule{
+				Direction:    vpc.SecurityGroupRule_INGRESS,
+				ProtocolName: "TCP",
+				Ports:        &vpc.PortRange{FromPort: 1, ToPort: 65535},
+				Target:       &vpc.SecurityGroupRule_CidrBlocks{CidrBlocks: &vpc.CidrBlocks{V4CidrBlocks: anywhere}},
+			},
+		},
+		{
+			name: "CIDRs in another order",
+			rule: SecurityGroupRule{
+				Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 22, ToPort: 22,
+				CIDRs: []string{"10.0.0.0/8", "192.168.0.0/16"},
+			},
+			api: &vpc.SecurityGroupRule{
+				Description:  "SSH from the office",
+				Direction:    vpc.SecurityGroupRule_INGRESS,
+				ProtocolName: "TCP",
+				Ports:        &vpc.PortRange{FromPort: 22, ToPort: 22},
+				Target: &vpc.SecurityGroupRule_CidrBlocks{
+					CidrBlocks: &vpc.CidrBlocks{V4CidrBlocks: []string{"192.168.0.0/16", "10.0.0.0/8"}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := RuleFromProto(tt.api).key(); got != tt.rule.key() {
+			t.Errorf("%s: got key %q, want %q", tt.name, got, tt.rule.key())
+		}
+	}
+
+	ssh := SecurityGroupRule{Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 22, ToPort: 22, CIDRs: anywhere}
+	for _, other := range []SecurityGroupRule{
+		{Direction: vpc.SecurityGroupRule_EGRESS, Protocol: "tcp", FromPort: 22, ToPort: 22, CIDRs: anywhere},
+		{Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "udp", FromPort: 22, ToPort: 22, CIDRs: anywhere},
+		{Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 22, ToPort: 23, CIDRs: anywhere},
+		{Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", FromPort: 22, ToPort: 22, Self: true},
+		{Direction: vpc.SecurityGroupRule_INGRESS, Protocol: "tcp", CIDRs: anywhere},
+	} {
+		if other.key() == ssh.key() {
+			t.Errorf("%s has the key of %s", other, ssh)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+)
+
+type SelectorOperator string
+
+const (
+	SelectorEq        SelectorOperator = "="
+	SelectorNe        SelectorOperator = "!="
+	SelectorIn        SelectorOperator = "in"
+	SelectorNotIn     SelectorOperator = "notin"
+	SelectorMatch     SelectorOperator = "=~"
+	SelectorNotMatch  SelectorOperator = "!~"
+	SelectorExists    SelectorOperator = "exists"
+	SelectorNotExists SelectorOperator = "!"
+)
+
+// Fields which can be used in field requirements, see instanceField.
+var SelectorFields = []string{"id", "name", "status", "zone", "platform", "subnet", "ip", "internal-ip", "fqdn", "sa"}
+
+// apiFields are instance fields the List API can filter on, by selector field name.
+var apiFields = map[string]string{
+	"id":     "id",
+	"name":   "name",
+	"status": "status",
+}
+
+// Requirement is a single condition on a label or a field of an instance.
+type Requirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+
+	re *regexp.Regexp
+}
+
+// Selector selects instances by labels and fields. All requirements must match.
+type Selector struct {
+	Labels []Requirement
+	Fields []Requirement
+}
+
+// ParseLabelSelector parses comma separated label requirements:
+//
+//	env=prod,role!=db  tier in (web,api)  tier notin (db)  name=~^web-  gpu  !gpu
+func ParseLabelSelector(s string) ([]Requirement, error) {
+	var out []Requirement
+	for _, term := range splitTerms(s) {
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("label selector %q: %w", s, err)
+		}
+		out = append(out, r)
+	}
+
+	return out, nil
+}
+
+// ParseFieldFilter parses a single field requirement, e.g. `name=~^web-` or `zone=ru-central1-a`.
+// Values are not split on commas, so regular expressions may contain them.
+func ParseFieldFilter(s string) (Requirement, error) {
+	r, err := parseRequirement(s)
+	if err != nil {
+		return r, fmt.Errorf("filter %q: %w", s, err)
+	}
+	if !slices.Contains(SelectorFields, r.Key) {
+		return r, fmt.Errorf("filter %q: unknown field %q, allow: %s", s, r.Key, strings.Join(SelectorFields, ", "))
+	}
+	if r.Operator == SelectorExists || r.Operator == SelectorNotExists {
+		return r, fmt.Errorf("filter %q: operator expected", s)
+	}
+	if r.Key == "status" {
+		for i, v := range r.Values {
+			r.Values[i] = strings.ToUpper(v)
+		}
+	}
+
+	return r, nil
+}
+
+func splitTerms(s string) []string {
+	var (
+		out   []string
+		depth int
+		start int
+	)
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	out = append(out, s[start:])
+
+	terms := out[:0]
+	for _, t := range out {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			terms = append(terms, t)
+		}
+	}
+
+	return terms
+}
+
+// comparisonOperators are the operators between a key and a value, the two-character ones first.
+var comparisonOperators = []SelectorOperator{SelectorNotMatch, SelectorMatch, SelectorNe, "==", SelectorEq}
+
+// cutOperator splits s at the leftmost operator, the longest one at that position,
+// so that the value may contain operators, e.g. `env=a!=b` is env = "a!=b".
+func cutOperator(s string) (key string, op SelectorOperator, value string, ok bool) {
+	for i := 0; i < len(s); i++ {
+		for _, o := range comparisonOperators {
+			if strings.HasPrefix(s[i:], string(o)) {
+				return s[:i], o, s[i+len(o):], true
+			}
+		}
+	}
+
+	return "", "", "", false
+}
+
+func parseRequirement(s string) (Requirement, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return Requirement{}, fmt.Errorf("empty requirement")
+	}
+
+	if key, ok := strings.CutPrefix(s, "!"); ok {
+		return Requirement{Key: strings.TrimSpace(key), Operator: SelectorNotExists}, nil
+	}
+
+	if key, op, value, ok := cutOperator(s); ok {
+		if op == "==" {
+			op = SelectorEq
+		}
+
+		r := Requirement{Key: strings.TrimSpace(key), Operator: op, Values: []string{strings.TrimSpace(value)}}
+		if len(r.Key) == 0 {
+			return r, fmt.Errorf("key expected in %q", s)
+		}
+		if op == SelectorMatch || op == SelectorNotMatch {
+			re, err := regexp.Compile(r.Values[0])
+			if err != nil {
+				return r, err
+			}
+			r.re = re
+		}
+
+		return r, nil
+	}
+
+	if fields := strings.Fields(s); len(fields) >= 2 {
+		op := SelectorOperator(strings.ToLower(fields[1]))
+		if op == SelectorIn || op == SelectorNotIn {
+			key := fields[0]
+			list := strings.TrimSpace(strings.Join(fields[2:], " "))
+			if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+				return Requirement{}, fmt.Errorf("(value, ...) expected in %q", s)
+			}
+
+			r := Requirement{Key: key, Operator: op}
+			for _, v := range strings.Split(list[1:len(list)-1], ",") {
+				if v = strings.TrimSpace(v); len(v) > 0 {
+					r.Values = append(r.Values, v)
+				}
+			}
+			if len(r.Values) == 0 {
+				return r, fmt.Errorf("empty value list in %q", s)
+			}
+
+			return r, nil
+		}
+
+		return Requirement{}, fmt.Errorf("unknown operator %q", fields[1])
+	}
+
+	return Requirement{Key: s, Operator: SelectorExists}, nil
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case SelectorExists:
+		return r.Key
+	case SelectorNotExists:
+		return "!" + r.Key
+	case SelectorIn, SelectorNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+
+	return r.Key + string(r.Operator) + r.Values[0]
+}
+
+// Matches reports whether the value satisfies the requirement. ok tells whether the value is present.
+func (r Requirement) Matches(value string, ok bool) bool {
+	switch r.Operator {
+	case SelectorExists:
+		return ok
+	case SelectorNotExists:
+		return !ok
+	case SelectorEq, SelectorIn:
+		return ok && slices.Contains(r.Values, value)
+	case SelectorNe, SelectorNotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	case SelectorMatch:
+		return ok && r.re.MatchString(value)
+	case SelectorNotMatch:
+		return !ok || !r.re.MatchString(value)
+	}
+
+	return false
+}
+
+// Matches reports whether the instance satisfies all requirements.
+func (s Selector) Matches(i *compute.Instance) bool {
+	for _, r := range s.Labels {
+		v, ok := i.Labels[r.Key]
+		if !r.Matches(v, ok) {
+			return false
+		}
+	}
+	for _, r := range s.Fields {
+		v, ok := instanceField(i, r.Key)
+		if !r.Matches(v, ok) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Empty reports whether the selector has no requirements.
+func (s Selector) Empty() bool {
+	return len(s.Labels) == 0 && len(s.Fields) == 0
+}
+
+// Split returns the API filters for the requirements the List API can evaluate
+// and a selector with the rest, which has to be evaluated locally.
+// Negative label requirements stay local: unlike the API, they match instances without the label.
+func (s Selector) Split() ([]Filter, Selector) {
+	var (
+		filters []Filter
+		local   Selector
+	)
+
+	for _, r := range s.Labels {
+		if f, ok := r.filter("labels." + r.Key); ok && (r.Operator == SelectorEq || r.Operator == SelectorIn) {
+			filters = append(filters, f)
+		} else {
+			local.Labels = append(local.Labels, r)
+		}
+	}
+	for _, r := range s.Fields {
+		if field, ok := apiFields[r.Key]; ok {
+			if f, ok := r.filter(field); ok {
+				filters = append(filters, f)
+				continue
+			}
+		}
+		local.Fields = append(local.Fields, r)
+	}
+
+	return filters, local
+}
+
+func (r Requirement) filter(field string) (Filter, bool) {
+	switch r.Operator {
+	case SelectorEq:
+		return Filter{Field: field, Operator: OperatorEq, Value: r.Values[0]}, true
+	case SelectorNe:
+		return Filter{Field: field, Operator: OperatorNe, Value: r.Values[0]}, true
+	case SelectorIn:
+		return Filter{Field: field, Operator: OperatorIn, Value: r.Values}, true
+	case SelectorNotIn:
+		return Filter{Field: field, Operator: OperatorNotIn, Value: r.Values}, true
+	}
+
+	return Filter{}, false
+}
+
+func instanceField(i *compute.Instance, field string) (string, bool) {
+	var v string
+	switch field {
+	case "id":
+		v = i.Id
+	case "name":
+		v = i.Name
+	case "status":
+		v = i.Status.String()
+	case "zone":
+		v = i.ZoneId
+	case "platform":
+		v = i.PlatformId
+	case "subnet":
+		v = firstSubnetID(i)
+	case "ip":
+		v = GetIPv4(i).External()
+	case "internal-ip":
+		v = GetIPv4(i).Internal()
+	case "fqdn":
+		v = i.Fqdn
+	case "sa":
+		v = i.ServiceAccountId
+	}
+
+	return v, len(v) > 0
+}
+
+// ComputeInstanceSelect returns the instances of the folder matching the selector.
+// Requirements supported by the API are pushed into the list filter, the rest are evaluated locally.
+func (c *Client) ComputeInstanceSelect(ctx context.Context, folderID string, sel Selector) ([]*compute.Instance, error) {
+	filters, local := sel.Split()
+
+	var out []*compute.Instance
+	it := c.ComputeInstanceIterator(ctx, folderID, nil, filters...)
+	for it.Next() {
+		if local.Matches(it.Value()) {
+			out = append(out, it.Value())
+		}
+	}
+
+	return out, it.Error()
+}
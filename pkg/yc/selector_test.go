@@ -0,0 +1,279 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+)
+
+// stripRegexp drops the compiled expressions, so that requirements can be compared with reflect.DeepEqual.
+func stripRegexp(reqs []Requirement) []Requirement {
+	var out []Requirement
+	for _, r := range reqs {
+		r.re = nil
+		out = append(out, r)
+	}
+
+	return out
+}
+
+func mustLabelSelector(t *testing.T, s string) []Requirement {
+	t.Helper()
+
+	reqs, err := ParseLabelSelector(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reqs
+}
+
+func mustFieldFilter(t *testing.T, s string) Requirement {
+	t.Helper()
+
+	r, err := ParseFieldFilter(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []Requirement
+		wantErr string
+	}{
+		{in: "env=prod", want: []Requirement{{Key: "env", Operator: SelectorEq, Values: []string{"prod"}}}},
+		{in: "env==prod", want: []Requirement{{Key: "env", Operator: SelectorEq, Values: []string{"prod"}}}},
+		{in: " role != db ", want: []Requirement{{Key: "role", Operator: SelectorNe, Values: []string{"db"}}}},
+		{in: "tier in (web, api)", want: []Requirement{{Key: "tier", Operator: SelectorIn, Values: []string{"web", "api"}}}},
+		{in: "tier NOTIN (db)", want: []Requirement{{Key: "tier", Operator: SelectorNotIn, Values: []string{"db"}}}},
+		{in: "name=~^web-", want: []Requirement{{Key: "name", Operator: SelectorMatch, Values: []string{"^web-"}}}},
+		{in: "name!~-old$", want: []Requirement{{Key: "name", Operator: SelectorNotMatch, Values: []string{"-old$"}}}},
+		{in: "env=a!=b", want: []Requirement{{Key: "env", Operator: SelectorEq, Values: []string{"a!=b"}}}},
+		{in: "env!=a=b", want: []Requirement{{Key: "env", Operator: SelectorNe, Values: []string{"a=b"}}}},
+		{in: "env==a=b", want: []Requirement{{Key: "env", Operator: SelectorEq, Values: []string{"a=b"}}}},
+		{in: "name=~^a=b", want: []Requirement{{Key: "name", Operator: SelectorMatch, Values: []string{"^a=b"}}}},
+		{in: "name!~a!=b", want: []Requirement{{Key: "name", Operator: SelectorNotMatch, Values: []string{"a!=b"}}}},
+		{in: "name=!~x", want: []Requirement{{Key: "name", Operator: SelectorEq, Values: []string{"!~x"}}}},
+		{in: "gpu", want: []Requirement{{Key: "gpu", Operator: SelectorExists}}},
+		{in: "!gpu", want: []Requirement{{Key: "gpu", Operator: SelectorNotExists}}},
+		{
+			in: "env=prod,tier in (web,api),!gpu",
+			want: []Requirement{
+				{Key: "env", Operator: SelectorEq, Values: []string{"prod"}},
+				{Key: "tier", Operator: SelectorIn, Values: []string{"web", "api"}},
+				{Key: "gpu", Operator: SelectorNotExists},
+			},
+		},
+		{in: "", want: nil},
+		{in: "=prod", wantErr: "key expected"},
+		{in: "tier in web", wantErr: "(value, ...) expected"},
+		{in: "tier in ()", wantErr: "empty value list"},
+		{in: "tier within (web)", wantErr: "unknown operator"},
+		{in: "name=~(", wantErr: "missing closing )"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLabelSelector(tt.in)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got = stripRegexp(got); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldFilter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Requirement
+		wantErr string
+	}{
+		{in: "zone=ru-central1-a", want: Requirement{Key: "zone", Operator: SelectorEq, Values: []string{"ru-central1-a"}}},
+		{in: "status=running", want: Requirement{Key: "status", Operator: SelectorEq, Values: []string{"RUNNING"}}},
+		{in: "status in (running,stopped)", want: Requirement{Key: "status", Operator: SelectorIn, Values: []string{"RUNNING", "STOPPED"}}},
+		{in: "name=~^web-(1,2)", want: Requirement{Key: "name", Operator: SelectorMatch, Values: []string{"^web-(1,2)"}}},
+		{in: "name=~^a=b", want: Requirement{Key: "name", Operator: SelectorMatch, Values: []string{"^a=b"}}},
+		{in: "name!=a=b", want: Requirement{Key: "name", Operator: SelectorNe, Values: []string{"a=b"}}},
+		{in: "color=red", wantErr: "unknown field"},
+		{in: "name", wantErr: "operator expected"},
+		{in: "!ip", wantErr: "operator expected"},
+		{in: "", wantErr: "empty requirement"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFieldFilter(tt.in)
+			if len(tt.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.re = nil; !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequirementMatches(t *testing.T) {
+	tests := []struct {
+		req   string
+		value string
+		ok    bool
+		want  bool
+	}{
+		{req: "env=prod", value: "prod", ok: true, want: true},
+		{req: "env=prod", value: "dev", ok: true, want: false},
+		{req: "env=prod", want: false},
+		{req: "env!=prod", value: "dev", ok: true, want: true},
+		{req: "env!=prod", want: true},
+		{req: "env!=prod", value: "prod", ok: true, want: false},
+		{req: "env in (prod,stage)", value: "stage", ok: true, want: true},
+		{req: "env in (prod,stage)", want: false},
+		{req: "env notin (prod,stage)", value: "dev", ok: true, want: true},
+		{req: "env notin (prod,stage)", want: true},
+		{req: "env notin (prod,stage)", value: "prod", ok: true, want: false},
+		{req: "env=~^pr", value: "prod", ok: true, want: true},
+		{req: "env!~^pr", value: "prod", ok: true, want: false},
+		{req: "env!~^pr", want: true},
+		{req: "env", value: "", ok: true, want: true},
+		{req: "env", want: false},
+		{req: "!env", want: true},
+		{req: "!env", value: "prod", ok: true, want: false},
+	}
+	for _, tt := range tests {
+		r := mustLabelSelector(t, tt.req)[0]
+		if got := r.Matches(tt.value, tt.ok); got != tt.want {
+			t.Errorf("%s matches (%q, %t) = %t, want %t", tt.req, tt.value, tt.ok, got, tt.want)
+		}
+	}
+}
+
+func TestSelectorSplit(t *testing.T) {
+	sel := Selector{
+		Labels: mustLabelSelector(t, "env=prod,tier in (web,api),role!=db,tier notin (db),gpu,!spot,name=~^web"),
+		Fields: []Requirement{
+			mustFieldFilter(t, "name=web-1"),
+			mustFieldFilter(t, "id!=fhm1"),
+			mustFieldFilter(t, "status in (running)"),
+			mustFieldFilter(t, "name=~^web-"),
+			mustFieldFilter(t, "zone=ru-central1-a"),
+			mustFieldFilter(t, "ip=1.2.3.4"),
+		},
+	}
+
+	filters, local := sel.Split()
+
+	var got []string
+	for _, f := range filters {
+		got = append(got, f.String())
+	}
+	want := []string{
+		`labels.env = "prod"`,
+		`labels.tier IN ("web", "api")`,
+		`name = "web-1"`,
+		`id != "fhm1"`,
+		`status IN ("RUNNING")`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("API filters:\n got %q\nwant %q", got, want)
+	}
+
+	var gotLocal []string
+	for _, r := range append(local.Labels, local.Fields...) {
+		gotLocal = append(gotLocal, r.String())
+	}
+	wantLocal := []string{
+		"role!=db", "tier notin (db)", "gpu", "!spot", "name=~^web",
+		"name=~^web-", "zone=ru-central1-a", "ip=1.2.3.4",
+	}
+	if !reflect.DeepEqual(gotLocal, wantLocal) {
+		t.Fatalf("local requirements:\n got %q\nwant %q", gotLocal, wantLocal)
+	}
+
+	if filters, local = (Selector{}).Split(); len(filters) > 0 || !local.Empty() {
+		t.Fatalf("empty selector split into %v and %+v", filters, local)
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	instance := &compute.Instance{
+		Id:         "fhm1",
+		Name:       "web-1",
+		Status:     compute.Instance_RUNNING,
+		ZoneId:     "ru-central1-a",
+		PlatformId: "standard-v3",
+		Labels:     map[string]string{"env": "prod", "tier": "web"},
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			SubnetId: "e9b1",
+			PrimaryV4Address: &compute.PrimaryAddress{
+				Address:     "10.0.0.5",
+				OneToOneNat: &compute.OneToOneNat{Address: "51.250.1.2"},
+			},
+		}},
+	}
+
+	tests := []struct {
+		labels string
+		fields []string
+		want   bool
+	}{
+		{want: true},
+		{labels: "env=prod,tier in (web,api)", want: true},
+		{labels: "env=prod,!gpu", want: true},
+		{labels: "env=prod,gpu", want: false},
+		{labels: "role!=db", want: true},
+		{fields: []string{"name=~^web-", "status=running", "zone=ru-central1-a"}, want: true},
+		{fields: []string{"ip=51.250.1.2", "internal-ip=10.0.0.5", "subnet=e9b1"}, want: true},
+		{fields: []string{"platform!=standard-v3"}, want: false},
+		{fields: []string{"sa=aje1"}, want: false},
+		{fields: []string{"sa!=aje1"}, want: true},
+		{labels: "env=prod", fields: []string{"status=stopped"}, want: false},
+	}
+	for _, tt := range tests {
+		var sel Selector
+		if len(tt.labels) > 0 {
+			sel.Labels = mustLabelSelector(t, tt.labels)
+		}
+		for _, f := range tt.fields {
+			sel.Fields = append(sel.Fields, mustFieldFilter(t, f))
+		}
+
+		if got := sel.Matches(instance); got != tt.want {
+			t.Errorf("labels %q fields %q: matches = %t, want %t", tt.labels, tt.fields, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// SerialPorts is the number of serial ports of an instance.
+const SerialPorts = 4
+
+// ComputeInstanceSerialPortOutput returns the output of the serial port (1-4) of the instance.
+func (c *Client) ComputeInstanceSerialPortOutput(ctx context.Context, id string, port int64) (string, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := c.backend.Instance().GetSerialPortOutput(cctx, &compute.GetInstanceSerialPortOutputRequest{
+		InstanceId: id,
+		Port:       port,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Contents, nil
+}
+
+// ComputeInstanceSerialPortFollow writes the serial port output to w and then polls for new output
+// until the context is done.
+func (c *Client) ComputeInstanceSerialPortFollow(ctx context.Context, id string, port int64, w io.Writer) error {
+	var prev string
+	for {
+		out, err := c.ComputeInstanceSerialPortOutput(ctx, id, port)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if _, err = io.WriteString(w, newOutput(prev, out)); err != nil {
+			return err
+		}
+		prev = out
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(waitInterval):
+		}
+	}
+}
+
+// anchorSize is the length of the end of the previous output looked up in the current one.
+const anchorSize = 512
+
+// newOutput returns the part of cur after prev. The API returns the last megabyte of the output only,
+// so when prev is no longer a prefix of cur the end of prev is looked up instead.
+func newOutput(prev, cur string) string {
+	if strings.HasPrefix(cur, prev) {
+		return cur[len(prev):]
+	}
+
+	anchor := prev[max(0, len(prev)-anchorSize):]
+	if i := strings.LastIndex(cur, anchor); i >= 0 && len(anchor) > 0 {
+		return cur[i+len(anchor):]
+	}
+
+	return cur
+}
+
+var cloudInitError = regexp.MustCompile(
+	`(?i)cloud-init.*\b(error|failed|critical)\b|\bTraceback\b|Failed to run module|\[(WARNING|ERROR|CRITICAL)]`,
+)
+
+// Highlighter colors the lines in which cloud-init reports a failure.
+// Lines are written when complete, call Flush to write the rest.
+type Highlighter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewHighlighter(w io.Writer) *Highlighter {
+	return &Highlighter{w: w}
+}
+
+func (h *Highlighter) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	for {
+		i := bytes.IndexByte(h.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := h.writeLine(h.buf[:i], "\n"); err != nil {
+			return len(p), err
+		}
+		h.buf = h.buf[i+1:]
+	}
+}
+
+// Flush writes the last line if it has no line break.
+func (h *Highlighter) Flush() error {
+	if len(h.buf) == 0 {
+		return nil
+	}
+
+	err := h.writeLine(h.buf, "")
+	h.buf = nil
+
+	return err
+}
+
+func (h *Highlighter) writeLine(line []byte, eol string) error {
+	s := string(line)
+	if cloudInitError.MatchString(s) {
+		s = text.Colors{text.FgRed, text.Bold}.Sprint(strings.TrimSuffix(s, "\r"))
+	}
+
+	_, err := io.WriteString(h.w, s+eol)
+	return err
+}
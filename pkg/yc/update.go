@@ -0,0 +1,136 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/ks-tool/ks/pkg/utils"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-sdk/operation"
+
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// ComputeInstanceUpdate is a change of the instance settings. Nil fields are left as is.
+type ComputeInstanceUpdate struct {
+	PlatformID       *string
+	Cores            *int64
+	CoreFraction     *int64
+	MemoryGib        *int64
+	Preemptible      *bool
+	ServiceAccountID *string
+}
+
+// InstanceChange is a changed setting of an instance.
+type InstanceChange struct {
+	Field         string
+	Before, After string
+	// Restart is set if the instance must be stopped to apply the change.
+	Restart bool
+}
+
+// Request returns the update request of the instance with the field mask of the changed settings,
+// and the changes. Settings equal to the current ones are not changed.
+func (u ComputeInstanceUpdate) Request(i *compute.Instance) (*compute.UpdateInstanceRequest, []InstanceChange) {
+	req := &compute.UpdateInstanceRequest{
+		InstanceId: i.Id,
+		UpdateMask: &fieldmaskpb.FieldMask{},
+	}
+
+	var changes []InstanceChange
+	change := func(field, before, after string, restart bool) bool {
+		if before == after {
+			return false
+		}
+		changes = append(changes, InstanceChange{Field: field, Before: before, After: after, Restart: restart})
+		return true
+	}
+
+	if u.PlatformID != nil && change("platform", i.PlatformId, *u.PlatformID, true) {
+		req.PlatformId = *u.PlatformID
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "platform_id")
+	}
+
+	// The resources are replaced as a whole.
+	r := i.GetResources()
+	spec := &compute.ResourcesSpec{
+		Memory:       r.GetMemory(),
+		Cores:        r.GetCores(),
+		CoreFraction: r.GetCoreFraction(),
+		Gpus:         r.GetGpus(),
+	}
+	var resources bool
+	if u.Cores != nil && change("cores", itoa(spec.Cores), itoa(*u.Cores), true) {
+		spec.Cores, resources = *u.Cores, true
+	}
+	if u.CoreFraction != nil && change("core fraction", itoa(spec.CoreFraction)+"%", itoa(*u.CoreFraction)+"%", true) {
+		spec.CoreFraction, resources = *u.CoreFraction, true
+	}
+	if u.MemoryGib != nil {
+		memory := *u.MemoryGib * utils.Gib
+		if change("memory", itoa(spec.Memory/utils.Gib)+"G", itoa(*u.MemoryGib)+"G", true) {
+			spec.Memory, resources = memory, true
+		}
+	}
+	if resources {
+		req.ResourcesSpec = spec
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "resources_spec")
+	}
+
+	preemptible := i.GetSchedulingPolicy().GetPreemptible()
+	if u.Preemptible != nil && change("preemptible", strconv.FormatBool(preemptible), strconv.FormatBool(*u.Preemptible), true) {
+		req.SchedulingPolicy = &compute.SchedulingPolicy{Preemptible: *u.Preemptible}
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "scheduling_policy")
+	}
+
+	if u.ServiceAccountID != nil && change("service account", i.ServiceAccountId, *u.ServiceAccountID, false) {
+		req.ServiceAccountId = *u.ServiceAccountID
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "service_account_id")
+	}
+
+	return req, changes
+}
+
+// NeedsRestart reports whether any of the changes requires the instance to be stopped.
+func NeedsRestart(changes []InstanceChange) bool {
+	for _, ch := range changes {
+		if ch.Restart {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Client) ComputeInstanceUpdate(ctx context.Context, req *compute.UpdateInstanceRequest) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	if len(req.GetUpdateMask().GetPaths()) == 0 {
+		return nil, fmt.Errorf("nothing to update in compute instance %s", req.InstanceId)
+	}
+
+	return c.wrapOperation(c.backend.Instance().Update(cctx, req))
+}
+
+func itoa(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
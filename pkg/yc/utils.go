@@ -16,7 +16,13 @@ limitations under the License.
 
 package yc
 
-import "github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+import (
+	"github.com/ks-tool/ks/pkg/common"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+
+	"gopkg.in/yaml.v3"
+)
 
 type ComputeInstanceIPv4 struct {
 	e, i string
@@ -30,19 +36,46 @@ func (i ComputeInstanceIPv4) External() string {
 	return i.e
 }
 
+// HasExternal reports whether the instance has a one-to-one NAT address, External falls back to the internal one.
+func (i ComputeInstanceIPv4) HasExternal() bool {
+	return len(i.e) > 0
+}
+
 func (i ComputeInstanceIPv4) Internal() string {
 	return i.i
 }
 
 func GetIPv4(i *compute.Instance) ComputeInstanceIPv4 {
-	ipv4 := i.NetworkInterfaces[0].PrimaryV4Address
-	var e string
-	if ipv4.OneToOneNat != nil {
-		e = ipv4.OneToOneNat.Address
+	if len(i.NetworkInterfaces) == 0 {
+		return ComputeInstanceIPv4{}
 	}
 
+	ipv4 := i.NetworkInterfaces[0].GetPrimaryV4Address()
 	return ComputeInstanceIPv4{
-		e: e,
-		i: ipv4.Address,
+		e: ipv4.GetOneToOneNat().GetAddress(),
+		i: ipv4.GetAddress(),
 	}
 }
+
+// GetUser returns the first user created by the cloud-config user-data of the instance.
+// The instance must be got with its metadata, see Client.ComputeInstanceGet.
+func GetUser(i *compute.Instance) string {
+	var cloudConfig struct {
+		Users []yaml.Node `yaml:"users"`
+	}
+	if err := yaml.Unmarshal([]byte(i.Metadata[common.UserDataKey]), &cloudConfig); err != nil {
+		return ""
+	}
+
+	for _, node := range cloudConfig.Users {
+		var usr struct {
+			Name string `yaml:"name"`
+		}
+		// A plain string is a default user of the image.
+		if node.Kind == yaml.MappingNode && node.Decode(&usr) == nil && len(usr.Name) > 0 {
+			return usr.Name
+		}
+	}
+
+	return ""
+}
@@ -18,39 +18,400 @@ package yc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+
+	"github.com/ks-tool/ks/pkg/common"
 
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
-	"github.com/yandex-cloud/go-sdk/sdkresolvers"
+	"github.com/yandex-cloud/go-sdk/operation"
 )
 
-func (c *Client) FirstSubnetInZone(ctx context.Context, folderID string, zone string) (string, error) {
+const (
+	// AutoNetworkName is the name of the network created with --auto-network.
+	AutoNetworkName = "ks"
+	// AutoNetworkCIDR is the range subnets are allocated from when no CIDR is given, a /24 per subnet.
+	AutoNetworkCIDR = "10.128.0.0/16"
+
+	autoSubnetPrefix = 24
+)
+
+// AutoNetworkZones are the zones the auto network gets a subnet in.
+var AutoNetworkZones = []string{"ru-central1-a", "ru-central1-b", "ru-central1-d"}
+
+// ErrNoSubnet is returned when an instance is created in a zone without a subnet.
+var ErrNoSubnet = errors.New("no subnet")
+
+func (c *Client) VPCNetworkCreate(
+	ctx context.Context,
+	folderID, name, description string,
+	labels map[string]string,
+) (*operation.Operation, error) {
 	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
-	resp, err := c.sdk.VPC().Subnet().List(cctx, &vpc.ListSubnetsRequest{
-		FolderId: folderID,
-		PageSize: sdkresolvers.DefaultResolverPageSize,
-	})
+	return c.wrapOperation(c.backend.Network().Create(cctx, &vpc.CreateNetworkRequest{
+		FolderId:    folderID,
+		Name:        name,
+		Description: description,
+		Labels:      labels,
+	}))
+}
+
+// VPCNetworkDelete deletes the network. Networks with subnets can't be deleted.
+func (c *Client) VPCNetworkDelete(ctx context.Context, id string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Network().Delete(cctx, &vpc.DeleteNetworkRequest{NetworkId: id}))
+}
+
+// VPCNetworkList returns all networks of the folder.
+func (c *Client) VPCNetworkList(ctx context.Context, folderID string) ([]*vpc.Network, error) {
+	req := &vpc.ListNetworksRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*vpc.Network
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.Network().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Networks...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// VPCNetworkResolve finds the network of the folder referenced by name or ID.
+func (c *Client) VPCNetworkResolve(ctx context.Context, folderID, ref string) (*vpc.Network, error) {
+	lst, err := c.VPCNetworkList(ctx, folderID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	if len(resp.Subnets) == 0 {
-		return "", fmt.Errorf("no subnet in zone %q", zone)
+	return resolveOne("network", folderID, ref, lst, func(n *vpc.Network) (string, string) { return n.Id, n.Name })
+}
+
+// SubnetConfig describes a subnet to create. The CIDR is allocated from AutoNetworkCIDR if empty.
+type SubnetConfig struct {
+	FolderID  string
+	NetworkID string
+	Name      string
+	Zone      string
+	CIDR      string
+	Labels    map[string]string
+}
+
+func (c *Client) VPCSubnetCreate(ctx context.Context, cfg *SubnetConfig) (*operation.Operation, error) {
+	req := &vpc.CreateSubnetRequest{
+		FolderId:  cfg.FolderID,
+		NetworkId: cfg.NetworkID,
+		Name:      cfg.Name,
+		Labels:    cfg.Labels,
+		ZoneId:    cfg.Zone,
+	}
+	if len(req.ZoneId) == 0 {
+		req.ZoneId = DefaultZone
+	}
+
+	cidr := cfg.CIDR
+	if len(cidr) == 0 {
+		lst, err := c.VPCSubnetList(ctx, cfg.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		if cidr, err = freeCIDR(AutoNetworkCIDR, autoSubnetPrefix, subnetBlocks(lst)); err != nil {
+			return nil, err
+		}
+	}
+	req.V4CidrBlocks = []string{cidr}
+
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Subnet().Create(cctx, req))
+}
+
+// VPCSubnetDelete deletes the subnet. Subnets used by instances can't be deleted.
+func (c *Client) VPCSubnetDelete(ctx context.Context, id string) (*operation.Operation, error) {
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	return c.wrapOperation(c.backend.Subnet().Delete(cctx, &vpc.DeleteSubnetRequest{SubnetId: id}))
+}
+
+// VPCSubnetList returns all subnets of the folder.
+func (c *Client) VPCSubnetList(ctx context.Context, folderID string) ([]*vpc.Subnet, error) {
+	req := &vpc.ListSubnetsRequest{FolderId: folderID, PageSize: listPageSize}
+
+	var out []*vpc.Subnet
+	for {
+		cctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		resp, err := c.backend.Subnet().List(cctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, resp.Subnets...)
+		if len(resp.NextPageToken) == 0 {
+			return out, nil
+		}
+		req.PageToken = resp.NextPageToken
+	}
+}
+
+// VPCSubnetResolve finds the subnet of the folder referenced by name or ID.
+func (c *Client) VPCSubnetResolve(ctx context.Context, folderID, ref string) (*vpc.Subnet, error) {
+	lst, err := c.VPCSubnetList(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveOne("subnet", folderID, ref, lst, func(s *vpc.Subnet) (string, string) { return s.Id, s.Name })
+}
+
+// SubnetPreference picks a subnet when a zone has several. Subnets with all the labels
+// are preferred over the ones within the CIDR; any subnet of the zone is used if none match.
+type SubnetPreference struct {
+	Labels map[string]string
+	CIDR   *net.IPNet
+}
+
+// ParseSubnetPreference parses key=value labels and a CIDR, both optional.
+func ParseSubnetPreference(labels []string, cidr string) (SubnetPreference, error) {
+	var pref SubnetPreference
+	for _, kv := range labels {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || len(key) == 0 {
+			return pref, fmt.Errorf("subnet label %q: key=value expected", kv)
+		}
+		if pref.Labels == nil {
+			pref.Labels = make(map[string]string)
+		}
+		pref.Labels[key] = value
+	}
+
+	if len(cidr) > 0 {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return pref, fmt.Errorf("subnet CIDR: %w", err)
+		}
+		pref.CIDR = block
+	}
+
+	return pref, nil
+}
+
+func (p SubnetPreference) score(s *vpc.Subnet) int {
+	var score int
+	if len(p.Labels) > 0 {
+		match := true
+		for k, v := range p.Labels {
+			if s.Labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			score += 2
+		}
 	}
 
-	subnetID := ""
-	for _, subnet := range resp.Subnets {
+	if p.CIDR != nil {
+		for _, cidr := range s.V4CidrBlocks {
+			_, block, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			if p.CIDR.Contains(block.IP) && prefixLen(block) >= prefixLen(p.CIDR) {
+				score++
+				break
+			}
+		}
+	}
+
+	return score
+}
+
+// FirstSubnetInZone returns the ID of the most preferred subnet of the zone.
+// Among equally preferred subnets the first one listed wins.
+func (c *Client) FirstSubnetInZone(ctx context.Context, folderID, zone string, pref SubnetPreference) (string, error) {
+	lst, err := c.VPCSubnetList(ctx, folderID)
+	if err != nil {
+		return "", err
+	}
+
+	var best *vpc.Subnet
+	bestScore := -1
+	for _, subnet := range lst {
 		if subnet.ZoneId != zone {
 			continue
 		}
-		subnetID = subnet.Id
-		break
+		if score := pref.score(subnet); score > bestScore {
+			best, bestScore = subnet, score
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("%w in zone %q of folder %s", ErrNoSubnet, zone, folderID)
+	}
+
+	return best.Id, nil
+}
+
+// EnsureAutoNetwork creates the network AutoNetworkName managed by ks, unless the folder has it,
+// and a subnet in each of AutoNetworkZones the network has no subnet in.
+// A network AutoNetworkName not managed by ks is an error.
+// CIDRs of new subnets don't overlap with any subnet of the folder.
+func (c *Client) EnsureAutoNetwork(ctx context.Context, folderID string) (*vpc.Network, []*vpc.Subnet, error) {
+	labels := map[string]string{common.ManagedKey: KsToolKey}
+
+	networks, err := c.VPCNetworkList(ctx, folderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var network *vpc.Network
+	for _, n := range networks {
+		if n.Name == AutoNetworkName {
+			network = n
+			break
+		}
+	}
+	if network != nil && network.Labels[common.ManagedKey] != KsToolKey {
+		return nil, nil, fmt.Errorf("network %s (%s) is not managed by ks", network.Name, network.Id)
+	}
+	if network == nil {
+		op, err := c.VPCNetworkCreate(ctx, folderID, AutoNetworkName, "Created by ks with --auto-network", labels)
+		if network, err = operationResult[*vpc.Network](ctx, op, err); err != nil {
+			return nil, nil, fmt.Errorf("create network %s: %w", AutoNetworkName, err)
+		}
+	}
+
+	all, err := c.VPCSubnetList(ctx, folderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var subnets []*vpc.Subnet
+	zones := make(map[string]bool)
+	for _, s := range all {
+		if s.NetworkId == network.Id {
+			subnets = append(subnets, s)
+			zones[s.ZoneId] = true
+		}
+	}
+
+	used := subnetBlocks(all)
+	for _, zone := range AutoNetworkZones {
+		if zones[zone] {
+			continue
+		}
+
+		cidr, err := freeCIDR(AutoNetworkCIDR, autoSubnetPrefix, used)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, block, _ := net.ParseCIDR(cidr)
+		used = append(used, block)
+
+		op, err := c.VPCSubnetCreate(ctx, &SubnetConfig{
+			FolderID:  folderID,
+			NetworkID: network.Id,
+			Name:      AutoNetworkName + "-" + zone,
+			Zone:      zone,
+			CIDR:      cidr,
+			Labels:    labels,
+		})
+		subnet, err := operationResult[*vpc.Subnet](ctx, op, err)
+		if err != nil {
+			return nil, nil, fmt.Errorf("create subnet in zone %s: %w", zone, err)
+		}
+		subnets = append(subnets, subnet)
+	}
+
+	return network, subnets, nil
+}
+
+// operationResult waits for the operation returned with err and returns its response.
+func operationResult[T any](ctx context.Context, op *operation.Operation, err error) (T, error) {
+	var zero T
+	if err != nil {
+		return zero, err
 	}
-	if subnetID == "" {
-		return "", fmt.Errorf("subnet not found")
+	if err = op.Wait(ctx); err != nil {
+		return zero, err
 	}
 
-	return subnetID, err
+	resp, err := op.Response()
+	if err != nil {
+		return zero, err
+	}
+	out, ok := resp.(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected response %T of operation %s", resp, op.Id())
+	}
+
+	return out, nil
+}
+
+func subnetBlocks(lst []*vpc.Subnet) []*net.IPNet {
+	var out []*net.IPNet
+	for _, s := range lst {
+		for _, cidr := range s.V4CidrBlocks {
+			if _, block, err := net.ParseCIDR(cidr); err == nil {
+				out = append(out, block)
+			}
+		}
+	}
+
+	return out
+}
+
+// freeCIDR returns the first block with the prefix length within pool which overlaps none of used.
+func freeCIDR(pool string, prefix int, used []*net.IPNet) (string, error) {
+	_, poolNet, err := net.ParseCIDR(pool)
+	if err != nil {
+		return "", err
+	}
+	base := poolNet.IP.To4()
+	if base == nil || prefix < prefixLen(poolNet) || prefix > 30 {
+		return "", fmt.Errorf("can't allocate /%d blocks from %s", prefix, pool)
+	}
+
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	step := uint32(1) << (32 - prefix)
+	count := uint32(1) << (prefix - prefixLen(poolNet))
+	for i := uint32(0); i < count; i++ {
+		v := start + i*step
+		block := &net.IPNet{
+			IP:   net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)).To4(),
+			Mask: net.CIDRMask(prefix, 32),
+		}
+
+		free := true
+		for _, u := range used {
+			if u.Contains(block.IP) || block.Contains(u.IP) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return block.String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no free /%d block in %s", prefix, pool)
+}
+
+func prefixLen(n *net.IPNet) int {
+	ones, _ := n.Mask.Size()
+	return ones
 }
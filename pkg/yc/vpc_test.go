@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFreeCIDR(t *testing.T) {
+	blocks := func(cidrs ...string) []*net.IPNet {
+		var out []*net.IPNet
+		for _, cidr := range cidrs {
+			_, block, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, block)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		pool    string
+		prefix  int
+		used    []*net.IPNet
+		want    string
+		wantErr bool
+	}{
+		{name: "empty", pool: AutoNetworkCIDR, prefix: 24, want: "10.128.0.0/24"},
+		{name: "first used", pool: AutoNetworkCIDR, prefix: 24, used: blocks("10.128.0.0/24"), want: "10.128.1.0/24"},
+		{name: "gap", pool: AutoNetworkCIDR, prefix: 24, used: blocks("10.128.0.0/24", "10.128.2.0/24"), want: "10.128.1.0/24"},
+		{name: "smaller used block", pool: AutoNetworkCIDR, prefix: 24, used: blocks("10.128.0.128/28"), want: "10.128.1.0/24"},
+		{name: "larger used block", pool: AutoNetworkCIDR, prefix: 24, used: blocks("10.128.0.0/22"), want: "10.128.4.0/24"},
+		{name: "outside the pool", pool: AutoNetworkCIDR, prefix: 24, used: blocks("192.168.0.0/16", "10.0.0.0/16"), want: "10.128.0.0/24"},
+		{name: "exhausted", pool: "10.128.0.0/23", prefix: 24, used: blocks("10.128.0.0/24", "10.128.1.0/24"), wantErr: true},
+		{name: "covered", pool: AutoNetworkCIDR, prefix: 24, used: blocks("10.0.0.0/8"), wantErr: true},
+		{name: "prefix shorter than the pool", pool: AutoNetworkCIDR, prefix: 15, wantErr: true},
+		{name: "IPv6 pool", pool: "fd00::/64", prefix: 80, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := freeCIDR(tt.pool, tt.prefix, tt.used)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+)
+
+type WaitKind string
+
+const (
+	WaitStatus    WaitKind = "status"
+	WaitSSH       WaitKind = "ssh"
+	WaitCloudInit WaitKind = "cloud-init"
+)
+
+// WaitConditions is the list of wait conditions for flag help.
+const WaitConditions = "status=<status>|ssh|cloud-init"
+
+// WaitCondition is a state of a compute instance to wait for.
+// Every kind implies the ones before it: SSH waits for RUNNING, cloud-init waits for SSH if it is used.
+type WaitCondition struct {
+	Kind   WaitKind
+	Status compute.Instance_Status
+}
+
+// ParseWaitCondition parses "status=<status>", "ssh" or "cloud-init".
+func ParseWaitCondition(s string) (WaitCondition, error) {
+	kind, value, _ := strings.Cut(s, "=")
+	cond := WaitCondition{Kind: WaitKind(kind), Status: compute.Instance_RUNNING}
+
+	switch cond.Kind {
+	case WaitStatus:
+		st, ok := compute.Instance_Status_value[strings.ToUpper(value)]
+		if !ok || st == 0 {
+			return cond, fmt.Errorf("invalid status %q", value)
+		}
+		cond.Status = compute.Instance_Status(st)
+	case WaitSSH, WaitCloudInit:
+	default:
+		return cond, fmt.Errorf("invalid wait condition %q, allow: %s", s, WaitConditions)
+	}
+
+	return cond, nil
+}
+
+// ComputeInstanceWaitStatus polls the instance until it has the status.
+// It fails if the instance gets into ERROR or CRASHED, unless that is the status asked for.
+func (c *Client) ComputeInstanceWaitStatus(
+	ctx context.Context,
+	id string,
+	st compute.Instance_Status,
+) (*compute.Instance, error) {
+	for {
+		instance, err := c.ComputeInstanceGet(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		switch instance.Status {
+		case st:
+			return instance, nil
+		case compute.Instance_ERROR, compute.Instance_CRASHED:
+			return nil, fmt.Errorf("compute instance %s is %s", instance.Name, instance.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: compute instance %s is %s", ctx.Err(), instance.Name, instance.Status)
+		case <-time.After(waitInterval):
+		}
+	}
+}
+
+var (
+	cloudInitFinished = regexp.MustCompile(`Cloud-init v\. \S+ finished at`)
+	// cloudInitFailure matches the lines of the modules failures `cloud-init status` reports as an error.
+	cloudInitFailure = regexp.MustCompile(`Failed to run module|Failed running|\[CRITICAL]|\bTraceback\b`)
+)
+
+// cloudInitResult reports whether cloud-init has finished according to the serial port output,
+// and the first failure it reported if so.
+func cloudInitResult(out string) (bool, error) {
+	if !cloudInitFinished.MatchString(out) {
+		return false, nil
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if cloudInitFailure.MatchString(line) {
+			return true, fmt.Errorf("cloud-init failed: %s", strings.TrimSpace(line))
+		}
+	}
+
+	return true, nil
+}
+
+// ComputeInstanceWaitCloudInit polls the serial port output until cloud-init reports it has finished.
+// It fails if cloud-init reported a failed module.
+func (c *Client) ComputeInstanceWaitCloudInit(ctx context.Context, id string) error {
+	for {
+		out, err := c.ComputeInstanceSerialPortOutput(ctx, id, 1)
+		if err != nil {
+			return err
+		}
+		if finished, err := cloudInitResult(out); finished {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: cloud-init has not finished", ctx.Err())
+		case <-time.After(waitInterval):
+		}
+	}
+}
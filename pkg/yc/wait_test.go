@@ -0,0 +1,78 @@
+/*
+Copyright © 2024 Alexey Shulutkov <github@shulutkov.ru>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yc
+
+import "testing"
+
+func TestCloudInitResult(t *testing.T) {
+	tests := []struct {
+		name         string
+		out          string
+		wantFinished bool
+		wantErr      string
+	}{
+		{
+			name: "running",
+			out:  "Cloud-init v. 23.4 running 'modules:config' at Mon, 01 Jan 2024 00:00:01 +0000.\n",
+		},
+		{
+			name:         "finished",
+			out:          "Cloud-init v. 23.4 finished at Mon, 01 Jan 2024 00:00:10 +0000. Datasource DataSourceEc2.  Up 10.00 seconds\n",
+			wantFinished: true,
+		},
+		{
+			name: "failed module",
+			out: "[   8.1] cloud-init[812]: 2024-01-01 00:00:08,000 - util.py[WARNING]: Failed running /var/lib/cloud/instance/scripts/runcmd [1]\n" +
+				"[   8.2] cloud-init[812]: 2024-01-01 00:00:08,100 - util.py[WARNING]: Failed to run module scripts_user (scripts in /var/lib/cloud/instance/scripts)\n" +
+				"Cloud-init v. 23.4 finished at Mon, 01 Jan 2024 00:00:10 +0000. Datasource DataSourceEc2.  Up 10.00 seconds\n",
+			wantFinished: true,
+			wantErr:      "cloud-init failed: [   8.1] cloud-init[812]: 2024-01-01 00:00:08,000 - util.py[WARNING]: Failed running /var/lib/cloud/instance/scripts/runcmd [1]",
+		},
+		{
+			name: "failure before finish",
+			out:  "Failed to run module scripts_user\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finished, err := cloudInitResult(tt.out)
+			if finished != tt.wantFinished {
+				t.Errorf("finished = %v, want %v", finished, tt.wantFinished)
+			}
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("err = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseWaitCondition(t *testing.T) {
+	for _, s := range []string{"ssh", "cloud-init", "status=stopped", "status=RUNNING"} {
+		if _, err := ParseWaitCondition(s); err != nil {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "status", "status=nope", "http"} {
+		if _, err := ParseWaitCondition(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
@@ -17,14 +17,22 @@ limitations under the License.
 package yc
 
 import (
+	"context"
 	"errors"
 
+	ycop "github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
 	ycsdk "github.com/yandex-cloud/go-sdk"
 	"github.com/yandex-cloud/go-sdk/iamkey"
+	"github.com/yandex-cloud/go-sdk/operation"
 )
 
 type Client struct {
-	sdk *ycsdk.SDK
+	backend Backend
+}
+
+// NewFromBackend creates a client on top of the given services, e.g. the fake cloud from pkg/yc/fake.
+func NewFromBackend(backend Backend) *Client {
+	return &Client{backend: backend}
 }
 
 // NewFromToken creates an SDK instance with credentials for user Yandex Passport OAuth token.
@@ -34,15 +42,21 @@ func NewFromToken(token string) (*Client, error) {
 		return nil, errors.New("token required")
 	}
 
-	sdk, _ := ycsdk.Build(nil, ycsdk.Config{
-		Credentials: ycsdk.OAuthToken(token),
-	})
-
-	return &Client{sdk: sdk}, nil
+	return NewFromCredentials(context.Background(), ycsdk.OAuthToken(token))
 }
 
-// NewFromIAMKey creates an SDK instance with credentials for the given IAM Key
+// NewFromIAMToken creates an SDK instance with credentials for the given IAM token.
 // See https://yandex.cloud/ru/docs/iam/concepts/authorization/iam-token for details.
+func NewFromIAMToken(token string) (*Client, error) {
+	if len(token) == 0 {
+		return nil, errors.New("token required")
+	}
+
+	return NewFromCredentials(context.Background(), ycsdk.NewIAMTokenCredentials(token))
+}
+
+// NewFromIAMKey creates an SDK instance with credentials for the given service account authorized key JSON.
+// See https://yandex.cloud/ru/docs/iam/concepts/authorization/key for details.
 func NewFromIAMKey(token []byte) (*Client, error) {
 	key, err := iamkey.ReadFromJSONBytes(token)
 	if err != nil {
@@ -54,17 +68,36 @@ func NewFromIAMKey(token []byte) (*Client, error) {
 		return nil, err
 	}
 
-	sdk, _ := ycsdk.Build(nil, ycsdk.Config{
+	return NewFromCredentials(context.Background(), cred)
+}
+
+// NewFromCredentials creates an SDK instance with the given credentials.
+func NewFromCredentials(ctx context.Context, cred ycsdk.Credentials) (*Client, error) {
+	sdk, err := ycsdk.Build(ctx, ycsdk.Config{
 		Credentials: cred,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return &Client{sdk: sdk}, nil
+	return &Client{backend: sdkBackend{sdk: sdk}}, nil
 }
 
-func NewClient(token string) (*Client, error) {
-	if token[:2] == "t1." {
-		return NewFromIAMKey([]byte(token))
+// NewClient creates an SDK instance with the credentials found by ResolveCredentials.
+func NewClient(ctx context.Context, cfg CredentialsConfig) (*Client, error) {
+	cred, err := ResolveCredentials(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromCredentials(ctx, cred)
+}
+
+// wrapOperation binds an operation proto to the backend's operation service so that it can be waited on.
+func (c *Client) wrapOperation(op *ycop.Operation, err error) (*operation.Operation, error) {
+	if err != nil {
+		return nil, err
 	}
 
-	return NewFromToken(token)
+	return operation.New(c.backend.Operation(), op), nil
 }